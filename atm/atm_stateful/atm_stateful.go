@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"fmt"
+	"os"
 	"sort"
 	"strings"
 	"sync"
@@ -19,6 +20,10 @@ var (
 	ErrCannotDispense           = errors.New("cannot dispense exact amount")
 	ErrInsufficientFunds        = errors.New("insufficient funds")
 	ErrDailyLimitExceeded       = errors.New("daily limit exceeded")
+	ErrCardDailyLimitExceeded   = errors.New("card daily limit exceeded")
+	ErrWithdrawalCountExceeded  = errors.New("too many withdrawals today")
+	ErrVelocityLimitExceeded    = errors.New("too many withdrawals in a short time, card blocked")
+	ErrNoTransactionService     = errors.New("account service has no transaction history to check limits against")
 	ErrAccountNotFound          = errors.New("account not found")
 	ErrTransactionsDoesNotExist = errors.New("No transactions found for this account")
 	ErrTransactionDoesNotExist  = errors.New("No transactions found for this transaction id")
@@ -31,26 +36,40 @@ type User struct {
 	Location Location
 }
 
+// BankService - BankServiceV1 in-process, HostBankService over ISO 8583
+// to a bank switch (bank_switch.go)
 type BankService interface {
 	ValidatePin(cardNumber, pin string) (bool, error)
-	DebitAccount(accountId string, amount float64) error
-	CreditAccount(accountId string, amount float64) error
+	// ChangePin - the bank checks currentPin itself, the ATM's own check
+	// is not enough to let anyone who knows a card number reset its PIN
+	ChangePin(cardNumber, currentPin, newPin string) error
+	// DebitAccount / CreditAccount - ref = the ATM transaction id, what
+	// Reverse undoes later
+	DebitAccount(accountId string, amount Money, ref string) error
+	CreditAccount(accountId string, amount Money, ref string) error
+	// Reverse - undo the posting made under ref (on the host: an 0420 for
+	// that 0200, never a new 0200 the other way). Unknown ref = nothing to undo
+	Reverse(ref string) error
 }
 
 type BankServiceV1 struct {
+	pins map[string]pinRecord // cardNumber -> salted PIN hash (pin_security.go)
+	mu   sync.RWMutex
 }
 
-func (s *BankServiceV1) ValidatePin(cardNumber, pin string) (bool, error) {
-	// Validate PIN with bank's system
-	//maybe call Bank's API
-	return true, nil
+func NewBankServiceV1() *BankServiceV1 {
+	return &BankServiceV1{pins: make(map[string]pinRecord)}
 }
 
-func (s *BankServiceV1) DebitAccount(accountId string, amount float64) error {
+func (s *BankServiceV1) DebitAccount(accountId string, amount Money, ref string) error {
 	return nil
 }
 
-func (s *BankServiceV1) CreditAccount(accountId string, amount float64) error {
+func (s *BankServiceV1) CreditAccount(accountId string, amount Money, ref string) error {
+	return nil
+}
+
+func (s *BankServiceV1) Reverse(ref string) error {
 	return nil
 }
 
@@ -67,97 +86,163 @@ type Location struct {
 }
 
 type CashDispenser interface {
-	Dispense(amount float64) error
+	Dispense(amount Money) error
 	Deposit(denoms map[float64]int) error
-	GetCurrentBalance() float64
+	GetCurrentBalance() Money
+	Cassettes() map[float64]int // sellable notes per denomination, a copy
+
+	// Two-phase dispense for the withdraw saga:
+	// ReserveNotes takes notes out of the sellable inventory (nobody else
+	// can get them), DispenseNotes pushes them out, ReleaseNotes undoes
+	// the reservation if a later step fails.
+	// requested = customer's note mix (see note_mix.go), nil = any
+	ReserveNotes(amount Money, requested map[float64]int) (map[float64]int, error)
+	ReleaseNotes(notes map[float64]int) error
+	DispenseNotes(notes map[float64]int) error
 }
 
+// CashDispenserV1 - cash is counted from the notes, no separate balance
+// field that can drift away from what is in the cassettes
 type CashDispenserV1 struct {
-	CurrBalance   float64
-	CashInventory map[float64]int
+	CashInventory map[float64]int // sellable notes
+	reserved      map[float64]int // reserved, not yet dispensed
+	Objective     MixObjective    // nil = FewestNotes
 	mu            sync.Mutex
 }
 
-func NewCashDispenserV1(currBalance float64, cashInventory map[float64]int) (*CashDispenserV1, error) {
+func NewCashDispenserV1(cashInventory map[float64]int) (*CashDispenserV1, error) {
 	return &CashDispenserV1{
-		CurrBalance:   currBalance,
 		CashInventory: cashInventory,
+		reserved:      make(map[float64]int),
 	}, nil
 }
 
-func (d *CashDispenserV1) GetCurrentBalance() float64 {
-	return d.CurrBalance
+// GetCurrentBalance - sellable cash, reserved notes excluded
+func (d *CashDispenserV1) GetCurrentBalance() Money {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return notesTotal(d.CashInventory)
+}
+
+// CountedCash - everything physically inside, reserved notes included
+// (what a cash count finds, see Reconcile)
+func (d *CashDispenserV1) CountedCash() Money {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return notesTotal(d.CashInventory).Add(notesTotal(d.reserved))
+}
+
+func (d *CashDispenserV1) Cassettes() map[float64]int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cassettes := make(map[float64]int, len(d.CashInventory))
+	for denom, count := range d.CashInventory {
+		cassettes[denom] = count
+	}
+	return cassettes
+}
+
+func notesTotal(notes map[float64]int) Money {
+	total := Paise(0)
+	for denom, count := range notes {
+		total = total.Add(NoteValue(denom, count))
+	}
+	return total
 }
 
 func (d *CashDispenserV1) Deposit(denoms map[float64]int) error {
-	total := 0.0
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
 	for denom, count := range denoms {
-		total += denom * float64(count)
 		d.CashInventory[denom] += count
 	}
-
-	d.CurrBalance += total
 	return nil
 }
 
-func (d *CashDispenserV1) Dispense(amount float64) error {
-
-	if d.CurrBalance < amount {
-		return ErrInsufficientCash
+// Dispense - one shot: reserve + dispense
+func (d *CashDispenserV1) Dispense(amount Money) error {
+	notes, err := d.ReserveNotes(amount, nil)
+	if err != nil {
+		return err
 	}
+	return d.DispenseNotes(notes)
+}
 
-	remaining := amount
-	used := map[float64]int{}
-	inv := d.CashInventory
+func (d *CashDispenserV1) ReserveNotes(amount Money, requested map[float64]int) (map[float64]int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-	// Sort denominations
-	var keys []float64
-	for denom := range inv {
-		keys = append(keys, denom)
+	if notesTotal(d.CashInventory).LessThan(amount) {
+		return nil, ErrInsufficientCash
 	}
-	sort.Sort(sort.Reverse(sort.Float64Slice(keys)))
 
-	// Greedy dispensing
-	for _, denom := range keys {
-		count := inv[denom]
-		if count <= 0 {
-			continue
-		}
+	used, err := PlanNoteMix(amount, d.CashInventory, requested, d.Objective)
+	if err != nil {
+		return nil, err
+	}
 
-		need := int(remaining / denom)
-		if need > count {
-			need = count
-		}
+	// Apply changes
+	for denom, cnt := range used {
+		d.CashInventory[denom] -= cnt
+		d.reserved[denom] += cnt
+	}
+	return used, nil
+}
 
-		if need > 0 {
-			used[denom] = need
-			remaining -= float64(need) * denom
-		}
+// ReleaseNotes - reserved notes go back to the sellable inventory
+func (d *CashDispenserV1) ReleaseNotes(notes map[float64]int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-		if remaining <= 0 {
-			break
-		}
+	if err := d.checkReserved(notes); err != nil {
+		return err
 	}
-
-	// Check if exact amount dispensed
-	if remaining > 0 {
-		return ErrCannotDispense
+	for denom, cnt := range notes {
+		d.reserved[denom] -= cnt
+		d.CashInventory[denom] += cnt
 	}
+	return nil
+}
 
-	// Apply changes
-	for denom, cnt := range used {
-		inv[denom] -= cnt
+// DispenseNotes - hardware pushes the reserved notes out of the slot
+func (d *CashDispenserV1) DispenseNotes(notes map[float64]int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.checkReserved(notes); err != nil {
+		return err
+	}
+	for denom, cnt := range notes {
+		d.reserved[denom] -= cnt
 	}
-	d.CurrBalance -= amount
+	return nil
+}
 
+func (d *CashDispenserV1) checkReserved(notes map[float64]int) error {
+	for denom, cnt := range notes {
+		if d.reserved[denom] < cnt {
+			return fmt.Errorf("only %d notes of %.0f reserved, asked for %d", d.reserved[denom], denom, cnt)
+		}
+	}
 	return nil
 }
 
+// ATMService - card + PIN are checked once by Authenticate, every
+// operation after that takes the Session (see pin_security.go)
 type ATMService interface {
-	Withdraw(cardNumber, pin string, amount float64) error
-	Deposit(cardNumber, pin string, amount float64, denominations map[float64]int) error
-	CheckBalance(cardNumber, pin string) (float64, error)
+	Authenticate(cardNumber, pin string) (*Session, error)
+	EndSession(session *Session)
+	Withdraw(session *Session, amount Money) error
+	Deposit(session *Session, amount Money, denominations map[float64]int) error
+	CheckBalance(session *Session) (Money, error)
+
+	// operations.go
+	ValidatePayee(session *Session, payeeAccountId string) (*Payee, error)
+	Transfer(session *Session, payeeAccountId string, amount Money) (*Receipt, error)
+	MiniStatement(session *Session, n int) ([]Transaction, *Receipt, error)
+	ChangePIN(session *Session, currentPin, newPin string) (*Receipt, error)
 }
 
 type ATMServiceV1 struct {
@@ -168,6 +253,16 @@ type ATMServiceV1 struct {
 	TransactionService   TransactionService
 	ReceiptService       ReceiptService
 	CashDispenserService CashDispenser
+	Currency             Currency // what the keypad and cassettes deal in, INR by default
+
+	// FaultHook - called before every saga step, a non-nil error fails
+	// that step (fault-injection in tests / demos, nil in production)
+	FaultHook func(step string) error
+	Audit     AuditLog // auth failures, nil = not recorded
+
+	sessions map[string]*Session // token -> session
+	faults   map[string]string   // hardware fault code -> detail (fleet.go)
+	mu       sync.Mutex
 }
 
 func NewATMServiceV1(atmId string, txnServ TransactionService, bankServ BankService, acctServ AccountService, cardServ CardService, receiptServ ReceiptService, dispenser CashDispenser,
@@ -180,101 +275,78 @@ func NewATMServiceV1(atmId string, txnServ TransactionService, bankServ BankServ
 		CardService:          cardServ,
 		ReceiptService:       receiptServ,
 		CashDispenserService: dispenser,
+		Currency:             INR,
+		sessions:             make(map[string]*Session),
+		faults:               make(map[string]string),
 	}, nil
 }
 
-func (s *ATMServiceV1) Withdraw(cardNumber, pin string, amount float64) error {
-	//1. validate card
-	err := s.CardService.ValidateCard(cardNumber)
-	if err != nil {
-		return err
-	}
+func (s *ATMServiceV1) Withdraw(session *Session, amount Money) error {
+	return s.WithdrawWithMix(session, amount, nil)
+}
 
-	//2. validate pin
-	isValidPIN, err := s.BankService.ValidatePin(cardNumber, pin)
+// WithdrawWithMix - Withdraw with the notes the customer asked for
+// (e.g. {100: 5}), the rest is up to the dispenser's objective
+func (s *ATMServiceV1) WithdrawWithMix(session *Session, amount Money, requested map[float64]int) error {
+	//1. card + pin checked by Authenticate, session still good?
+	accountId, err := s.checkSession(session)
 	if err != nil {
 		return err
 	}
-	if !isValidPIN {
-		return errors.New("invalid PIN")
-	}
-	accountId, err := s.CardService.GetAccountDetails(cardNumber)
-	if err != nil {
+	cardNumber := session.CardNumber
+	if err := checkCurrency(amount, s.Currency); err != nil {
 		return err
 	}
 
-	err = s.AccountService.CanWithdraw(accountId, amount)
-	if err != nil {
-		return err
+	// limits + PENDING row in one step => every later step is traceable
+	// (see saga.go) and a concurrent withdrawal already counts against us
+	txn, err := s.AccountService.BeginWithdrawal(
+		accountId,
+		cardNumber,
+		s.ATMid, // Current ATM's ID
+		amount,
+	)
+	if errors.Is(err, ErrVelocityLimitExceeded) {
+		// looks like a skimmed card being emptied => stop it right here
+		s.CardService.BlockCard(cardNumber)
+		s.audit(AuditCardBlocked, cardNumber, err.Error())
 	}
-
-	err = s.CashDispenserService.Dispense(amount)
 	if err != nil {
 		return err
 	}
 
-	err = s.AccountService.DebitAccount(accountId, amount)
+	err = s.withdrawSaga(txn, accountId, amount, requested)
 	if err != nil {
 		return err
 	}
 
-	txn, err := s.TransactionService.CreateTransaction(
-		accountId,
-		s.ATMid, // Current ATM's ID
-		amount,
-		Withdraw, // Transaction type
-	)
-
 	// Generate receipt (optional - don't fail withdrawal if receipt fails)
 	s.ReceiptService.GenerateReceipt(txn.Id)
 
 	return nil
 }
-func (s *ATMServiceV1) Deposit(cardNumber, pin string, amount float64, denominations map[float64]int) error {
-	//1. validate card
-	err := s.CardService.ValidateCard(cardNumber)
-	if err != nil {
-		return err
-	}
-
-	//2. validate pin
-	isValidPIN, err := s.BankService.ValidatePin(cardNumber, pin)
+func (s *ATMServiceV1) Deposit(session *Session, amount Money, denominations map[float64]int) error {
+	//1. card + pin checked by Authenticate, session still good?
+	accountId, err := s.checkSession(session)
 	if err != nil {
 		return err
 	}
-	if !isValidPIN {
-		return errors.New("invalid PIN")
-	}
-	accountId, err := s.CardService.GetAccountDetails(cardNumber)
-	if err != nil {
+	cardNumber := session.CardNumber
+	if err := checkCurrency(amount, s.Currency); err != nil {
 		return err
 	}
 
 	// 4. Calculate total from denominations
-	calculatedAmount := 0.0
-	for denom, count := range denominations {
-		calculatedAmount += denom * float64(count)
-	}
+	calculatedAmount := notesTotal(denominations)
 
 	// 5. Validate user-passed amount matches actual cash
 	if amount != calculatedAmount {
-		return fmt.Errorf("amount mismatch: declared %.2f but counted %.2f", amount, calculatedAmount)
-	}
-
-	//add to atm
-	err = s.CashDispenserService.Deposit(denominations)
-	if err != nil {
-		return err
+		return fmt.Errorf("amount mismatch: declared %v but counted %v", amount, calculatedAmount)
 	}
 
-	//only if adding to atm was successful then only add in account
-	err = s.AccountService.CreditAccount(accountId, amount)
-	if err != nil {
-		return err
-	}
-
-	_, err = s.TransactionService.CreateTransaction(
+	txn, err := s.TransactionService.BeginTransaction(
 		accountId,
+		cardNumber,
 		s.ATMid, // Current ATM's ID
 		amount,
 		Deposit, // Transaction type
@@ -283,46 +355,34 @@ func (s *ATMServiceV1) Deposit(cardNumber, pin string, amount float64, denominat
 		return err
 	}
 
-	return nil
+	// credit account, then store notes in the cassettes (see saga.go)
+	return s.depositSaga(txn, accountId, amount, denominations)
 }
 
-func (s *ATMServiceV1) CheckBalance(cardNumber, pin string) (float64, error) {
-	// 1. Validate card
-	err := s.CardService.ValidateCard(cardNumber)
+func (s *ATMServiceV1) CheckBalance(session *Session) (Money, error) {
+	// 1-3. Card + PIN checked by Authenticate, session still good?
+	accountId, err := s.checkSession(session)
 	if err != nil {
-		return 0, err
+		return Money{}, err
 	}
+	cardNumber := session.CardNumber
 
-	// 2. Validate PIN
-	isValidPIN, err := s.BankService.ValidatePin(cardNumber, pin)
+	// 4. Balance from the ledger
+	balance, err := s.AccountService.Balance(accountId)
 	if err != nil {
-		return 0, err
-	}
-	if !isValidPIN {
-		return 0, ErrInvalidPIN
-	}
-
-	// 3. Get account
-	accountId, err := s.CardService.GetAccountDetails(cardNumber)
-	if err != nil {
-		return 0, err
-	}
-
-	// 4. Get account details
-	account, err := s.AccountService.GetAccount(accountId)
-	if err != nil {
-		return 0, err
+		return Money{}, err
 	}
 
 	// 5. Create balance inquiry transaction (optional)
 	_, _ = s.TransactionService.CreateTransaction(
 		accountId,
+		cardNumber,
 		s.ATMid,
-		0, // No amount for balance inquiry
+		Paise(0), // No amount for balance inquiry
 		BalanceInquiry,
 	)
 
-	return account.CurrBalance, nil
+	return balance, nil
 
 }
 
@@ -334,23 +394,92 @@ type ATM struct {
 
 type AccountService interface {
 	GetAccount(accountId string) (*Account, error)
-	CanWithdraw(accountId string, amount float64) error
-	DebitAccount(accountId string, amount float64) error
-	CreditAccount(accountId string, amount float64) error
+	// Balance - book balance (available + on hold), Available - what can
+	// still be withdrawn; both summed from the ledger (ledger.go)
+	Balance(accountId string) (Money, error)
+	Available(accountId string) (Money, error)
+	// CanWithdraw - balance + every limit in WithdrawalLimits, checked
+	// against this card's / account's recent withdrawals
+	CanWithdraw(accountId, cardNumber, atmId string, amount Money) error
+	// BeginWithdrawal - CanWithdraw + the PENDING Withdraw transaction as
+	// ONE step: two withdrawals at once can't both pass a limit only one fits
+	BeginWithdrawal(accountId, cardNumber, atmId string, amount Money) (*Transaction, error)
+
+	// Every money movement is a ledger entry: counter = the other side
+	// (ATM cash for deposits, ...), ref = the Transaction id
+	DebitAccount(accountId string, amount Money, counter LedgerAccount, ref string) error
+	CreditAccount(accountId string, amount Money, counter LedgerAccount, ref string) error
+
+	// Holds for the withdraw saga: ReserveFunds moves amount to the hold
+	// account (not spendable, still in the balance), CaptureFunds pays
+	// the hold out to counter, ReleaseFunds gives it back
+	ReserveFunds(accountId string, amount Money, ref string) error
+	ReleaseFunds(accountId string, amount Money, ref string) error
+	CaptureFunds(accountId string, amount Money, counter LedgerAccount, ref string) error
+
+	ValidatePayee(fromAccountId, payeeAccountId string) (*Payee, error)
+	Transfer(fromAccountId, toAccountId string, amount Money, ref string) error
 }
 
 type AccountServiceV1 struct {
 	accounts map[string]*Account //(account_id -> account object)
-	mu       sync.RWMutex
+	ledger   *Ledger             // balances live here, not on Account
+	mu       sync.RWMutex        // check-then-post is atomic per service
+
+	// limits are computed from history, not from counters on Account
+	// => nothing to reset at midnight, nothing to get out of sync
+	transactions TransactionService
+	limits       WithdrawalLimits
+	withdrawMu   sync.Mutex       // limit check + PENDING row, see BeginWithdrawal
+	Now          func() time.Time // injectable clock, time.Now by default
 }
 
 // constructor
-func NewAccountServiceV1() (*AccountServiceV1, error) {
+func NewAccountServiceV1(txnServ TransactionService, ledger *Ledger, limits WithdrawalLimits) (*AccountServiceV1, error) {
 	return &AccountServiceV1{
-		accounts: make(map[string]*Account),
+		accounts:     make(map[string]*Account),
+		ledger:       ledger,
+		transactions: txnServ,
+		limits:       limits,
+		Now:          time.Now,
 	}, nil
 }
 
+// OpenAccount - register the account, opening balance comes from the
+// bank's reserves (ref OPEN-<id>)
+func (s *AccountServiceV1) OpenAccount(account *Account, opening Money) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.accounts[account.Id]; exists {
+		return fmt.Errorf("account %s already exists", account.Id)
+	}
+	if !opening.IsZero() { // Money{} = no opening balance
+		if err := checkCurrency(opening, s.ledger.Currency()); err != nil {
+			return err
+		}
+	}
+	if opening.IsPositive() {
+		if _, err := s.ledger.Post("OPEN-"+account.Id, "opening balance",
+			Debit(BankReserves, opening), Credit(CustomerAccount(account.Id), opening)); err != nil {
+			return err
+		}
+	}
+	s.accounts[account.Id] = account
+	return nil
+}
+
+func (s *AccountServiceV1) AccountIds() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]string, 0, len(s.accounts))
+	for id := range s.accounts {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
 func (s *AccountServiceV1) GetAccount(accountId string) (*Account, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -362,66 +491,127 @@ func (s *AccountServiceV1) GetAccount(accountId string) (*Account, error) {
 
 	return account, nil
 }
-func (s *AccountServiceV1) CanWithdraw(accountId string, amount float64) error {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
 
+func (s *AccountServiceV1) Balance(accountId string) (Money, error) {
+	if _, err := s.GetAccount(accountId); err != nil {
+		return Money{}, err
+	}
+	return s.ledger.Balance(CustomerAccount(accountId)).Add(s.ledger.Balance(HoldAccount(accountId))), nil
+}
+
+func (s *AccountServiceV1) Available(accountId string) (Money, error) {
+	if _, err := s.GetAccount(accountId); err != nil {
+		return Money{}, err
+	}
+	return s.ledger.Balance(CustomerAccount(accountId)), nil
+}
+
+func (s *AccountServiceV1) CanWithdraw(accountId, cardNumber, atmId string, amount Money) error {
+	if err := checkCurrency(amount, s.ledger.Currency()); err != nil {
+		return err
+	}
+	s.mu.RLock()
 	account, exists := s.accounts[accountId]
 	if !exists {
+		s.mu.RUnlock()
 		return ErrAccountNotFound
 	}
-	// Check if sufficient balance
-	if account.CurrBalance < amount {
+	balance, dailyLimit := s.ledger.Balance(CustomerAccount(accountId)), account.DailyLimit
+	s.mu.RUnlock()
+
+	// Check if sufficient balance (money on hold is not spendable)
+	if balance.LessThan(amount) {
 		return ErrInsufficientFunds
 	}
-	// Check daily limit
-	// TODO: Track today's withdrawals
-	// For now, simple check:
-	if amount > account.DailyLimit {
-		return ErrDailyLimitExceeded
+
+	// Check rolling 24h limits (see withdrawal_limits.go)
+	return s.checkLimits(accountId, cardNumber, atmId, amount, dailyLimit)
+}
+
+// BeginWithdrawal - checked alone, two withdrawals both see the history
+// without the other one and both pass; under withdrawMu the second check
+// runs after the first one's PENDING row is written and counts it
+func (s *AccountServiceV1) BeginWithdrawal(accountId, cardNumber, atmId string, amount Money) (*Transaction, error) {
+	if s.transactions == nil {
+		return nil, ErrNoTransactionService
 	}
-	return nil
+	s.withdrawMu.Lock()
+	defer s.withdrawMu.Unlock()
 
+	if err := s.CanWithdraw(accountId, cardNumber, atmId, amount); err != nil {
+		return nil, err
+	}
+	return s.transactions.BeginTransaction(accountId, cardNumber, atmId, amount, Withdraw)
 }
-func (s *AccountServiceV1) DebitAccount(accountId string, amount float64) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
 
-	account, exists := s.accounts[accountId]
-	if !exists {
+// move - amount leaves from (debited) and lands on to (credited), e.g.
+// deposit = atm-cash => customer; checks the source first, caller holds mu
+func (s *AccountServiceV1) move(accountId, ref, memo string, from LedgerAccount, amount Money, to LedgerAccount, insufficient error) error {
+	if _, exists := s.accounts[accountId]; !exists {
 		return ErrAccountNotFound
 	}
+	if err := checkCurrency(amount, s.ledger.Currency()); err != nil {
+		return err
+	}
+	if !amount.IsPositive() {
+		return ErrInvalidAmount
+	}
+	// customer-side accounts can't go below zero, the ATM / bank side can
+	if !from.isAsset() && s.ledger.Balance(from).LessThan(amount) {
+		return insufficient
+	}
+	_, err := s.ledger.Post(ref, memo, Debit(from, amount), Credit(to, amount))
+	return err
+}
+
+func (s *AccountServiceV1) DebitAccount(accountId string, amount Money, counter LedgerAccount, ref string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	// Double-check balance (defensive programming)
-	if account.CurrBalance < amount {
-		return ErrInsufficientFunds
-	}
-	// Debit the account
-	account.CurrBalance -= amount
-	return nil
+	return s.move(accountId, ref, "debit", CustomerAccount(accountId), amount, counter, ErrInsufficientFunds)
 }
-func (s *AccountServiceV1) CreditAccount(accountId string, amount float64) error {
+
+func (s *AccountServiceV1) CreditAccount(accountId string, amount Money, counter LedgerAccount, ref string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	account, exists := s.accounts[accountId]
-	if !exists {
-		return ErrAccountNotFound
-	}
+	return s.move(accountId, ref, "credit", counter, amount, CustomerAccount(accountId), ErrInsufficientFunds)
+}
 
-	// Credit the account
-	account.CurrBalance += amount
-	return nil
+func (s *AccountServiceV1) ReserveFunds(accountId string, amount Money, ref string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.move(accountId, ref, "reserve", CustomerAccount(accountId), amount, HoldAccount(accountId), ErrInsufficientFunds)
+}
+
+func (s *AccountServiceV1) ReleaseFunds(accountId string, amount Money, ref string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.move(accountId, ref, "release", HoldAccount(accountId), amount, CustomerAccount(accountId),
+		fmt.Errorf("release %v: not that much on hold", amount))
+}
+
+// CaptureFunds - can't fail for lack of money: the hold guaranteed it
+func (s *AccountServiceV1) CaptureFunds(accountId string, amount Money, counter LedgerAccount, ref string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.move(accountId, ref, "capture", HoldAccount(accountId), amount, counter,
+		fmt.Errorf("capture %v: not that much on hold", amount))
 }
 
 type Account struct {
 	Id          string
 	UserId      string
 	BankId      string
-	CurrBalance float64
 	AccountType AccountType
-	DailyLimit  float64
+	DailyLimit  Money
+	// no balance fields: AccountService.Balance / Available sum the ledger
 }
+
 type AccountType string
 
 const (
@@ -442,16 +632,33 @@ type CardService interface {
 	GetCard(cardNumber string) (*Card, error)
 	BlockCard(cardNumber string) error
 	GetAccountDetails(cardNumber string) (string, error)
+
+	// Wrong-PIN lockout + admin unblock (pin_security.go)
+	RecordPinFailure(cardNumber, atmId string) (attemptsLeft int, err error)
+	ResetPinFailures(cardNumber string)
+	UnblockCard(cardNumber, adminId, reason string) error
 }
 
 type CardServiceV1 struct {
 	Cards map[string]*Card //(cardNumber, card)
 	mu    sync.RWMutex
+
+	lockout     PinLockoutPolicy
+	pinFailures map[string][]time.Time // cardNumber -> recent wrong PINs
+	audit       AuditLog
+	Now         func() time.Time // injectable clock
 }
 
-func NewCardServiceV1() (*CardServiceV1, error) {
+func NewCardServiceV1(lockout PinLockoutPolicy, audit AuditLog) (*CardServiceV1, error) {
+	if audit == nil {
+		audit = NewAuditLogV1()
+	}
 	return &CardServiceV1{
-		Cards: make(map[string]*Card),
+		Cards:       make(map[string]*Card),
+		lockout:     lockout,
+		pinFailures: make(map[string][]time.Time),
+		audit:       audit,
+		Now:         time.Now,
 	}, nil
 }
 
@@ -524,9 +731,20 @@ type Card struct {
 }
 
 type TransactionService interface {
-	CreateTransaction(accountId, atmId string, amount float64, txnType TransactionType) (*Transaction, error)
+	CreateTransaction(accountId, cardNumber, atmId string, amount Money, txnType TransactionType) (*Transaction, error)
+	// BeginTransaction - same as CreateTransaction but PENDING, saga
+	// moves it on with RecordStep / UpdateStatus
+	BeginTransaction(accountId, cardNumber, atmId string, amount Money, txnType TransactionType) (*Transaction, error)
+	RecordStep(transactionId, step string) error
+	UpdateStatus(transactionId string, status TransactionStatus, reason string) error
 	GetTransaction(transactionId string) (*Transaction, error)
 	GetTransactionHistory(accountId string) ([]*Transaction, error)
+	// GetATMTransactions - everything done on one ATM, oldest first
+	GetATMTransactions(atmId string) ([]*Transaction, error)
+	// RecordNotes - notes a withdrawal took out of the cassettes
+	RecordNotes(transactionId string, notes map[float64]int) error
+	CreateTransfer(fromAccountId, toAccountId, cardNumber, atmId string, amount Money) (*Transaction, error)
+	LastTransactions(accountId string, n int, types ...TransactionType) ([]Transaction, error)
 }
 
 type TransactionServiceV1 struct {
@@ -541,20 +759,30 @@ func NewTransactionServiceV1() (*TransactionServiceV1, error) {
 		accountTransactions: make(map[string][]*Transaction),
 	}, nil
 }
-func (s *TransactionServiceV1) CreateTransaction(accountId, atmId string, amount float64, txnType TransactionType) (*Transaction, error) {
+func (s *TransactionServiceV1) CreateTransaction(accountId, cardNumber, atmId string, amount Money, txnType TransactionType) (*Transaction, error) {
+	return s.insert(accountId, cardNumber, atmId, amount, txnType, Completed) // Assume success at creation
+}
+
+func (s *TransactionServiceV1) BeginTransaction(accountId, cardNumber, atmId string, amount Money, txnType TransactionType) (*Transaction, error) {
+	return s.insert(accountId, cardNumber, atmId, amount, txnType, Pending)
+}
+
+func (s *TransactionServiceV1) insert(accountId, cardNumber, atmId string, amount Money, txnType TransactionType, status TransactionStatus) (*Transaction, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	txnId := generateTransactionId()
 
 	transaction := &Transaction{
-		Id:        txnId,
-		AccountId: accountId,
-		Amount:    amount,
-		Type:      txnType,
-		Status:    Success, // Assume success at creation
-		ATMId:     atmId,
-		CreatedAt: time.Now(),
+		Id:         txnId,
+		AccountId:  accountId,
+		CardNumber: cardNumber,
+		Amount:     amount,
+		Type:       txnType,
+		Status:     status,
+		ATMId:      atmId,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
 	}
 	// Store transaction //kinda inserting in db table
 	s.totalTransactions[txnId] = transaction
@@ -563,6 +791,34 @@ func (s *TransactionServiceV1) CreateTransaction(accountId, atmId string, amount
 	return transaction, nil
 }
 
+// RecordStep - last completed saga step, written after every step
+func (s *TransactionServiceV1) RecordStep(transactionId, step string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transaction, ok := s.totalTransactions[transactionId]
+	if !ok {
+		return ErrTransactionDoesNotExist
+	}
+	transaction.Step = step
+	transaction.UpdatedAt = time.Now()
+	return nil
+}
+
+func (s *TransactionServiceV1) UpdateStatus(transactionId string, status TransactionStatus, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transaction, ok := s.totalTransactions[transactionId]
+	if !ok {
+		return ErrTransactionDoesNotExist
+	}
+	transaction.Status = status
+	transaction.Reason = reason
+	transaction.UpdatedAt = time.Now()
+	return nil
+}
+
 func (s *TransactionServiceV1) GetTransaction(transactionId string) (*Transaction, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -574,7 +830,38 @@ func (s *TransactionServiceV1) GetTransaction(transactionId string) (*Transactio
 	return transaction, nil
 }
 
+func (s *TransactionServiceV1) GetATMTransactions(atmId string) ([]*Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var transactions []*Transaction
+	for _, transaction := range s.totalTransactions {
+		if transaction.ATMId == atmId {
+			transactions = append(transactions, transaction)
+		}
+	}
+	sort.Slice(transactions, func(i, j int) bool {
+		return transactions[i].CreatedAt.Before(transactions[j].CreatedAt)
+	})
+	return transactions, nil
+}
+
+func (s *TransactionServiceV1) RecordNotes(transactionId string, notes map[float64]int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transaction, ok := s.totalTransactions[transactionId]
+	if !ok {
+		return ErrTransactionDoesNotExist
+	}
+	transaction.Notes = notes
+	transaction.UpdatedAt = time.Now()
+	return nil
+}
+
 func (s *TransactionServiceV1) GetTransactionHistory(accountId string) ([]*Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
 	transactions, ok := s.accountTransactions[accountId]
 	if !ok {
@@ -585,13 +872,20 @@ func (s *TransactionServiceV1) GetTransactionHistory(accountId string) ([]*Trans
 }
 
 type Transaction struct {
-	Id        string
-	AccountId string //1 account can have many transaction
-	Amount    float64
-	Type      TransactionType
-	Status    TransactionStatus
-	ATMId     string
-	CreatedAt time.Time
+	Id         string
+	AccountId  string //1 account can have many transaction
+	CardNumber string //account can have several cards (add-on cards), limits are per card too
+	Amount     Money
+	Type       TransactionType
+	Status     TransactionStatus
+	Step       string          // last completed saga step (withdraw / deposit)
+	Reason     string          // why it was REVERSED / is stuck PENDING
+	Notes      map[float64]int // withdrawals: notes dispensed, for cash forecasting
+	ATMId      string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+
+	Counterparty string // other account of a transfer
 }
 type TransactionType string
 
@@ -599,24 +893,31 @@ const (
 	Withdraw       TransactionType = "WITHDRAW"
 	Deposit        TransactionType = "DEPOSIT"
 	BalanceInquiry TransactionType = "BALANCE_INQUIRY"
+	TransferOut    TransactionType = "TRANSFER_OUT"
+	TransferIn     TransactionType = "TRANSFER_IN"
+	MiniStatement  TransactionType = "MINI_STATEMENT"
+	PinChange      TransactionType = "PIN_CHANGE"
 )
 
 type TransactionStatus string
 
 const (
-	Success TransactionStatus = "SUCCESS"
-	Failed  TransactionStatus = "FAILED"
-	Pending TransactionStatus = "PENDING"
+	Pending   TransactionStatus = "PENDING"   // saga running, or stuck => reconciliation
+	Completed TransactionStatus = "COMPLETED" // every step done
+	Reversed  TransactionStatus = "REVERSED"  // a step failed, earlier steps undone
+	Failed    TransactionStatus = "FAILED"
 )
 
 type ReceiptService interface {
 	GenerateReceipt(transactionId string) (*Receipt, error)
+	GenerateMiniStatement(transactionId string, transactions []Transaction) (*Receipt, error)
 }
 
 type ReceiptServiceV1 struct {
 	TransactionService TransactionService
 	AccountService     AccountService
 	Receipts           map[string]*Receipt //reciept_id, receipt object
+	mu                 sync.Mutex          // one ATM can finish several withdrawals at once
 }
 
 func NewReceiptServiceV1(txnServ TransactionService, acctServ AccountService) (*ReceiptServiceV1, error) {
@@ -633,8 +934,8 @@ func (s *ReceiptServiceV1) GenerateReceipt(transactionId string) (*Receipt, erro
 	if err != nil {
 		return nil, err
 	}
-	// 2. Get account details (to get balance)
-	account, err := s.AccountService.GetAccount(transaction.AccountId)
+	// 2. Balance from the ledger
+	balance, err := s.AccountService.Balance(transaction.AccountId)
 	if err != nil {
 		return nil, err
 	}
@@ -642,34 +943,41 @@ func (s *ReceiptServiceV1) GenerateReceipt(transactionId string) (*Receipt, erro
 	receipt := &Receipt{
 		TransactionId: transactionId,
 		Amount:        transaction.Amount,
-		Balance:       account.CurrBalance, // Balance AFTER transaction
+		Balance:       balance, // Balance AFTER transaction
 		CreatedAt:     time.Now(),
-		Summary:       fmt.Sprintf("%s of %.2f completed", transaction.Type, transaction.Amount),
+		Summary:       receiptSummary(transaction),
 	}
 	// 4. Store receipt (optional)
+	s.mu.Lock()
 	s.Receipts[transactionId] = receipt
+	s.mu.Unlock()
 
 	return receipt, nil
 }
 
 type Receipt struct {
 	TransactionId string
-	Amount        float64
-	Balance       float64
+	Amount        Money
+	Balance       Money
 	CreatedAt     time.Time
 	Summary       string
+	Lines         []string // mini statement rows
 }
 
 // State pattern
 type OperationType string
 
 const (
-	OpWithdraw OperationType = "WITHDRAW"
-	OpDeposit  OperationType = "DEPOSIT"
-	OpBalance  OperationType = "BALANCE"
+	OpWithdraw      OperationType = "WITHDRAW"
+	OpDeposit       OperationType = "DEPOSIT"
+	OpBalance       OperationType = "BALANCE"
+	OpTransfer      OperationType = "TRANSFER"
+	OpMiniStatement OperationType = "MINI_STATEMENT"
+	OpChangePIN     OperationType = "CHANGE_PIN"
 )
 
 type ATMState interface {
+	Name() StateName
 	InsertCard(ctx *ATMController, card string) error
 	EnterPIN(ctx *ATMController, pin string) error
 	SelectOperation(ctx *ATMController, op OperationType) error
@@ -677,59 +985,83 @@ type ATMState interface {
 	Execute(ctx *ATMController) error
 	Cancel(ctx *ATMController) error
 	EnterDenominations(ctx *ATMController, denominations map[float64]int) error
+	EnterPayee(ctx *ATMController, payeeAccountId string) error
 }
 
 type ATMController struct {
-	currentState ATMState
+	machine      *atmMachine            // transition table: state_machine.go
+	states       map[StateName]ATMState // what each state does with input
+	lastActivity time.Time              // last input / transition, timeouts count from here
 
-	//session data
+	//session data (never the PIN: Authenticate swaps it for a Session)
 	cardNumber    string
-	pin           string
+	session       *Session
 	operation     OperationType
-	amount        float64
+	amount        Money
 	denominations map[float64]int
+	payee         *Payee
 
 	// Your existing service
 	atmService ATMService
+
+	// Inactivity timeouts (session_timeout.go)
+	Now           func() time.Time // injectable clock
+	Timeouts      map[StateName]StateTimeout
+	Journal       Journal
+	retainedCards []string
+	retainCard    bool // guard of TIMEOUT => CARD_RETAINED, set by expire
+	mu            sync.Mutex
 }
 
 func NewATMController(atmService ATMService) *ATMController {
-	return &ATMController{
-		currentState: &IdleState{},
+	ctx := &ATMController{
+		states:       newStateHandlers(),
+		lastActivity: time.Now(),
 		atmService:   atmService,
+		Now:          time.Now,
+		Timeouts:     DefaultStateTimeouts(),
+		Journal:      NewJournalV1(),
 	}
+	ctx.machine = atmStates.NewMachine(ctx)
+	ctx.machine.Now = func() time.Time { return ctx.Now() }
+	return ctx
 }
 func (ctx *ATMController) InsertCard(cardNumber string) error {
-	return ctx.currentState.InsertCard(ctx, cardNumber)
+	return ctx.handle(func() error { return ctx.currentState().InsertCard(ctx, cardNumber) })
 }
 
 func (ctx *ATMController) EnterPIN(pin string) error {
-	return ctx.currentState.EnterPIN(ctx, pin)
+	return ctx.handle(func() error { return ctx.currentState().EnterPIN(ctx, pin) })
 }
 
 func (ctx *ATMController) SelectOperation(op OperationType) error {
-	return ctx.currentState.SelectOperation(ctx, op)
+	return ctx.handle(func() error { return ctx.currentState().SelectOperation(ctx, op) })
 }
 func (ctx *ATMController) EnterAmount(amount float64) error {
-	return ctx.currentState.EnterAmount(ctx, amount)
+	return ctx.handle(func() error { return ctx.currentState().EnterAmount(ctx, amount) })
 }
 
 func (ctx *ATMController) Execute() error {
-	return ctx.currentState.Execute(ctx)
+	return ctx.handle(func() error { return ctx.currentState().Execute(ctx) })
 }
 func (ctx *ATMController) Cancel() error {
-	return ctx.currentState.Cancel(ctx)
+	return ctx.handle(func() error { return ctx.currentState().Cancel(ctx) })
 }
 func (ctx *ATMController) EnterDenominations(denominations map[float64]int) error {
-	return ctx.currentState.EnterDenominations(ctx, denominations)
+	return ctx.handle(func() error { return ctx.currentState().EnterDenominations(ctx, denominations) })
+}
+func (ctx *ATMController) EnterPayee(payeeAccountId string) error {
+	return ctx.handle(func() error { return ctx.currentState().EnterPayee(ctx, payeeAccountId) })
 }
 
 func (ctx *ATMController) reset() {
+	ctx.atmService.EndSession(ctx.session)
 	ctx.cardNumber = ""
-	ctx.pin = ""
+	ctx.session = nil
 	ctx.operation = ""
-	ctx.amount = 0
+	ctx.amount = Money{}
 	ctx.denominations = nil
+	ctx.payee = nil
 }
 
 // Step 4: Base State (Default Implementations)
@@ -759,12 +1091,14 @@ func (s *BaseATMState) EnterDenominations(ctx *ATMController, denominations map[
 	return errors.New("❌ cannot enter denominations in this state")
 }
 
+func (s *BaseATMState) EnterPayee(ctx *ATMController, payeeAccountId string) error {
+	return errors.New("❌ cannot enter payee in this state")
+}
+
 func (s *BaseATMState) Cancel(ctx *ATMController) error {
 	fmt.Println("❌ Transaction cancelled")
 	fmt.Println("💳 Card ejected")
-	ctx.reset()
-	ctx.currentState = &IdleState{}
-	return nil
+	return ctx.fire(EventCancel)
 }
 
 //Concrete states
@@ -774,10 +1108,14 @@ type IdleState struct {
 	BaseATMState
 }
 
+func (s *IdleState) Name() StateName { return StateIdle }
+
 func (s *IdleState) InsertCard(ctx *ATMController, cardNumber string) error {
 	fmt.Println("Card inserted:", cardNumber)
 	ctx.cardNumber = cardNumber
-	ctx.currentState = &CardInsertState{}
+	if err := ctx.fire(EventInsertCard); err != nil {
+		return err
+	}
 	fmt.Println("📌 Please enter your PIN")
 	return nil
 }
@@ -791,15 +1129,35 @@ type CardInsertState struct {
 	BaseATMState
 }
 
+func (s *CardInsertState) Name() StateName { return StateCardInserted }
+
 func (s *CardInsertState) EnterPIN(ctx *ATMController, pin string) error {
 	fmt.Println("🔐 Validating PIN...")
-	ctx.pin = pin
-	ctx.currentState = &PINValidatedState{}
+	session, err := ctx.atmService.Authenticate(ctx.cardNumber, pin)
+	if errors.Is(err, ErrInvalidPIN) {
+		fmt.Println("❌", err)
+		fmt.Println("📌 Please enter your PIN")
+		return err
+	}
+	if err != nil {
+		// locked / blocked / expired card => no more tries on this machine
+		fmt.Println("❌", err)
+		fmt.Println("💳 Card ejected")
+		ctx.fire(EventCardRefused)
+		return err
+	}
+	ctx.session = session
+	if err := ctx.fire(EventPINAccepted); err != nil {
+		return err
+	}
 	fmt.Println("✅ PIN validated!")
 	fmt.Println("📌 Select operation:")
 	fmt.Println("   1. Withdraw")
 	fmt.Println("   2. Deposit")
 	fmt.Println("   3. Balance Inquiry")
+	fmt.Println("   4. Transfer")
+	fmt.Println("   5. Mini Statement")
+	fmt.Println("   6. Change PIN")
 	return nil
 }
 
@@ -808,17 +1166,27 @@ type PINValidatedState struct {
 	BaseATMState
 }
 
+func (s *PINValidatedState) Name() StateName { return StatePINValidated }
+
 func (s *PINValidatedState) SelectOperation(ctx *ATMController, op OperationType) error {
-	fmt.Printf("✅ Operation selected: %s\n", op)
+	// the operation is the guard input: the table picks the next state
 	ctx.operation = op
-	if op == OpDeposit {
-		ctx.currentState = &DenomiantionAndAmountEntryState{}
+	if err := ctx.fire(EventSelectOperation); err != nil {
+		ctx.operation = ""
+		fmt.Printf("❌ %v: %s\n", ErrUnknownOperation, op)
+		return fmt.Errorf("%w %q: %w", ErrUnknownOperation, op, err)
+	}
+	fmt.Printf("✅ Operation selected: %s\n", op)
+	switch ctx.currentState().Name() {
+	case StateDenominationEntry:
 		fmt.Println("Please insert cash into the deposit slot")
-	} else if op == OpBalance {
-		ctx.currentState = &ReadyToExecuteState{}
+	case StateReadyToExecute:
 		fmt.Println("📌 Press Execute to confirm")
-	} else {
-		ctx.currentState = &AmountEntryState{}
+	case StatePayeeEntry:
+		fmt.Println("📌 Enter payee account number:")
+	case StateChangePIN:
+		fmt.Println("📌 Enter current PIN:")
+	default:
 		fmt.Println("📌 Enter amount:")
 	}
 	return nil
@@ -828,22 +1196,23 @@ type DenomiantionAndAmountEntryState struct {
 	BaseATMState
 }
 
+func (s *DenomiantionAndAmountEntryState) Name() StateName { return StateDenominationEntry }
+
 // assuming we get the denominations map from ATM's hardware
 // which is actually counting the notes of each type,
 // preparing this map and sending it to our code
 func (s *DenomiantionAndAmountEntryState) EnterDenominations(ctx *ATMController, denominations map[float64]int) error {
-	total := 0.0
-	for denom, count := range denominations {
-		total += denom * float64(count)
-	}
+	total := notesTotal(denominations)
 
 	ctx.amount = total
 	ctx.denominations = denominations
-	ctx.currentState = &ReadyToExecuteState{}
-	fmt.Printf("✅ Cash counted: ₹%.2f\n", total)
+	if err := ctx.fire(EventCashCounted); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Cash counted: %v\n", total)
 	fmt.Println("   Denominations detected:")
 	for denom, count := range denominations {
-		fmt.Printf("   ₹%.0f × %d = ₹%.0f\n", denom, count, denom*float64(count))
+		fmt.Printf("   ₹%.0f × %d = %v\n", denom, count, NoteValue(denom, count))
 	}
 	fmt.Println("📌 Press Execute to confirm deposit")
 
@@ -855,11 +1224,23 @@ type AmountEntryState struct {
 	BaseATMState
 }
 
+func (s *AmountEntryState) Name() StateName { return StateAmountEntry }
+
+// keypad input is the only float left: converted once, here
 func (s *AmountEntryState) EnterAmount(ctx *ATMController, amount float64) error {
-	fmt.Printf("✅ Amount entered: ₹%.2f\n", amount)
-	ctx.amount = amount
-	ctx.currentState = &ReadyToExecuteState{}
-	fmt.Printf("📌 Confirm %s of ₹%.2f? Press Execute\n", ctx.operation, ctx.amount)
+	money, err := ParseRupees(amount)
+	if err != nil {
+		return err
+	}
+	if !money.IsPositive() {
+		return ErrInvalidAmount
+	}
+	fmt.Printf("✅ Amount entered: %v\n", money)
+	ctx.amount = money
+	if err := ctx.fire(EventAmountEntered); err != nil {
+		return err
+	}
+	fmt.Printf("📌 Confirm %s of %v? Press Execute\n", ctx.operation, ctx.amount)
 	return nil
 }
 
@@ -868,6 +1249,8 @@ type ReadyToExecuteState struct {
 	BaseATMState
 }
 
+func (s *ReadyToExecuteState) Name() StateName { return StateReadyToExecute }
+
 func (s *ReadyToExecuteState) Execute(ctx *ATMController) error {
 	fmt.Println("⏳ Processing transaction...")
 
@@ -875,37 +1258,49 @@ func (s *ReadyToExecuteState) Execute(ctx *ATMController) error {
 
 	switch ctx.operation {
 	case OpWithdraw:
-		err = ctx.atmService.Withdraw(ctx.cardNumber, ctx.pin, ctx.amount)
+		err = ctx.atmService.Withdraw(ctx.session, ctx.amount)
 		if err == nil {
-			fmt.Printf("✅ Withdrawal successful! ₹%.2f dispensed\n", ctx.amount)
+			fmt.Printf("✅ Withdrawal successful! %v dispensed\n", ctx.amount)
 		}
 
 	case OpDeposit:
-		err = ctx.atmService.Deposit(ctx.cardNumber, ctx.pin, ctx.amount, ctx.denominations)
+		err = ctx.atmService.Deposit(ctx.session, ctx.amount, ctx.denominations)
 		if err == nil {
-			fmt.Printf("✅ Deposit successful! ₹%.2f deposited\n", ctx.amount)
+			fmt.Printf("✅ Deposit successful! %v deposited\n", ctx.amount)
 		}
 
 	case OpBalance:
-		balance, balErr := ctx.atmService.CheckBalance(ctx.cardNumber, ctx.pin)
+		balance, balErr := ctx.atmService.CheckBalance(ctx.session)
 		if balErr != nil {
 			err = balErr
 		} else {
-			fmt.Printf("✅ Your current balance: ₹%.2f\n", balance)
+			fmt.Printf("✅ Your current balance: %v\n", balance)
+		}
+
+	case OpTransfer:
+		var receipt *Receipt
+		receipt, err = ctx.atmService.Transfer(ctx.session, ctx.payee.AccountId, ctx.amount)
+		if err == nil {
+			fmt.Printf("✅ Transfer successful! %v sent to %s\n", ctx.amount, ctx.payee.Masked)
+			printReceipt(receipt)
+		}
+
+	case OpMiniStatement:
+		var receipt *Receipt
+		_, receipt, err = ctx.atmService.MiniStatement(ctx.session, miniStatementSize)
+		if err == nil {
+			printReceipt(receipt)
 		}
 	}
 
 	if err != nil {
 		fmt.Println("❌ Transaction failed:", err)
-		ctx.reset()
-		ctx.currentState = &IdleState{}
+		ctx.fire(EventDone)
 		return err
 	}
 
 	fmt.Println("💳 Please take your card")
-	ctx.reset()
-	ctx.currentState = &IdleState{}
-	return nil
+	return ctx.fire(EventDone)
 }
 
 // Helper function to generate transaction ID
@@ -926,10 +1321,12 @@ func main() {
 	// ============================================
 	// SETUP: Initialize All Services
 	// ============================================
-	bankServ := &BankServiceV1{}
-	cardServ, _ := NewCardServiceV1()
-	accountServ, _ := NewAccountServiceV1()
+	audit := NewAuditLogV1()
+	bankServ := NewBankServiceV1()
+	cardServ, _ := NewCardServiceV1(DefaultPinLockoutPolicy(), audit)
 	transactionServ, _ := NewTransactionServiceV1()
+	ledger := NewLedger(INR)
+	accountServ, _ := NewAccountServiceV1(transactionServ, ledger, DefaultWithdrawalLimits())
 	receiptServ, _ := NewReceiptServiceV1(transactionServ, accountServ)
 
 	// Create ATM with cash inventory
@@ -944,19 +1341,24 @@ func main() {
 		BankId:   "BANK-001",
 		Location: &Location{City: "Hyderabad", Street: "Lanco Hills", Pincode: "500089"},
 	}
-	dispenserServ, _ := NewCashDispenserV1(12000, cashInv)
+	dispenserServ, _ := NewCashDispenserV1(cashInv)
+	ledger.RecordCashLoad("LOAD-"+atm.Id, atm.Id, dispenserServ.GetCurrentBalance())
 	atmServ, _ := NewATMServiceV1(atm.Id, transactionServ, bankServ, accountServ, cardServ, receiptServ, dispenserServ)
+	atmServ.Audit = audit
 
 	// Create test data
 	testAccount := &Account{
 		Id:          "ACC-001",
 		UserId:      "USER-001",
 		BankId:      "BANK-001",
-		CurrBalance: 50000,
 		AccountType: Savings,
-		DailyLimit:  20000,
+		DailyLimit:  Rupees(20000),
+	}
+	accountServ.OpenAccount(testAccount, Rupees(50000))
+	balance := func() Money {
+		b, _ := accountServ.Balance(testAccount.Id)
+		return b
 	}
-	accountServ.accounts["ACC-001"] = testAccount
 
 	testCard := &Card{
 		CardNumber: "CARD-001",
@@ -967,45 +1369,60 @@ func main() {
 		Status:     Active,
 	}
 	cardServ.Cards["CARD-001"] = testCard
+	bankServ.SetPin("CARD-001", "1234") // issuance: bank keeps the hash only
 
 	fmt.Println("\n✅ System Initialized")
 	fmt.Printf("   💳 Card: %s (John Doe)\n", testCard.CardNumber)
 	fmt.Printf("   🏦 Account: %s\n", testAccount.Id)
-	fmt.Printf("   💰 Initial Balance: ₹%.2f\n", testAccount.CurrBalance)
+	fmt.Printf("   💰 Initial Balance: %v\n", balance())
 
 	// ============================================
 	// PART 1: Direct Service Calls (Backend API)
 	// ============================================
 	printSectionHeader("PART 1: Direct Service Calls (Backend API Style)")
 
+	// Card + PIN once, the session is used for every call below
+	session, err := atmServ.Authenticate("CARD-001", "1234")
+	if err != nil {
+		fmt.Println("   ❌ Authentication failed:", err)
+		return
+	}
+
 	// Test 1: Deposit
 	fmt.Println("📥 Test 1: Deposit ₹6,000")
 	depositDenom := map[float64]int{500: 2, 200: 10, 100: 30}
-	if err := atmServ.Deposit("CARD-001", "1234", 6000, depositDenom); err != nil {
+	if err := atmServ.Deposit(session, Rupees(6000), depositDenom); err != nil {
 		fmt.Println("   ❌ Error:", err)
 	} else {
-		fmt.Printf("   ✅ Success! Balance: ₹%.2f\n", testAccount.CurrBalance)
+		fmt.Printf("   ✅ Success! Balance: %v\n", balance())
 	}
 	fmt.Println()
 
 	// Test 2: Withdraw
 	fmt.Println("📤 Test 2: Withdraw ₹5,000")
-	if err := atmServ.Withdraw("CARD-001", "1234", 5000); err != nil {
+	if err := atmServ.Withdraw(session, Rupees(5000)); err != nil {
 		fmt.Println("   ❌ Error:", err)
 	} else {
-		fmt.Printf("   ✅ Success! Balance: ₹%.2f\n", testAccount.CurrBalance)
+		fmt.Printf("   ✅ Success! Balance: %v\n", balance())
 	}
 	fmt.Println()
 
 	// Test 3: Check Balance
 	fmt.Println("💵 Test 3: Check Balance")
-	if balance, err := atmServ.CheckBalance("CARD-001", "1234"); err != nil {
+	if balance, err := atmServ.CheckBalance(session); err != nil {
 		fmt.Println("   ❌ Error:", err)
 	} else {
-		fmt.Printf("   ✅ Current Balance: ₹%.2f\n", balance)
+		fmt.Printf("   ✅ Current Balance: %v\n", balance)
 	}
 	fmt.Println()
 
+	fmt.Println("💱 Test 3b: Amounts in another currency are refused, not a panic")
+	dollar := Money{Amount: 100, Currency: "USD"}
+	fmt.Println("   ✅ Withdraw:", atmServ.Withdraw(session, dollar))
+	fmt.Println("   ✅ DebitAccount:", accountServ.DebitAccount("ACC-001", dollar, ATMCashAccount("ATM-001"), "TXN-USD"))
+	atmServ.EndSession(session)
+	fmt.Println()
+
 	// Test 4: Transaction History
 	fmt.Println("📜 Test 4: Transaction History")
 	if txns, err := transactionServ.GetTransactionHistory("ACC-001"); err != nil {
@@ -1013,11 +1430,39 @@ func main() {
 	} else {
 		fmt.Printf("   ✅ Total Transactions: %d\n", len(txns))
 		for i, txn := range txns {
-			fmt.Printf("      %d. %s | ₹%.2f | %s\n", i+1, txn.Type, txn.Amount, txn.Status)
+			fmt.Printf("      %d. %s | %v | %s\n", i+1, txn.Type, txn.Amount, txn.Status)
 		}
 	}
 	fmt.Println()
 
+	// Test 5: Rolling limits - several withdrawals in a row can't
+	// sneak past the daily limit any more
+	fmt.Println("🚦 Test 5: Withdrawal limits (account limit ₹10,000, max 3 per 10 min)")
+	accountServ.OpenAccount(&Account{
+		Id: "ACC-002", UserId: "USER-002", BankId: "BANK-001",
+		AccountType: Savings, DailyLimit: Rupees(10000),
+	}, Rupees(50000))
+	cardServ.Cards["CARD-002"] = &Card{
+		CardNumber: "CARD-002", UserId: "USER-002", AccountId: "ACC-002",
+		Name: "Jane Roe", ExpiryDate: time.Now().AddDate(2, 0, 0), Status: Active,
+	}
+	bankServ.SetPin("CARD-002", "1234")
+	session2, _ := atmServ.Authenticate("CARD-002", "1234")
+	for _, rupees := range []int64{4000, 4000, 3000, 1000, 500, 500} {
+		amount := Rupees(rupees)
+		if err := atmServ.Withdraw(session2, amount); err != nil {
+			fmt.Printf("   ❌ %v: %v\n", amount, err)
+		} else {
+			fmt.Printf("   ✅ %v dispensed\n", amount)
+		}
+	}
+	fmt.Println()
+
+	// Test 6: same limits, withdrawals racing each other / long windows
+	fmt.Println("🚦 Test 6: Concurrent withdrawals & velocity windows over 24h")
+	testConcurrentLimits()
+	fmt.Println()
+
 	// ============================================
 	// PART 2: State Pattern (Physical ATM)
 	// ============================================
@@ -1050,7 +1495,7 @@ func main() {
 
 	fmt.Println("▶️  Step 5: Execute Transaction")
 	atmController.Execute()
-	fmt.Printf("   📊 Balance After: ₹%.2f\n\n", testAccount.CurrBalance)
+	fmt.Printf("   📊 Balance After: %v\n\n", balance())
 
 	// ==========================================
 	// Scenario 2: Deposit
@@ -1082,7 +1527,7 @@ func main() {
 
 	fmt.Println("▶️  Step 5: Execute Transaction")
 	atmController2.Execute()
-	fmt.Printf("   📊 Balance After: ₹%.2f\n\n", testAccount.CurrBalance)
+	fmt.Printf("   📊 Balance After: %v\n\n", balance())
 
 	// ==========================================
 	// Scenario 3: Balance Inquiry
@@ -1152,31 +1597,102 @@ func main() {
 	atmController7.Cancel()
 	fmt.Println()
 
+	// ============================================
+	// PART 3: Saga Fault Injection
+	// ============================================
+	printSectionHeader("PART 3: Withdraw / Deposit Sagas - Fault Injection")
+	testSagaFaults()
+	fmt.Println()
+
+	// ============================================
+	// PART 4: Note Mix
+	// ============================================
+	printSectionHeader("PART 4: Note Mix - DP Dispenser")
+	testNoteMix()
+	fmt.Println()
+
+	// ============================================
+	// PART 5: PIN Lockout & Audit
+	// ============================================
+	printSectionHeader("PART 5: PIN Lockout, Hashed PINs & Audit")
+	testPinSecurity()
+	fmt.Println()
+
+	// ============================================
+	// PART 6: Session Timeouts
+	// ============================================
+	printSectionHeader("PART 6: Session Timeouts & Card Retention")
+	testSessionTimeouts(atmServ)
+	fmt.Println()
+
+	// ============================================
+	// PART 7: Transfer, Mini Statement, Change PIN
+	// ============================================
+	printSectionHeader("PART 7: Transfer, Mini Statement & Change PIN")
+	testBranchOperations()
+	fmt.Println()
+
+	// ============================================
+	// PART 8: Ledger & Reconciliation
+	// ============================================
+	printSectionHeader("PART 8: Double-Entry Ledger & Reconciliation")
+	testLedger(ledger, transactionServ, accountServ, map[string]CountedCash{atm.Id: dispenserServ})
+	fmt.Println()
+
+	// ============================================
+	// PART 9: ISO 8583 Host Protocol
+	// ============================================
+	printSectionHeader("PART 9: ISO 8583 Host Protocol & Reversals")
+	testHostProtocol()
+	fmt.Println()
+
+	// ============================================
+	// PART 10: Fleet Monitoring
+	// ============================================
+	printSectionHeader("PART 10: Fleet Monitoring, Forecast & Replenishment")
+	testFleet()
+	fmt.Println()
+
+	// ============================================
+	// PART 11: Declarative State Machine
+	// ============================================
+	printSectionHeader("PART 11: Transition Table, Guards, History & DOT")
+	testStateMachine(atmServ)
+	fmt.Println()
+
 	// ==========================================
 	// Final Summary
 	// ==========================================
 	printSectionHeader("FINAL SUMMARY")
 
-	fmt.Printf("💰 Final Account Balance: ₹%.2f\n", testAccount.CurrBalance)
+	fmt.Printf("💰 Final Account Balance: %v\n", balance())
 
 	if txns, _ := transactionServ.GetTransactionHistory("ACC-001"); txns != nil {
 		fmt.Printf("📊 Total Transactions Processed: %d\n", len(txns))
 
-		var totalWithdrawn, totalDeposited float64
+		totalWithdrawn, totalDeposited := Paise(0), Paise(0)
 		for _, txn := range txns {
+			if txn.Status != Completed {
+				continue
+			}
 			if txn.Type == Withdraw {
-				totalWithdrawn += txn.Amount
+				totalWithdrawn = totalWithdrawn.Add(txn.Amount)
 			} else if txn.Type == Deposit {
-				totalDeposited += txn.Amount
+				totalDeposited = totalDeposited.Add(txn.Amount)
 			}
 		}
-		fmt.Printf("📤 Total Withdrawn: ₹%.2f\n", totalWithdrawn)
-		fmt.Printf("📥 Total Deposited: ₹%.2f\n", totalDeposited)
+		fmt.Printf("📤 Total Withdrawn: %v\n", totalWithdrawn)
+		fmt.Printf("📥 Total Deposited: %v\n", totalDeposited)
 	}
 
 	fmt.Println("\n" + strings.Repeat("═", 62))
 	fmt.Println("✅ ATM SYSTEM DEMONSTRATION COMPLETE")
 	fmt.Println(strings.Repeat("═", 62))
+
+	if checkFailures > 0 {
+		fmt.Printf("❌ %d demo checks failed\n", checkFailures)
+		os.Exit(1)
+	}
 }
 
 // ==========================================
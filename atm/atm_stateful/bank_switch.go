@@ -0,0 +1,745 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================
+// BANK SWITCH SIMULATOR + HOST BANK SERVICE
+// HostBankService is a BankService that speaks ISO 8583 (iso8583.go)
+// over TCP; BankSwitch is the host on the other end, backed by its own
+// BankServiceV1 (PIN hashes) and AccountServiceV1 (ledger).
+//
+//	ATM                          switch
+//	0200 withdraw ₹2,000   ──▶  debit, answer slowly...
+//	   (no answer in Timeout)
+//	0420 reversal          ──▶  undo the debit (same ref => nets to 0)
+//	                       ◀──  0430
+//
+// A timed-out 0200 may or may not have been applied, so the ATM always
+// reverses it. Reversals the host doesn't acknowledge with 00 (undone) or
+// 25 (never saw the original) are kept and sent again before the next
+// request (store-and-forward). A saga undoing an approved 0200 sends an
+// 0420 for that STAN too (Reverse), not a new 0200 the other way. The switch is
+// idempotent: a repeated 0200 gets the first answer, a repeated 0420 is
+// approved again, and an 0200 arriving after its own reversal is refused.
+// ============================================
+
+var (
+	ErrHostTimeout     = errors.New("bank host did not answer in time")
+	ErrHostUnavailable = errors.New("bank host unavailable")
+	ErrHostDeclined    = errors.New("declined by bank host")
+)
+
+// responseMTI - 0100 => 0110, 0200 => 0210, 0420 => 0430
+func responseMTI(mti string) string {
+	if len(mti) != 4 {
+		return mti
+	}
+	return mti[:2] + string(mti[2]+1) + mti[3:]
+}
+
+// ============================================
+// SWITCH (host side)
+// ============================================
+
+// switchRecord - one 0200 as the host saw it, keyed by terminal + MTI +
+// STAN + transmission time (what field 90 of a reversal points at)
+type switchRecord struct {
+	proc      string
+	accountId string
+	amount    Money
+	rc        string
+	original  bool // the 0200 itself arrived (false = reversal came first)
+	reversed  bool
+}
+
+type BankSwitch struct {
+	Bank     *BankServiceV1
+	Accounts *AccountServiceV1
+
+	delay    func(req *ISOMessage) time.Duration // simulated slow host
+	records  map[string]*switchRecord
+	authSeq  int
+	listener net.Listener
+	conns    map[net.Conn]bool
+	done     chan struct{}
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+}
+
+func NewBankSwitch(bank *BankServiceV1, accounts *AccountServiceV1) *BankSwitch {
+	return &BankSwitch{
+		Bank:     bank,
+		Accounts: accounts,
+		records:  make(map[string]*switchRecord),
+		conns:    make(map[net.Conn]bool),
+		done:     make(chan struct{}),
+	}
+}
+
+// SetDelay - how long the host sits on its answer (after processing),
+// nil = answer at once
+func (s *BankSwitch) SetDelay(delay func(req *ISOMessage) time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = delay
+}
+
+// Start - listen on addr ("127.0.0.1:0" = any free port), returns the
+// address actually bound
+func (s *BankSwitch) Start(addr string) (string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	s.listener = listener
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return // closed
+			}
+			s.mu.Lock()
+			s.conns[conn] = true
+			s.mu.Unlock()
+
+			s.wg.Add(1)
+			go s.serve(conn)
+		}
+	}()
+	return listener.Addr().String(), nil
+}
+
+func (s *BankSwitch) Close() error {
+	close(s.done)
+	err := s.listener.Close()
+
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *BankSwitch) serve(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		req, err := readISOFrame(conn)
+		if err != nil {
+			return // EOF, or garbage we can't even answer
+		}
+		resp, delay := s.handle(req)
+
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-s.done:
+				return
+			}
+		}
+		if err := writeISOFrame(conn, resp); err != nil {
+			return
+		}
+	}
+}
+
+func (s *BankSwitch) handle(req *ISOMessage) (*ISOMessage, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := NewISOMessage(responseMTI(req.MTI))
+	for _, field := range []int{3, 4, 7, 11, 41, 90, 102} {
+		if req.Has(field) {
+			resp.Set(field, req.Get(field))
+		}
+	}
+
+	var rc string
+	switch req.MTI {
+	case MTIAuthRequest:
+		rc = s.authorize(req)
+	case MTIFinancialRequest:
+		rc = s.financial(req, resp)
+	case MTIReversalAdvice:
+		rc = s.reverse(req)
+	default:
+		rc = RCInvalidTxn
+	}
+	resp.Set(39, rc)
+
+	var delay time.Duration
+	if s.delay != nil {
+		delay = s.delay(req)
+	}
+	return resp, delay
+}
+
+// authorize - 0100: PIN verify / PIN change, PIN only ever as a PIN block
+// Both need the current PIN in 52; a change carries the new one in 125
+func (s *BankSwitch) authorize(req *ISOMessage) string {
+	card := req.Get(2)
+	pin, err := pinFromBlock(card, []byte(req.Get(52)))
+	if err != nil {
+		return RCIncorrectPIN
+	}
+	if ok, _ := s.Bank.ValidatePin(card, pin); !ok {
+		return RCIncorrectPIN
+	}
+
+	switch req.Get(3) {
+	case ProcPinVerify:
+		return RCApproved
+	case ProcPinChange:
+		newPin, err := pinFromBlock(card, []byte(req.Get(125)))
+		if err != nil {
+			return RCInvalidTxn
+		}
+		if err := s.Bank.SetPin(card, newPin); err != nil {
+			return RCInvalidTxn
+		}
+		return RCApproved
+	}
+	return RCInvalidTxn
+}
+
+func switchKey(terminalId, mti, stan, transmitted string) string {
+	return strings.Join([]string{terminalId, mti, stan, transmitted}, "/")
+}
+
+// financial - 0200: post on the host's ledger, ref = ISO-<key>
+func (s *BankSwitch) financial(req *ISOMessage, resp *ISOMessage) string {
+	key := switchKey(req.Get(41), req.MTI, req.Get(11), req.Get(7))
+	if record, seen := s.records[key]; seen {
+		if !record.original {
+			record.original = true
+			return RCDuplicate // already reversed, too late to apply it
+		}
+		return record.rc // retransmission: same answer, applied once
+	}
+
+	record := &switchRecord{proc: req.Get(3), accountId: req.Get(102), original: true}
+	s.records[key] = record
+
+	amount, err := parseISOAmount(req.Get(4))
+	if err != nil {
+		record.rc = RCInvalidTxn
+		return record.rc
+	}
+	record.amount = amount
+
+	counter, ref := ATMCashAccount(req.Get(41)), "ISO-"+key
+	switch record.proc {
+	case ProcWithdrawal:
+		err = s.Accounts.DebitAccount(record.accountId, amount, counter, ref)
+	case ProcDeposit:
+		err = s.Accounts.CreditAccount(record.accountId, amount, counter, ref)
+	default:
+		err = ErrInvalidAmount // unknown processing code, nothing posted
+	}
+
+	switch {
+	case err == nil:
+		record.rc = RCApproved
+		s.authSeq++
+		resp.Set(37, fmt.Sprintf("%012d", s.authSeq))
+		resp.Set(38, fmt.Sprintf("%06d", s.authSeq))
+		if available, err := s.Accounts.Available(record.accountId); err == nil {
+			resp.Set(54, isoBalance(available))
+		}
+	case errors.Is(err, ErrAccountNotFound):
+		record.rc = RCNoSuchAccount
+	case errors.Is(err, ErrInsufficientFunds):
+		record.rc = RCInsufficientFunds
+	case errors.Is(err, ErrInvalidAmount):
+		record.rc = RCInvalidTxn
+	default:
+		record.rc = RCSystemError
+	}
+	return record.rc
+}
+
+// reverse - 0420: undo the original if it was applied, approve repeats
+func (s *BankSwitch) reverse(req *ISOMessage) string {
+	original := req.Get(90)
+	if len(original) != 42 {
+		return RCInvalidTxn
+	}
+	key := switchKey(req.Get(41), original[:4], original[4:10], original[10:20])
+
+	record, seen := s.records[key]
+	if !seen {
+		// reversal overtook its original => remember, refuse the original
+		s.records[key] = &switchRecord{reversed: true}
+		return RCOriginalNotFound
+	}
+	if record.reversed || record.rc != RCApproved {
+		record.reversed = true
+		return RCApproved
+	}
+
+	counter, ref := ATMCashAccount(req.Get(41)), "ISO-"+key
+	var err error
+	switch record.proc {
+	case ProcWithdrawal:
+		err = s.Accounts.CreditAccount(record.accountId, record.amount, counter, ref)
+	case ProcDeposit:
+		err = s.Accounts.DebitAccount(record.accountId, record.amount, counter, ref)
+	}
+	if err != nil {
+		return RCSystemError // ATM keeps the reversal and retries
+	}
+	record.reversed = true
+	return RCApproved
+}
+
+// ============================================
+// HOST BANK SERVICE (ATM side)
+// ============================================
+
+type HostBankService struct {
+	Addr            string
+	TerminalId      string        // field 41
+	Timeout         time.Duration // per message, connect + answer
+	ReversalRetries int           // attempts before a reversal is stored
+	ReverseWithin   time.Duration // how long Reverse(ref) can still find an approved 0200
+	Now             func() time.Time
+	Trace           func(direction string, msg *ISOMessage) // nil = quiet
+
+	stan    int
+	pending []*ISOMessage        // unacknowledged reversals, oldest first
+	posted  map[string]postedTxn // ref -> approved 0200, for Reverse
+	mu      sync.Mutex
+}
+
+type postedTxn struct {
+	request *ISOMessage
+	at      time.Time
+}
+
+func NewHostBankService(addr, terminalId string) *HostBankService {
+	return &HostBankService{
+		Addr:            addr,
+		TerminalId:      terminalId,
+		Timeout:         2 * time.Second,
+		ReversalRetries: 3,
+		ReverseWithin:   time.Hour,
+		Now:             time.Now,
+		posted:          make(map[string]postedTxn),
+	}
+}
+
+func (h *HostBankService) ValidatePin(cardNumber, pin string) (bool, error) {
+	block, err := pinBlock(cardNumber, pin)
+	if err != nil {
+		return false, nil // same answer the host would give
+	}
+	req := h.request(MTIAuthRequest, ProcPinVerify).
+		Set(2, cardNumber).
+		Set(52, string(block))
+
+	resp, _, err := h.send(req)
+	if err != nil {
+		return false, err
+	}
+	switch rc := resp.Get(39); rc {
+	case RCApproved:
+		return true, nil
+	case RCIncorrectPIN:
+		return false, nil
+	default:
+		return false, fmt.Errorf("%w: response code %s", ErrHostDeclined, rc)
+	}
+}
+
+// ChangePin - 0100 with the current PIN block in 52, the new one in 125
+func (h *HostBankService) ChangePin(cardNumber, currentPin, newPin string) error {
+	current, err := pinBlock(cardNumber, currentPin)
+	if err != nil {
+		return ErrInvalidPIN
+	}
+	next, err := pinBlock(cardNumber, newPin)
+	if err != nil {
+		return err
+	}
+	req := h.request(MTIAuthRequest, ProcPinChange).
+		Set(2, cardNumber).
+		Set(52, string(current)).
+		Set(125, string(next))
+
+	resp, _, err := h.send(req)
+	if err != nil {
+		return err
+	}
+	switch rc := resp.Get(39); rc {
+	case RCApproved:
+		return nil
+	case RCIncorrectPIN:
+		return ErrInvalidPIN
+	default:
+		return fmt.Errorf("%w: response code %s", ErrHostDeclined, rc)
+	}
+}
+
+func (h *HostBankService) DebitAccount(accountId string, amount Money, ref string) error {
+	return h.financial(ProcWithdrawal, accountId, amount, ref)
+}
+
+func (h *HostBankService) CreditAccount(accountId string, amount Money, ref string) error {
+	return h.financial(ProcDeposit, accountId, amount, ref)
+}
+
+// Reverse - 0420 for the approved 0200 posted under ref. A timed-out
+// 0200 was reversed already, an unknown ref has nothing to undo
+func (h *HostBankService) Reverse(ref string) error {
+	h.mu.Lock()
+	posted, ok := h.posted[ref]
+	delete(h.posted, ref)
+	h.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	if err := h.reverse(posted.request); err != nil {
+		return fmt.Errorf("reversal stored for retry: %w", err)
+	}
+	return nil
+}
+
+// PendingReversals - reversals still waiting for the host's 0430
+func (h *HostBankService) PendingReversals() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.pending)
+}
+
+// FlushReversals - one more try for every stored reversal, returns how
+// many are still pending. Runs before every request anyway
+func (h *HostBankService) FlushReversals() int {
+	h.mu.Lock()
+	pending := h.pending
+	h.pending = nil
+	h.mu.Unlock()
+
+	var left []*ISOMessage
+	for _, reversal := range pending {
+		if err := h.sendReversal(reversal); err != nil {
+			left = append(left, reversal)
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.pending = append(left, h.pending...)
+	return len(h.pending)
+}
+
+func (h *HostBankService) financial(proc, accountId string, amount Money, ref string) error {
+	req := h.request(MTIFinancialRequest, proc).
+		Set(4, isoAmount(amount)).
+		Set(102, accountId)
+
+	resp, sent, err := h.send(req)
+	if err != nil {
+		if !sent {
+			return err // never left the ATM, nothing to undo
+		}
+		// the host may have applied it => always reverse
+		if rerr := h.reverse(req); rerr != nil {
+			return fmt.Errorf("%w, reversal stored for retry (%v)", err, rerr)
+		}
+		return fmt.Errorf("%w, reversed", err)
+	}
+
+	switch rc := resp.Get(39); rc {
+	case RCApproved:
+		h.remember(ref, req)
+		return nil
+	case RCInsufficientFunds:
+		return ErrInsufficientFunds
+	case RCNoSuchAccount:
+		return ErrAccountNotFound
+	default:
+		return fmt.Errorf("%w: response code %s", ErrHostDeclined, rc)
+	}
+}
+
+// remember - approved 0200 under ref, so Reverse can find it; entries
+// older than ReverseWithin are dropped (sagas undo within seconds)
+func (h *HostBankService) remember(ref string, req *ISOMessage) {
+	now := h.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for old, posted := range h.posted {
+		if now.Sub(posted.at) > h.ReverseWithin {
+			delete(h.posted, old)
+		}
+	}
+	h.posted[ref] = postedTxn{request: req, at: now}
+}
+
+// reverse - 0420 for original, ReversalRetries attempts, then stored
+func (h *HostBankService) reverse(original *ISOMessage) error {
+	reversal := h.request(MTIReversalAdvice, original.Get(3)).
+		Set(4, original.Get(4)).
+		Set(90, originalData(original)).
+		Set(102, original.Get(102))
+
+	var err error
+	for attempt := 0; attempt < h.ReversalRetries; attempt++ {
+		if err = h.sendReversal(reversal); err == nil {
+			return nil
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.pending = append(h.pending, reversal)
+	return err
+}
+
+// sendReversal - one 0420. Only 00 (undone) and 25 (never saw it) mean
+// the host has it covered; 96 etc. => the debit may still stand, keep it
+func (h *HostBankService) sendReversal(reversal *ISOMessage) error {
+	resp, _, err := h.exchange(reversal)
+	if err != nil {
+		return err
+	}
+	switch rc := resp.Get(39); rc {
+	case RCApproved, RCOriginalNotFound:
+		return nil
+	default:
+		return fmt.Errorf("%w: reversal response code %s", ErrHostDeclined, rc)
+	}
+}
+
+func (h *HostBankService) request(mti, proc string) *ISOMessage {
+	h.mu.Lock()
+	h.stan = h.stan%999999 + 1
+	stan := h.stan
+	h.mu.Unlock()
+
+	return NewISOMessage(mti).
+		Set(3, proc).
+		Set(7, isoTime(h.Now())).
+		Set(11, fmt.Sprintf("%06d", stan)).
+		Set(41, h.TerminalId)
+}
+
+// send - stored reversals first, then req
+func (h *HostBankService) send(req *ISOMessage) (*ISOMessage, bool, error) {
+	if h.PendingReversals() > 0 {
+		h.FlushReversals()
+	}
+	return h.exchange(req)
+}
+
+// exchange - one request, one answer on a fresh connection. sent = the
+// request went out (so the host may have acted on it)
+func (h *HostBankService) exchange(req *ISOMessage) (*ISOMessage, bool, error) {
+	conn, err := net.DialTimeout("tcp", h.Addr, h.Timeout)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %v", ErrHostUnavailable, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(h.Timeout))
+
+	h.trace("→", req)
+	if err := writeISOFrame(conn, req); err != nil {
+		if errors.Is(err, ErrISOFieldValue) {
+			return nil, false, err // our own bad message, nothing sent
+		}
+		return nil, true, hostError(err)
+	}
+	resp, err := readISOFrame(conn)
+	if err != nil {
+		return nil, true, hostError(err)
+	}
+	h.trace("←", resp)
+
+	if resp.MTI != responseMTI(req.MTI) || resp.Get(11) != req.Get(11) {
+		return nil, true, fmt.Errorf("%w: answer %s/%s to %s/%s", ErrISOFormat, resp.MTI, resp.Get(11), req.MTI, req.Get(11))
+	}
+	return resp, true, nil
+}
+
+func hostError(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrHostTimeout
+	}
+	if errors.Is(err, ErrISOFormat) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrHostUnavailable, err)
+}
+
+func (h *HostBankService) trace(direction string, msg *ISOMessage) {
+	if h.Trace != nil {
+		h.Trace(direction, msg)
+	}
+}
+
+// ============================================
+// HOST PROTOCOL DEMO
+// Real TCP on 127.0.0.1, the switch's SetDelay plays the slow host
+// ============================================
+
+func testHostProtocol() {
+	fmt.Println("📦 Codec round trip")
+	msg := NewISOMessage(MTIFinancialRequest).
+		Set(3, ProcWithdrawal).
+		Set(4, isoAmount(Rupees(1500))).
+		Set(7, "0115100000").
+		Set(11, "000042").
+		Set(41, "ATM-001").
+		Set(102, "ACC-001")
+	data, _ := msg.Pack()
+	fmt.Printf("   %s\n", msg)
+	fmt.Printf("   %d bytes, bitmap %X (bit 1 set: field 102 needs the secondary)\n", len(data), data[4:20])
+	if back, err := UnpackISOMessage(data); err == nil && back.String() == msg.String() {
+		fmt.Println("   ✅ unpacked to the same message")
+	}
+	if _, err := UnpackISOMessage(data[:len(data)-3]); err != nil {
+		fmt.Println("   ✅ truncated message rejected:", err)
+	}
+	if _, err := NewISOMessage(MTIFinancialRequest).Set(4, "12ab").Pack(); err != nil {
+		fmt.Println("   ✅ bad amount not sent:", err)
+	}
+	fmt.Println()
+
+	// host side: its own PIN store and ledger
+	hostBank := NewBankServiceV1()
+	hostLedger := NewLedger(INR)
+	hostAccounts, _ := NewAccountServiceV1(nil, hostLedger, WithdrawalLimits{})
+	hostAccounts.OpenAccount(&Account{Id: "ACC-H1", UserId: "USER-H", BankId: "BANK-001", AccountType: Savings}, Rupees(10000))
+	hostBank.SetPin("CARD-H1", "4321")
+
+	sw := NewBankSwitch(hostBank, hostAccounts)
+	addr, err := sw.Start("127.0.0.1:0")
+	if err != nil {
+		fmt.Println("   ❌ switch:", err)
+		return
+	}
+	defer sw.Close()
+	hostBalance := func() Money {
+		balance, _ := hostAccounts.Available("ACC-H1")
+		return balance
+	}
+
+	// ATM side: card + PIN checks now go over the wire
+	host := NewHostBankService(addr, "ATM-H1")
+	host.Timeout = 150 * time.Millisecond
+	host.Trace = func(direction string, msg *ISOMessage) {
+		fmt.Printf("      %s %s\n", direction, msg)
+	}
+	rig := newATMRig(WithdrawalLimits{})
+	atmServ, dispenser := rig.addATM("ATM-H1", map[float64]int{500: 10})
+	atmServ.BankService = host
+	// the ATM's own mirror of the account (holds, receipts); the host has the final say
+	rig.accounts.OpenAccount(&Account{Id: "ACC-H1", UserId: "USER-H", BankId: "BANK-001", AccountType: Savings, DailyLimit: Rupees(20000)}, Rupees(10000))
+	rig.issueCard("CARD-H1", "USER-H", "ACC-H1", "Host Tester", "") // PIN lives at the host
+
+	fmt.Printf("🔌 Switch on %s, ACC-H1 holds %v at the host\n\n", addr, hostBalance())
+
+	fmt.Println("🔑 0100 PIN verify: wrong, then right")
+	if _, err := atmServ.Authenticate("CARD-H1", "1111"); err != nil {
+		fmt.Println("   ✅ rejected:", err)
+	}
+	session, err := atmServ.Authenticate("CARD-H1", "4321")
+	if err != nil {
+		fmt.Println("   ❌ not authenticated:", err)
+		return
+	}
+	fmt.Println("   ✅ authenticated by the host")
+	defer atmServ.EndSession(session)
+	fmt.Println()
+
+	// every Withdraw below is the real saga: bank_debit is the 0200
+	lastTxn := func() *Transaction {
+		history, _ := rig.txns.GetTransactionHistory("ACC-H1")
+		return history[len(history)-1]
+	}
+
+	fmt.Println("💸 Withdraw ₹2,000 => 0200")
+	if err := atmServ.Withdraw(session, Rupees(2000)); err != nil {
+		fmt.Println("   ❌", err)
+	} else {
+		fmt.Printf("   ✅ approved, host balance %v, ATM cash %v\n", hostBalance(), dispenser.GetCurrentBalance())
+	}
+	fmt.Println()
+
+	fmt.Println("🐢 Host sits on the 0210 for 400ms, ATM gives up after 150ms")
+	sw.SetDelay(func(req *ISOMessage) time.Duration {
+		if req.MTI == MTIFinancialRequest {
+			return 400 * time.Millisecond
+		}
+		return 0
+	})
+	err = atmServ.Withdraw(session, Rupees(1000))
+	fmt.Printf("   ✅ %v\n", err)
+	fmt.Printf("   ✅ host applied then reversed it: balance %v\n", hostBalance())
+	fmt.Printf("   ✅ withdrawal %s after %s, no cash out: ATM cash %v\n\n", lastTxn().Status, orNone(lastTxn().Step), dispenser.GetCurrentBalance())
+
+	fmt.Println("📴 0430s get lost too => reversal stored, sent before the next request")
+	sw.SetDelay(func(req *ISOMessage) time.Duration { return 400 * time.Millisecond })
+	err = atmServ.Withdraw(session, Rupees(1000))
+	fmt.Printf("   ✅ %v\n", err)
+	fmt.Printf("   ✅ pending reversals at the ATM: %d (host balance %v)\n", host.PendingReversals(), hostBalance())
+	sw.SetDelay(nil)
+	if ok, err := host.ValidatePin("CARD-H1", "4321"); ok && err == nil {
+		fmt.Printf("   ✅ next request flushed it: pending %d, host balance %v\n", host.PendingReversals(), hostBalance())
+	}
+	fmt.Println()
+
+	fmt.Println("💥 Host approved, then the cassette jams => 0420 for that 0200, not a deposit")
+	atmServ.FaultHook = func(step string) error {
+		if step == "dispense" {
+			return ErrInjectedFault
+		}
+		return nil
+	}
+	err = atmServ.Withdraw(session, Rupees(1000))
+	atmServ.FaultHook = nil
+	fmt.Printf("   ✅ %v\n", err)
+	fmt.Printf("   ✅ withdrawal %s, host balance back to %v\n\n", lastTxn().Status, hostBalance())
+
+	fmt.Println("🔑 0100 PIN change: the host wants the current PIN too")
+	host.Timeout = time.Second // verify + re-hash on the host takes a while
+	if err := host.ChangePin("CARD-H1", "1111", "8642"); errors.Is(err, ErrInvalidPIN) {
+		fmt.Println("   ✅ wrong current PIN refused:", err)
+	}
+	if err := host.ChangePin("CARD-H1", "4321", "8642"); err == nil {
+		ok, _ := host.ValidatePin("CARD-H1", "8642")
+		fmt.Printf("   ✅ changed, new PIN accepted=%v\n", ok)
+	} else {
+		fmt.Println("   ❌", err)
+	}
+	fmt.Println()
+
+	fmt.Println("🚫 0200 for more than the balance (straight to the host, the ATM's mirror would refuse it first)")
+	if err := host.DebitAccount("ACC-H1", Rupees(50000), "TXN-DIRECT"); errors.Is(err, ErrInsufficientFunds) {
+		fmt.Println("   ✅ declined:", err)
+	}
+	fmt.Println()
+
+	debits, credits := hostLedger.TrialBalance()
+	fmt.Printf("📒 Host ledger: %d entries, debits %v = credits %v\n", len(hostLedger.Entries("")), debits, credits)
+}
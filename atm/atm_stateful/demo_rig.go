@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// ============================================
+// DEMO RIG
+// The services every demo wires up the same way: bank, cards (with an
+// audit log), transactions, one ledger, accounts and receipts. ATMs
+// and cards are added per demo.
+// ============================================
+
+type atmRig struct {
+	bank     *BankServiceV1
+	cards    *CardServiceV1
+	txns     *TransactionServiceV1
+	accounts *AccountServiceV1
+	receipts *ReceiptServiceV1
+	ledger   *Ledger
+	audit    *AuditLogV1
+}
+
+func newATMRig(limits WithdrawalLimits) *atmRig {
+	audit := NewAuditLogV1()
+	cards, _ := NewCardServiceV1(DefaultPinLockoutPolicy(), audit)
+	txns, _ := NewTransactionServiceV1()
+	ledger := NewLedger(INR)
+	accounts, _ := NewAccountServiceV1(txns, ledger, limits)
+	receipts, _ := NewReceiptServiceV1(txns, accounts)
+	return &atmRig{
+		bank: NewBankServiceV1(), cards: cards, txns: txns,
+		accounts: accounts, receipts: receipts, ledger: ledger, audit: audit,
+	}
+}
+
+// demoNotes - ₹12,000: 500×10, 200×20, 100×30
+func demoNotes() map[float64]int {
+	return map[float64]int{500: 10, 200: 20, 100: 30}
+}
+
+// addATM - ATM on the rig's services, its cash load booked in the ledger
+func (r *atmRig) addATM(atmId string, notes map[float64]int) (*ATMServiceV1, *CashDispenserV1) {
+	dispenser, _ := NewCashDispenserV1(notes)
+	r.ledger.RecordCashLoad("LOAD-"+atmId, atmId, dispenser.GetCurrentBalance())
+	atmServ, _ := NewATMServiceV1(atmId, r.txns, r.bank, r.accounts, r.cards, r.receipts, dispenser)
+	atmServ.Audit = r.audit
+	return atmServ, dispenser
+}
+
+// issueCard - active card valid for 2 years, pin "" = PIN kept elsewhere (host)
+func (r *atmRig) issueCard(cardNumber, userId, accountId, name, pin string) {
+	r.cards.Cards[cardNumber] = &Card{
+		CardNumber: cardNumber, UserId: userId, AccountId: accountId,
+		Name: name, ExpiryDate: time.Now().AddDate(2, 0, 0), Status: Active,
+	}
+	if pin != "" {
+		r.bank.SetPin(cardNumber, pin)
+	}
+}
+
+// checkFailures - self-checking demos (saga faults, note mix property
+// check) count here, main exits non-zero if any of them failed
+var checkFailures int
+
+func checkFailed(format string, args ...interface{}) {
+	checkFailures++
+	fmt.Printf(format, args...)
+}
@@ -0,0 +1,652 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================
+// FLEET MONITORING & CASH REPLENISHMENT
+// Every ATM knew its own cassettes, nobody looked across machines =>
+// operations heard about an empty ATM from customers. Now:
+//
+//	ATM ──heartbeat──▶ FleetService ──▶ alerts (LOW_CASH, CASSETTE_EMPTY,
+//	  cassettes, faults,     │            DEPLETION_SOON, FAULT, OFFLINE)
+//	  last transaction       │
+//	                         ├──▶ forecast: notes/hour per denomination
+//	                         │    from the ATM's withdrawal history
+//	                         └──▶ replenishment order ──execute──▶
+//	                              CashDispenserV1.Deposit + ledger LOAD
+//
+// Alerts are raised once per condition and cleared when the condition
+// goes away, so a heartbeat every minute doesn't page anybody 60 times
+// ============================================
+
+var (
+	ErrUnknownATM          = errors.New("ATM not registered with the fleet")
+	ErrNothingToReplenish  = errors.New("no cassette needs replenishment")
+	ErrOrderNotFound       = errors.New("replenishment order not found")
+	ErrOrderNotExecutable  = errors.New("replenishment order is not PLANNED")
+	ErrATMAlreadyInService = errors.New("ATM already registered")
+)
+
+// ATMStatus - one heartbeat
+type ATMStatus struct {
+	ATMId           string
+	Cassettes       map[float64]int   // sellable notes per denomination
+	Faults          map[string]string // fault code -> detail
+	LastTransaction time.Time         // zero = none yet
+}
+
+// Heartbeat - what the ATM reports to the fleet every minute or so
+func (s *ATMServiceV1) Heartbeat() ATMStatus {
+	status := ATMStatus{
+		ATMId:     s.ATMid,
+		Cassettes: s.CashDispenserService.Cassettes(),
+		Faults:    s.Faults(),
+	}
+	if txns, err := s.TransactionService.GetATMTransactions(s.ATMid); err == nil && len(txns) > 0 {
+		status.LastTransaction = txns[len(txns)-1].CreatedAt
+	}
+	return status
+}
+
+// ReportFault - hardware noticed something (CARD_READER, RECEIPT_PRINTER, ...)
+func (s *ATMServiceV1) ReportFault(code, detail string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[code] = detail
+}
+
+func (s *ATMServiceV1) ClearFault(code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.faults, code)
+}
+
+func (s *ATMServiceV1) Faults() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	faults := make(map[string]string, len(s.faults))
+	for code, detail := range s.faults {
+		faults[code] = detail
+	}
+	return faults
+}
+
+// ============================================
+// POLICY, ALERTS, FORECAST, ORDERS
+// ============================================
+
+// CashPolicy - when to worry and how much to load
+type CashPolicy struct {
+	LowNotes         map[float64]int // fewer notes than this => LOW_CASH
+	Capacity         map[float64]int // cassette size, orders fill up to it
+	ForecastWindow   time.Duration   // withdrawal history behind the rate
+	Horizon          time.Duration   // empty sooner than this => DEPLETION_SOON
+	HeartbeatTimeout time.Duration   // silent longer than this => OFFLINE
+}
+
+func DefaultCashPolicy() CashPolicy {
+	return CashPolicy{
+		LowNotes:         map[float64]int{500: 5, 200: 10, 100: 10},
+		Capacity:         map[float64]int{500: 100, 200: 100, 100: 100},
+		ForecastWindow:   24 * time.Hour,
+		Horizon:          12 * time.Hour,
+		HeartbeatTimeout: 5 * time.Minute,
+	}
+}
+
+type AlertKind string
+
+const (
+	AlertLowCash       AlertKind = "LOW_CASH"
+	AlertCassetteEmpty AlertKind = "CASSETTE_EMPTY"
+	AlertDepletionSoon AlertKind = "DEPLETION_SOON"
+	AlertFault         AlertKind = "FAULT"
+	AlertOffline       AlertKind = "OFFLINE"
+	AlertCleared       AlertKind = "CLEARED"
+)
+
+const noDenomination float64 = 0
+
+type Alert struct {
+	ATMId  string
+	Kind   AlertKind
+	Denom  float64 // 0 = not about one cassette
+	Detail string
+	At     time.Time
+}
+
+func (a Alert) key() string {
+	return strings.Join([]string{a.ATMId, string(a.Kind), fmt.Sprint(a.Denom), a.Detail}, "|")
+}
+
+func (a Alert) String() string {
+	subject := a.ATMId
+	if a.Denom != noDenomination {
+		subject += fmt.Sprintf(" ₹%.0f", a.Denom)
+	}
+	return fmt.Sprintf("%-14s %-17s %s", a.Kind, subject, a.Detail)
+}
+
+// DenomForecast - EmptyIn < 0 = no withdrawals in the window, not emptying
+type DenomForecast struct {
+	Notes     int
+	Dispensed int // in the window
+	PerHour   float64
+	EmptyIn   time.Duration
+}
+
+type DepletionForecast struct {
+	ATMId    string
+	Window   time.Duration
+	PerDenom map[float64]DenomForecast
+}
+
+// FirstEmpty - the cassette that runs out first (ok = false: none is)
+func (f *DepletionForecast) FirstEmpty() (denom float64, in time.Duration, ok bool) {
+	for d, forecast := range f.PerDenom {
+		if forecast.EmptyIn >= 0 && (!ok || forecast.EmptyIn < in) {
+			denom, in, ok = d, forecast.EmptyIn, true
+		}
+	}
+	return denom, in, ok
+}
+
+type OrderStatus string
+
+const (
+	OrderPlanned  OrderStatus = "PLANNED"
+	OrderLoading  OrderStatus = "LOADING" // ExecuteOrder in progress
+	OrderExecuted OrderStatus = "EXECUTED"
+	OrderFailed   OrderStatus = "FAILED"
+)
+
+type ReplenishmentOrder struct {
+	Id         string
+	ATMId      string
+	Notes      map[float64]int // to load, per denomination
+	Amount     Money
+	Reason     string
+	Status     OrderStatus
+	CreatedAt  time.Time
+	ExecutedAt time.Time
+}
+
+// ============================================
+// FLEET SERVICE
+// ============================================
+
+type FleetService interface {
+	Register(atm *ATM, dispenser *CashDispenserV1) error
+	ReportHeartbeat(status ATMStatus) ([]Alert, error)
+	Sweep() []Alert // offline check + forecasts, run on a timer
+	Forecast(atmId string) (*DepletionForecast, error)
+	PlanReplenishment(atmId string) (*ReplenishmentOrder, error)
+	ExecuteOrder(orderId string) error
+	ActiveAlerts() []Alert
+}
+
+type fleetMember struct {
+	atm       *ATM
+	dispenser *CashDispenserV1
+	status    ATMStatus
+	lastSeen  time.Time // zero = never reported
+}
+
+type FleetServiceV1 struct {
+	Policy CashPolicy
+	Now    func() time.Time
+	Notify func(alert Alert) // pager / dashboard, nil = only kept in the log
+
+	transactions TransactionService
+	ledger       *Ledger // replenishment = cash moved from the vault
+	members      map[string]*fleetMember
+	active       map[string]Alert // key -> alert, currently raised
+	log          []Alert
+	orders       map[string]*ReplenishmentOrder
+	orderSeq     int
+	mu           sync.Mutex
+}
+
+func NewFleetServiceV1(txnServ TransactionService, ledger *Ledger, policy CashPolicy) (*FleetServiceV1, error) {
+	return &FleetServiceV1{
+		Policy:       policy,
+		Now:          time.Now,
+		transactions: txnServ,
+		ledger:       ledger,
+		members:      make(map[string]*fleetMember),
+		active:       make(map[string]Alert),
+		orders:       make(map[string]*ReplenishmentOrder),
+	}, nil
+}
+
+func (f *FleetServiceV1) Register(atm *ATM, dispenser *CashDispenserV1) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, exists := f.members[atm.Id]; exists {
+		return ErrATMAlreadyInService
+	}
+	f.members[atm.Id] = &fleetMember{atm: atm, dispenser: dispenser, status: ATMStatus{ATMId: atm.Id}}
+	return nil
+}
+
+// ReportHeartbeat - store the status, returns the alerts it raised
+func (f *FleetServiceV1) ReportHeartbeat(status ATMStatus) ([]Alert, error) {
+	f.mu.Lock()
+	member, ok := f.members[status.ATMId]
+	if !ok {
+		f.mu.Unlock()
+		return nil, ErrUnknownATM
+	}
+	member.status = status
+	member.lastSeen = f.Now()
+	f.mu.Unlock()
+
+	forecast, err := f.Forecast(status.ATMId)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.evaluate(member, forecast), nil
+}
+
+// Sweep - OFFLINE for silent ATMs, DEPLETION_SOON from fresh forecasts
+func (f *FleetServiceV1) Sweep() []Alert {
+	f.mu.Lock()
+	ids := make([]string, 0, len(f.members))
+	for id := range f.members {
+		ids = append(ids, id)
+	}
+	f.mu.Unlock()
+	sort.Strings(ids)
+
+	var raised []Alert
+	for _, id := range ids {
+		forecast, err := f.Forecast(id)
+		if err != nil {
+			continue
+		}
+		f.mu.Lock()
+		raised = append(raised, f.evaluate(f.members[id], forecast)...)
+		f.mu.Unlock()
+	}
+	return raised
+}
+
+// evaluate - conditions now vs alerts raised before; caller holds mu
+func (f *FleetServiceV1) evaluate(member *fleetMember, forecast *DepletionForecast) []Alert {
+	now, atmId := f.Now(), member.atm.Id
+	var current []Alert
+	add := func(kind AlertKind, denom float64, detail string) {
+		current = append(current, Alert{ATMId: atmId, Kind: kind, Denom: denom, Detail: detail, At: now})
+	}
+
+	if member.lastSeen.IsZero() || now.Sub(member.lastSeen) > f.Policy.HeartbeatTimeout {
+		add(AlertOffline, noDenomination, "no heartbeat")
+	}
+	for _, denom := range sortedDenoms(member.status.Cassettes) {
+		count := member.status.Cassettes[denom]
+		switch {
+		case count == 0:
+			add(AlertCassetteEmpty, denom, "0 notes")
+		case count < f.Policy.LowNotes[denom]:
+			add(AlertLowCash, denom, fmt.Sprintf("below %d notes", f.Policy.LowNotes[denom]))
+		}
+		if fc, ok := forecast.PerDenom[denom]; ok && count > 0 && fc.EmptyIn >= 0 && fc.EmptyIn < f.Policy.Horizon {
+			add(AlertDepletionSoon, denom, "empty within "+f.Policy.Horizon.String())
+		}
+	}
+	for code, detail := range member.status.Faults {
+		add(AlertFault, noDenomination, code+": "+detail)
+	}
+
+	// raise new ones, clear the ones whose condition is gone
+	var raised []Alert
+	seen := make(map[string]bool)
+	for _, alert := range current {
+		seen[alert.key()] = true
+		if _, already := f.active[alert.key()]; already {
+			continue
+		}
+		f.active[alert.key()] = alert
+		raised = append(raised, alert)
+	}
+	for key, alert := range f.active {
+		if alert.ATMId == atmId && !seen[key] {
+			delete(f.active, key)
+			cleared := alert
+			cleared.Detail, cleared.At = fmt.Sprintf("%s %s", alert.Kind, alert.Detail), now
+			cleared.Kind = AlertCleared
+			raised = append(raised, cleared)
+		}
+	}
+
+	sort.SliceStable(raised, func(i, j int) bool { return raised[i].key() < raised[j].key() })
+	for _, alert := range raised {
+		f.log = append(f.log, alert)
+		if f.Notify != nil {
+			f.Notify(alert)
+		}
+	}
+	return raised
+}
+
+// Forecast - notes per hour per cassette over Policy.ForecastWindow of
+// completed withdrawals, cassette levels from the last heartbeat
+func (f *FleetServiceV1) Forecast(atmId string) (*DepletionForecast, error) {
+	f.mu.Lock()
+	member, ok := f.members[atmId]
+	if !ok {
+		f.mu.Unlock()
+		return nil, ErrUnknownATM
+	}
+	cassettes, window, now := member.status.Cassettes, f.Policy.ForecastWindow, f.Now()
+	f.mu.Unlock()
+
+	history, err := f.transactions.GetATMTransactions(atmId)
+	if err != nil {
+		return nil, err
+	}
+	dispensed := make(map[float64]int)
+	for _, txn := range history {
+		if txn.Type != Withdraw || txn.Status != Completed || now.Sub(txn.CreatedAt) > window {
+			continue
+		}
+		for denom, count := range txn.Notes {
+			dispensed[denom] += count
+		}
+	}
+
+	forecast := &DepletionForecast{ATMId: atmId, Window: window, PerDenom: make(map[float64]DenomForecast)}
+	for denom, count := range cassettes {
+		fc := DenomForecast{Notes: count, Dispensed: dispensed[denom], EmptyIn: -1}
+		if fc.Dispensed > 0 {
+			fc.PerHour = float64(fc.Dispensed) / window.Hours()
+			fc.EmptyIn = time.Duration(float64(count) / fc.PerHour * float64(time.Hour))
+		}
+		forecast.PerDenom[denom] = fc
+	}
+	return forecast, nil
+}
+
+// PlanReplenishment - if any cassette is low, empty or running out
+// within the horizon, one visit fills every cassette up to Capacity
+func (f *FleetServiceV1) PlanReplenishment(atmId string) (*ReplenishmentOrder, error) {
+	forecast, err := f.Forecast(atmId)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cassettes := f.members[atmId].dispenser.Cassettes()
+	var reasons []string
+	for _, denom := range sortedDenoms(cassettes) {
+		count := cassettes[denom]
+		fc := forecast.PerDenom[denom]
+		switch {
+		case count < f.Policy.LowNotes[denom]:
+			reasons = append(reasons, fmt.Sprintf("₹%.0f low (%d)", denom, count))
+		case fc.EmptyIn >= 0 && fc.EmptyIn < f.Policy.Horizon:
+			reasons = append(reasons, fmt.Sprintf("₹%.0f empty in %v", denom, fc.EmptyIn.Round(time.Minute)))
+		}
+	}
+	if len(reasons) == 0 {
+		return nil, ErrNothingToReplenish
+	}
+
+	notes := make(map[float64]int)
+	for denom, capacity := range f.Policy.Capacity {
+		if need := capacity - cassettes[denom]; need > 0 {
+			notes[denom] = need
+		}
+	}
+
+	f.orderSeq++
+	order := &ReplenishmentOrder{
+		Id:        fmt.Sprintf("RPL-%s-%03d", atmId, f.orderSeq),
+		ATMId:     atmId,
+		Notes:     notes,
+		Amount:    notesTotal(notes),
+		Reason:    strings.Join(reasons, ", "),
+		Status:    OrderPlanned,
+		CreatedAt: f.Now(),
+	}
+	f.orders[order.Id] = order
+	return order, nil
+}
+
+// ExecuteOrder - the cash crew loaded the notes: into the cassettes,
+// onto the ledger (vault => ATM cash), alerts re-checked
+// The order goes LOADING before the lock is released => a second
+// ExecuteOrder for it (crew app retrying) is refused, never loads twice
+func (f *FleetServiceV1) ExecuteOrder(orderId string) error {
+	f.mu.Lock()
+	order, ok := f.orders[orderId]
+	if !ok {
+		f.mu.Unlock()
+		return ErrOrderNotFound
+	}
+	if order.Status != OrderPlanned {
+		f.mu.Unlock()
+		return ErrOrderNotExecutable
+	}
+	order.Status = OrderLoading
+	member := f.members[order.ATMId]
+	f.mu.Unlock()
+
+	err := member.dispenser.Deposit(order.Notes)
+	if err == nil && f.ledger != nil {
+		err = f.ledger.RecordCashLoad("LOAD-"+order.Id, order.ATMId, order.Amount)
+	}
+
+	f.mu.Lock()
+	if err != nil {
+		order.Status = OrderFailed
+		f.mu.Unlock()
+		return err
+	}
+	order.Status, order.ExecutedAt = OrderExecuted, f.Now()
+	member.status.Cassettes = member.dispenser.Cassettes()
+	f.mu.Unlock()
+
+	// fresh levels => LOW_CASH / CASSETTE_EMPTY clear now, not at the
+	// next heartbeat
+	forecast, err := f.Forecast(order.ATMId)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.evaluate(member, forecast)
+	return nil
+}
+
+func (f *FleetServiceV1) ActiveAlerts() []Alert {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	alerts := make([]Alert, 0, len(f.active))
+	for _, alert := range f.active {
+		alerts = append(alerts, alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].key() < alerts[j].key() })
+	return alerts
+}
+
+// Report - one line per ATM for the operations dashboard
+func (f *FleetServiceV1) Report() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ids := make([]string, 0, len(f.members))
+	for id := range f.members {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var b strings.Builder
+	for _, id := range ids {
+		member := f.members[id]
+		seen := "never"
+		if !member.lastSeen.IsZero() {
+			seen = f.Now().Sub(member.lastSeen).Round(time.Second).String() + " ago"
+		}
+		last := "none"
+		if !member.status.LastTransaction.IsZero() {
+			last = member.status.LastTransaction.Format("15:04:05")
+		}
+		fmt.Fprintf(&b, "   %-8s %-10s seen %-9s cash %-10v notes %-24s last txn %s\n",
+			id, member.atm.Location.City, seen, notesTotal(member.status.Cassettes),
+			formatNotes(member.status.Cassettes), last)
+	}
+	return b.String()
+}
+
+func sortedDenoms(notes map[float64]int) []float64 {
+	denoms := make([]float64, 0, len(notes))
+	for denom := range notes {
+		denoms = append(denoms, denom)
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(denoms)))
+	return denoms
+}
+
+// ============================================
+// FLEET DEMO
+// Fleet clock is faked so "6 minutes later" doesn't take 6 minutes
+// ============================================
+
+func testFleet() {
+	now := time.Now()
+	advance := func(d time.Duration) {
+		now = now.Add(d)
+		fmt.Printf("   ⏩ +%v\n", d)
+	}
+
+	rig := newATMRig(WithdrawalLimits{})
+	rig.accounts.OpenAccount(&Account{Id: "ACC-FL", UserId: "USER-FL", BankId: "BANK-001", AccountType: Savings, DailyLimit: Rupees(1000000)}, Rupees(500000))
+	rig.issueCard("CARD-FL", "USER-FL", "ACC-FL", "Fleet Tester", "9999")
+
+	policy := DefaultCashPolicy()
+	policy.ForecastWindow = time.Hour // freshly loaded ATMs: last hour is all the history there is
+	policy.Horizon = 4 * time.Hour    // the cash crew needs ~4h notice
+	fleet, _ := NewFleetServiceV1(rig.txns, rig.ledger, policy)
+	fleet.Now = func() time.Time { return now }
+	fleet.Notify = func(alert Alert) { fmt.Printf("   🔔 %s\n", alert) }
+
+	atms := map[string]*ATMServiceV1{}
+	dispensers := map[string]*CashDispenserV1{}
+	for _, setup := range []struct {
+		atm   *ATM
+		notes map[float64]int
+	}{
+		{&ATM{Id: "ATM-HYD", BankId: "BANK-001", Location: &Location{City: "Hyderabad"}}, map[float64]int{500: 80, 200: 90, 100: 90}},
+		{&ATM{Id: "ATM-BLR", BankId: "BANK-001", Location: &Location{City: "Bengaluru"}}, map[float64]int{500: 20, 200: 40, 100: 40}},
+		{&ATM{Id: "ATM-CHN", BankId: "BANK-001", Location: &Location{City: "Chennai"}}, map[float64]int{500: 60, 200: 60, 100: 60}},
+	} {
+		atmServ, dispenser := rig.addATM(setup.atm.Id, setup.notes)
+		fleet.Register(setup.atm, dispenser)
+		atms[setup.atm.Id], dispensers[setup.atm.Id] = atmServ, dispenser
+	}
+
+	fmt.Println("💓 First heartbeats (ATM-CHN stays silent)")
+	fleet.ReportHeartbeat(atms["ATM-HYD"].Heartbeat())
+	fleet.ReportHeartbeat(atms["ATM-BLR"].Heartbeat())
+	fmt.Println("   ✅ no alerts, cassettes full")
+	fmt.Println()
+
+	fmt.Println("💸 Busy hour at ATM-BLR: 4 × ₹2,000, 3 × ₹1,000 in ₹200s")
+	session, _ := atms["ATM-BLR"].Authenticate("CARD-FL", "9999")
+	for i := 0; i < 4; i++ {
+		atms["ATM-BLR"].Withdraw(session, Rupees(2000))
+	}
+	for i := 0; i < 3; i++ {
+		atms["ATM-BLR"].WithdrawWithMix(session, Rupees(1000), map[float64]int{200: 5})
+	}
+	atms["ATM-BLR"].EndSession(session)
+	atms["ATM-HYD"].ReportFault("RECEIPT_PRINTER", "paper out")
+	advance(time.Minute)
+	fleet.ReportHeartbeat(atms["ATM-BLR"].Heartbeat())
+	fleet.ReportHeartbeat(atms["ATM-HYD"].Heartbeat())
+	fmt.Println()
+
+	fmt.Println("💓 Same levels again, then the silent ATM is noticed")
+	advance(5 * time.Minute)
+	fleet.ReportHeartbeat(atms["ATM-BLR"].Heartbeat())
+	fleet.ReportHeartbeat(atms["ATM-HYD"].Heartbeat())
+	fmt.Println("   ✅ nothing repeated for unchanged conditions")
+	fleet.Sweep()
+	fmt.Println()
+
+	fmt.Println("📈 Forecast ATM-BLR (last hour of withdrawals)")
+	forecast, _ := fleet.Forecast("ATM-BLR")
+	for _, denom := range sortedDenoms(dispensers["ATM-BLR"].Cassettes()) {
+		fc := forecast.PerDenom[denom]
+		emptyIn := "not emptying"
+		if fc.EmptyIn >= 0 {
+			emptyIn = "empty in " + fc.EmptyIn.Round(time.Minute).String()
+		}
+		fmt.Printf("   ₹%-4.0f %3d notes, %2d dispensed, %5.1f/h, %s\n", denom, fc.Notes, fc.Dispensed, fc.PerHour, emptyIn)
+	}
+	if denom, in, ok := forecast.FirstEmpty(); ok {
+		fmt.Printf("   ⚠️  ₹%.0f cassette runs out first, in %v\n", denom, in.Round(time.Minute))
+	}
+	fmt.Println()
+
+	fmt.Println("🚚 Replenishment")
+	if _, err := fleet.PlanReplenishment("ATM-HYD"); err != nil {
+		fmt.Println("   ✅ ATM-HYD:", err)
+	}
+	order, err := fleet.PlanReplenishment("ATM-BLR")
+	if err != nil {
+		fmt.Println("   ❌ ATM-BLR:", err)
+		return
+	}
+	fmt.Printf("   📋 %s %s: load %s = %v (%s)\n", order.Id, order.Status, formatNotes(order.Notes), order.Amount, order.Reason)
+	advance(30 * time.Minute) // crew on site, ATMs kept reporting meanwhile
+	fleet.ReportHeartbeat(atms["ATM-BLR"].Heartbeat())
+	fleet.ReportHeartbeat(atms["ATM-HYD"].Heartbeat())
+	// the crew's app retries on a flaky network => two ExecuteOrder at once
+	results := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { results <- fleet.ExecuteOrder(order.Id) }()
+	}
+	loaded := 0
+	for i := 0; i < 2; i++ {
+		switch err := <-results; {
+		case err == nil:
+			loaded++
+		case !errors.Is(err, ErrOrderNotExecutable):
+			fmt.Println("   ❌", err)
+		}
+	}
+	if loaded != 1 {
+		checkFailed("   ❌ %s loaded %d times\n", order.Id, loaded)
+	}
+	fmt.Printf("   ✅ %s %s, cassettes now %s\n", order.Id, order.Status, formatNotes(dispensers["ATM-BLR"].Cassettes()))
+	if err := fleet.ExecuteOrder(order.Id); errors.Is(err, ErrOrderNotExecutable) {
+		fmt.Println("   ✅ executing it twice refused:", err)
+	}
+	booked, counted := rig.ledger.Balance(ATMCashAccount("ATM-BLR")), dispensers["ATM-BLR"].CountedCash()
+	fmt.Printf("   ✅ ledger atm-cash:ATM-BLR %v = counted %v\n", booked, counted)
+	fmt.Println()
+
+	fmt.Println("🖥️  Fleet dashboard")
+	fmt.Print(fleet.Report())
+	fmt.Println("   Active alerts:")
+	for _, alert := range fleet.ActiveAlerts() {
+		fmt.Printf("   • %s\n", alert)
+	}
+}
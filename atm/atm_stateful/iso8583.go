@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================
+// ISO 8583 (subset)
+// How the ATM talks to the bank host instead of calling BankServiceV1
+// in-process:
+//
+//	MTI   Meaning                        Answer
+//	----  -----------------------------  ------
+//	0100  authorization (PIN verify /    0110
+//	      PIN change, no money moves)
+//	0200  financial (withdraw, deposit)  0210
+//	0420  reversal of an 0200 the ATM    0430
+//	      never got an answer for
+//
+// Wire format: MTI (4 ASCII digits) | bitmap | fields in field order.
+// Bitmap = 8 bytes, bit 1 set => a second 8 bytes follow (fields 65-128).
+// Numeric fields are zero-padded ASCII, alphanumeric space-padded,
+// LLVAR / LLLVAR carry a 2 / 3 digit ASCII length first.
+// On TCP every message is prefixed with a 2-byte big-endian length.
+//
+//	Field  Name                        Format
+//	-----  --------------------------  ---------
+//	2      PAN (card number)           ans..19 LLVAR (demo cards are "CARD-001")
+//	3      processing code             n6
+//	4      amount, paise               n12
+//	7      transmission date & time    n10 MMDDhhmmss (UTC)
+//	11     STAN (trace number)         n6
+//	37     retrieval reference number  an12
+//	38     authorization id            an6
+//	39     response code               an2
+//	41     terminal id                 ans8
+//	52     PIN block (ISO 9564-0)      b8   plain here, encrypted in real life
+//	54     additional amounts          ans..120 LLLVAR
+//	90     original data elements      n42
+//	102    account id                  ans..28 LLVAR
+//	125    new PIN block (PIN change)  b8   network-private use, 52 = current PIN
+// ============================================
+
+var (
+	ErrISOFormat     = errors.New("malformed ISO 8583 message")
+	ErrISOFieldValue = errors.New("invalid ISO 8583 field value")
+)
+
+const (
+	MTIAuthRequest       = "0100"
+	MTIAuthResponse      = "0110"
+	MTIFinancialRequest  = "0200"
+	MTIFinancialResponse = "0210"
+	MTIReversalAdvice    = "0420"
+	MTIReversalResponse  = "0430"
+)
+
+// Processing codes (field 3), first 2 digits = transaction type
+const (
+	ProcWithdrawal = "010000"
+	ProcDeposit    = "210000"
+	ProcPinVerify  = "310000" // balance inquiry, PIN checked, nothing moves
+	ProcPinChange  = "920000"
+)
+
+// Response codes (field 39)
+const (
+	RCApproved          = "00"
+	RCInvalidTxn        = "12"
+	RCNoSuchAccount     = "14"
+	RCOriginalNotFound  = "25"
+	RCInsufficientFunds = "51"
+	RCIncorrectPIN      = "55"
+	RCDuplicate         = "94"
+	RCSystemError       = "96"
+)
+
+type isoFieldType int
+
+const (
+	isoNumeric isoFieldType = iota // digits, left-padded with 0
+	isoAlnum                       // printable ASCII, right-padded with spaces
+	isoBinary                      // raw bytes
+)
+
+type isoFieldSpec struct {
+	Name   string
+	Type   isoFieldType
+	Length int // fixed length, or max length when LenLen > 0
+	LenLen int // 0 = fixed, 2 = LLVAR, 3 = LLLVAR
+}
+
+var isoFields = map[int]isoFieldSpec{
+	2:   {Name: "PAN", Type: isoAlnum, Length: 19, LenLen: 2},
+	3:   {Name: "processing code", Type: isoNumeric, Length: 6},
+	4:   {Name: "amount", Type: isoNumeric, Length: 12},
+	7:   {Name: "transmission time", Type: isoNumeric, Length: 10},
+	11:  {Name: "STAN", Type: isoNumeric, Length: 6},
+	37:  {Name: "RRN", Type: isoAlnum, Length: 12},
+	38:  {Name: "auth id", Type: isoAlnum, Length: 6},
+	39:  {Name: "response code", Type: isoAlnum, Length: 2},
+	41:  {Name: "terminal id", Type: isoAlnum, Length: 8},
+	52:  {Name: "PIN block", Type: isoBinary, Length: 8},
+	54:  {Name: "additional amounts", Type: isoAlnum, Length: 120, LenLen: 3},
+	90:  {Name: "original data", Type: isoNumeric, Length: 42},
+	102: {Name: "account id", Type: isoAlnum, Length: 28, LenLen: 2},
+	125: {Name: "new PIN block", Type: isoBinary, Length: 8},
+}
+
+// ISOMessage - MTI + field values (binary fields as raw bytes in a string)
+type ISOMessage struct {
+	MTI    string
+	fields map[int]string
+}
+
+func NewISOMessage(mti string) *ISOMessage {
+	return &ISOMessage{MTI: mti, fields: make(map[int]string)}
+}
+
+func (m *ISOMessage) Set(field int, value string) *ISOMessage {
+	m.fields[field] = value
+	return m
+}
+
+func (m *ISOMessage) Get(field int) string {
+	return m.fields[field]
+}
+
+func (m *ISOMessage) Has(field int) bool {
+	_, ok := m.fields[field]
+	return ok
+}
+
+func (m *ISOMessage) Fields() []int {
+	fields := make([]int, 0, len(m.fields))
+	for field := range m.fields {
+		fields = append(fields, field)
+	}
+	sort.Ints(fields)
+	return fields
+}
+
+// String - for traces: PIN block never printed
+func (m *ISOMessage) String() string {
+	var b strings.Builder
+	b.WriteString(m.MTI)
+	for _, field := range m.Fields() {
+		value := m.fields[field]
+		switch field {
+		case 52, 125:
+			value = "****"
+		case 2:
+			value = maskCard(value)
+		}
+		fmt.Fprintf(&b, " %d=%s", field, strings.TrimSpace(value))
+	}
+	return b.String()
+}
+
+// Pack - message => bytes, every field checked against isoFields
+func (m *ISOMessage) Pack() ([]byte, error) {
+	if !isDigits(m.MTI) || len(m.MTI) != 4 {
+		return nil, fmt.Errorf("%w: MTI %q", ErrISOFieldValue, m.MTI)
+	}
+
+	fields := m.Fields()
+	bitmap := make([]byte, 8)
+	if len(fields) > 0 && fields[len(fields)-1] > 64 {
+		bitmap = make([]byte, 16)
+		bitmap[0] |= 0x80 // bit 1: secondary bitmap present
+	}
+
+	var body []byte
+	for _, field := range fields {
+		spec, ok := isoFields[field]
+		if !ok || field > len(bitmap)*8 {
+			return nil, fmt.Errorf("%w: field %d not supported", ErrISOFieldValue, field)
+		}
+		encoded, err := encodeField(field, spec, m.fields[field])
+		if err != nil {
+			return nil, err
+		}
+		bitmap[(field-1)/8] |= 0x80 >> uint((field-1)%8)
+		body = append(body, encoded...)
+	}
+
+	out := append([]byte(m.MTI), bitmap...)
+	return append(out, body...), nil
+}
+
+func encodeField(field int, spec isoFieldSpec, value string) ([]byte, error) {
+	bad := func(why string) error {
+		return fmt.Errorf("%w: field %d (%s) %s", ErrISOFieldValue, field, spec.Name, why)
+	}
+
+	switch spec.Type {
+	case isoNumeric:
+		if !isDigits(value) {
+			return nil, bad("not numeric")
+		}
+	case isoAlnum:
+		for _, c := range value {
+			if c < 0x20 || c > 0x7e {
+				return nil, bad("not printable ASCII")
+			}
+		}
+	}
+	if len(value) > spec.Length {
+		return nil, bad(fmt.Sprintf("longer than %d", spec.Length))
+	}
+
+	if spec.LenLen > 0 {
+		return append([]byte(fmt.Sprintf("%0*d", spec.LenLen, len(value))), value...), nil
+	}
+	switch spec.Type {
+	case isoNumeric:
+		value = strings.Repeat("0", spec.Length-len(value)) + value
+	case isoAlnum:
+		value += strings.Repeat(" ", spec.Length-len(value))
+	case isoBinary:
+		if len(value) != spec.Length {
+			return nil, bad(fmt.Sprintf("must be %d bytes", spec.Length))
+		}
+	}
+	return []byte(value), nil
+}
+
+// UnpackISOMessage - bytes => message; anything short, unknown or
+// trailing is ErrISOFormat (never trust what came off the wire)
+func UnpackISOMessage(data []byte) (*ISOMessage, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("%w: %d bytes", ErrISOFormat, len(data))
+	}
+	mti := string(data[:4])
+	if !isDigits(mti) {
+		return nil, fmt.Errorf("%w: MTI %q", ErrISOFormat, mti)
+	}
+
+	bitmap, pos := data[4:12], 12
+	if bitmap[0]&0x80 != 0 {
+		if len(data) < 20 {
+			return nil, fmt.Errorf("%w: secondary bitmap cut off", ErrISOFormat)
+		}
+		bitmap, pos = data[4:20], 20
+	}
+
+	msg := NewISOMessage(mti)
+	for field := 2; field <= len(bitmap)*8; field++ {
+		if bitmap[(field-1)/8]&(0x80>>uint((field-1)%8)) == 0 {
+			continue
+		}
+		spec, ok := isoFields[field]
+		if !ok {
+			return nil, fmt.Errorf("%w: field %d not supported", ErrISOFormat, field)
+		}
+
+		length := spec.Length
+		if spec.LenLen > 0 {
+			if pos+spec.LenLen > len(data) {
+				return nil, fmt.Errorf("%w: field %d length cut off", ErrISOFormat, field)
+			}
+			n, err := strconv.Atoi(string(data[pos : pos+spec.LenLen]))
+			if err != nil || n < 0 || n > spec.Length {
+				return nil, fmt.Errorf("%w: field %d length %q", ErrISOFormat, field, data[pos:pos+spec.LenLen])
+			}
+			length, pos = n, pos+spec.LenLen
+		}
+		if pos+length > len(data) {
+			return nil, fmt.Errorf("%w: field %d cut off", ErrISOFormat, field)
+		}
+		value := string(data[pos : pos+length])
+		pos += length
+
+		if spec.Type == isoNumeric && !isDigits(value) {
+			return nil, fmt.Errorf("%w: field %d not numeric", ErrISOFormat, field)
+		}
+		if spec.Type == isoAlnum && spec.LenLen == 0 {
+			value = strings.TrimRight(value, " ")
+		}
+		msg.fields[field] = value
+	}
+	if pos != len(data) {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrISOFormat, len(data)-pos)
+	}
+	return msg, nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ============================================
+// FIELD HELPERS
+// ============================================
+
+func isoAmount(amount Money) string {
+	return fmt.Sprintf("%012d", amount.Amount)
+}
+
+func parseISOAmount(value string) (Money, error) {
+	paise, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || paise < 0 {
+		return Money{}, fmt.Errorf("%w: amount %q", ErrISOFieldValue, value)
+	}
+	return Paise(paise), nil
+}
+
+func isoTime(t time.Time) string {
+	return t.UTC().Format("0102150405")
+}
+
+// isoBalance - field 54: account type 00, amount type 02 (available),
+// currency 356 (INR), sign C/D, amount n12
+func isoBalance(balance Money) string {
+	sign, amount := "C", balance.Amount
+	if amount < 0 {
+		sign, amount = "D", -amount
+	}
+	return fmt.Sprintf("0002356%s%012d", sign, amount)
+}
+
+// originalData - field 90 of a reversal: original MTI, STAN and
+// transmission time, acquirer / forwarding institution ids zeroed
+func originalData(original *ISOMessage) string {
+	return original.MTI + original.Get(11) + original.Get(7) + strings.Repeat("0", 22)
+}
+
+// ============================================
+// TCP FRAMING
+// ============================================
+
+func writeISOFrame(w io.Writer, msg *ISOMessage) error {
+	data, err := msg.Pack()
+	if err != nil {
+		return err
+	}
+	if len(data) > 0xFFFF {
+		return fmt.Errorf("%w: %d bytes", ErrISOFormat, len(data))
+	}
+	frame := make([]byte, 2, 2+len(data))
+	binary.BigEndian.PutUint16(frame, uint16(len(data)))
+	_, err = w.Write(append(frame, data...))
+	return err
+}
+
+func readISOFrame(r io.Reader) (*ISOMessage, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint16(header))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return UnpackISOMessage(data)
+}
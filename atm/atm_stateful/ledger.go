@@ -0,0 +1,403 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================
+// DOUBLE-ENTRY LEDGER
+// Balances are no longer fields somebody mutates: every money movement
+// is an Entry of postings whose debits equal its credits, and a balance
+// is the sum of the postings on that account.
+//
+//	Account             Type       Normal side
+//	------------------  ---------  -----------
+//	customer:<id>       liability  credit       what the bank owes the customer (available)
+//	hold:<id>           liability  credit       reserved by in-flight withdrawals
+//	atm-cash:<atmId>    asset      debit        cash inside the machine
+//	bank:reserves       asset      debit        vault / central bank, opening balances
+//
+//	Flow                 Debit              Credit
+//	-------------------  -----------------  -----------------
+//	open account         bank:reserves      customer:<id>
+//	load ATM             atm-cash:<atm>     bank:reserves
+//	deposit              atm-cash:<atm>     customer:<id>
+//	withdraw: reserve    customer:<id>      hold:<id>
+//	withdraw: release    hold:<id>          customer:<id>
+//	withdraw: confirm    hold:<id>          atm-cash:<atm>
+//	transfer             customer:<from>    customer:<to>
+//
+// Every entry carries Ref = the Transaction id => any balance can be
+// traced back to the transactions that made it (see Reconcile)
+// ============================================
+
+var (
+	ErrUnbalancedEntry = errors.New("ledger entry debits do not equal credits")
+	ErrInvalidPosting  = errors.New("invalid ledger posting")
+)
+
+type LedgerAccount string
+
+const BankReserves LedgerAccount = "bank:reserves"
+
+func CustomerAccount(accountId string) LedgerAccount { return LedgerAccount("customer:" + accountId) }
+func HoldAccount(accountId string) LedgerAccount     { return LedgerAccount("hold:" + accountId) }
+func ATMCashAccount(atmId string) LedgerAccount      { return LedgerAccount("atm-cash:" + atmId) }
+
+// isAsset - assets grow with debits, liabilities with credits
+func (a LedgerAccount) isAsset() bool {
+	return strings.HasPrefix(string(a), "atm-cash:") || strings.HasPrefix(string(a), "bank:")
+}
+
+type Side string
+
+const (
+	DebitSide  Side = "DR"
+	CreditSide Side = "CR"
+)
+
+type Posting struct {
+	Account LedgerAccount
+	Side    Side
+	Amount  Money
+}
+
+func Debit(account LedgerAccount, amount Money) Posting {
+	return Posting{Account: account, Side: DebitSide, Amount: amount}
+}
+
+func Credit(account LedgerAccount, amount Money) Posting {
+	return Posting{Account: account, Side: CreditSide, Amount: amount}
+}
+
+type Entry struct {
+	Id       int
+	Ref      string // Transaction id / OPEN-<account> / LOAD-<atm>
+	Memo     string
+	Time     time.Time
+	Postings []Posting
+}
+
+type Ledger struct {
+	currency Currency
+	entries  []Entry
+	balances map[LedgerAccount]Money // running totals, Replay recomputes them
+	mu       sync.RWMutex
+}
+
+func NewLedger(currency Currency) *Ledger {
+	return &Ledger{currency: currency, balances: make(map[LedgerAccount]Money)}
+}
+
+// Currency - fixed at NewLedger
+func (l *Ledger) Currency() Currency {
+	return l.currency
+}
+
+// Post - all postings or none
+func (l *Ledger) Post(ref, memo string, postings ...Posting) (*Entry, error) {
+	if len(postings) < 2 {
+		return nil, fmt.Errorf("%w: need at least 2 postings", ErrInvalidPosting)
+	}
+	var debits, credits int64
+	for _, p := range postings {
+		if p.Amount.Currency != l.currency {
+			return nil, fmt.Errorf("%w: %s posting in a %s ledger", ErrCurrencyMismatch, p.Amount.Currency, l.currency)
+		}
+		if !p.Amount.IsPositive() {
+			return nil, fmt.Errorf("%w: %s %s %v", ErrInvalidPosting, p.Side, p.Account, p.Amount)
+		}
+		switch p.Side {
+		case DebitSide:
+			debits += p.Amount.Amount
+		case CreditSide:
+			credits += p.Amount.Amount
+		default:
+			return nil, fmt.Errorf("%w: side %q", ErrInvalidPosting, p.Side)
+		}
+	}
+	if debits != credits {
+		return nil, fmt.Errorf("%w: %v vs %v", ErrUnbalancedEntry, Paise(debits), Paise(credits))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := Entry{
+		Id:       len(l.entries) + 1,
+		Ref:      ref,
+		Memo:     memo,
+		Time:     time.Now(),
+		Postings: append([]Posting(nil), postings...),
+	}
+	l.entries = append(l.entries, entry)
+	for _, p := range postings {
+		l.balances[p.Account] = l.balances[p.Account].Add(signed(p))
+	}
+	return &entry, nil
+}
+
+// signed - posting's effect on its account's balance (normal side +)
+func signed(p Posting) Money {
+	if (p.Side == DebitSide) == p.Account.isAsset() {
+		return p.Amount
+	}
+	return p.Amount.Neg()
+}
+
+func (l *Ledger) Balance(account LedgerAccount) Money {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.balances[account].Add(Money{Currency: l.currency})
+}
+
+// Entries - every entry touching account, oldest first (all for "")
+func (l *Ledger) Entries(account LedgerAccount) []Entry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var out []Entry
+	for _, entry := range l.entries {
+		for _, p := range entry.Postings {
+			if account == "" || p.Account == account {
+				out = append(out, entry)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Replay - balances recomputed from scratch, must equal the running ones
+func (l *Ledger) Replay() map[LedgerAccount]Money {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	balances := make(map[LedgerAccount]Money)
+	for _, entry := range l.entries {
+		for _, p := range entry.Postings {
+			balances[p.Account] = balances[p.Account].Add(signed(p))
+		}
+	}
+	return balances
+}
+
+// TrialBalance - total debits and credits over all entries
+func (l *Ledger) TrialBalance() (debits, credits Money) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	debits, credits = Money{Currency: l.currency}, Money{Currency: l.currency}
+	for _, entry := range l.entries {
+		for _, p := range entry.Postings {
+			if p.Side == DebitSide {
+				debits = debits.Add(p.Amount)
+			} else {
+				credits = credits.Add(p.Amount)
+			}
+		}
+	}
+	return debits, credits
+}
+
+// NetByRef - net effect of the entries with ref on the given accounts
+func (l *Ledger) NetByRef(ref string, accounts ...LedgerAccount) Money {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	net := Money{Currency: l.currency}
+	for _, entry := range l.entries {
+		if entry.Ref != ref {
+			continue
+		}
+		for _, p := range entry.Postings {
+			for _, account := range accounts {
+				if p.Account == account {
+					net = net.Add(signed(p))
+				}
+			}
+		}
+	}
+	return net
+}
+
+// RecordCashLoad - notes moved from the vault into an ATM (initial load,
+// replenishment); ref LOAD-<atm>-... keeps it apart from customer refs
+func (l *Ledger) RecordCashLoad(ref, atmId string, amount Money) error {
+	_, err := l.Post(ref, "cash loaded into "+atmId, Debit(ATMCashAccount(atmId), amount), Credit(BankReserves, amount))
+	return err
+}
+
+// ============================================
+// RECONCILIATION
+// What an auditor asks for at end of day:
+//  1. ledger balanced        total debits = total credits
+//  2. balances derivable     running balances = replay of all postings
+//  3. ATM cash               ledger atm-cash = notes physically counted
+//  4. every transaction      COMPLETED moved exactly its amount on the
+//     customer's accounts, REVERSED moved nothing, PENDING listed
+//  5. nothing untraceable    every customer posting has a known Ref
+// ============================================
+
+type ReconciliationReport struct {
+	Lines  []string
+	Issues []string
+}
+
+func (r *ReconciliationReport) OK() bool { return len(r.Issues) == 0 }
+
+func (r *ReconciliationReport) line(format string, args ...interface{}) {
+	r.Lines = append(r.Lines, fmt.Sprintf(format, args...))
+}
+
+func (r *ReconciliationReport) issue(format string, args ...interface{}) {
+	r.Issues = append(r.Issues, fmt.Sprintf(format, args...))
+}
+
+func (r *ReconciliationReport) String() string {
+	var b strings.Builder
+	for _, line := range r.Lines {
+		fmt.Fprintf(&b, "   %s\n", line)
+	}
+	if r.OK() {
+		b.WriteString("   ✅ reconciled, no issues\n")
+	}
+	for _, issue := range r.Issues {
+		fmt.Fprintf(&b, "   ❗ %s\n", issue)
+	}
+	return b.String()
+}
+
+// CountedCash - what a cash count of the machine finds
+type CountedCash interface {
+	CountedCash() Money
+}
+
+func Reconcile(ledger *Ledger, transactions TransactionService, accountIds []string, atms map[string]CountedCash) *ReconciliationReport {
+	report := &ReconciliationReport{}
+
+	// 1. balanced
+	debits, credits := ledger.TrialBalance()
+	report.line("Trial balance: debits %v, credits %v", debits, credits)
+	if debits != credits {
+		report.issue("ledger out of balance by %v", debits.Sub(credits))
+	}
+
+	// 2. derivable
+	replayed := ledger.Replay()
+	for account, balance := range replayed {
+		if running := ledger.Balance(account); running != balance.Add(Money{Currency: running.Currency}) {
+			report.issue("%s: running balance %v but postings sum to %v", account, running, balance)
+		}
+	}
+
+	// 3. ATM cash
+	atmIds := make([]string, 0, len(atms))
+	for atmId := range atms {
+		atmIds = append(atmIds, atmId)
+	}
+	sort.Strings(atmIds)
+	for _, atmId := range atmIds {
+		booked, counted := ledger.Balance(ATMCashAccount(atmId)), atms[atmId].CountedCash()
+		report.line("%-14s ledger %v, counted %v", atmId, booked, counted)
+		if booked != counted {
+			report.issue("%s: cash counted %v, ledger says %v (diff %v)", atmId, counted, booked, counted.Sub(booked))
+		}
+	}
+
+	// 4 + 5. transactions vs postings
+	sort.Strings(accountIds)
+	for _, accountId := range accountIds {
+		customer, hold := CustomerAccount(accountId), HoldAccount(accountId)
+		report.line("%-14s available %v, on hold %v", accountId, ledger.Balance(customer), ledger.Balance(hold))
+
+		known := map[string]bool{"OPEN-" + accountId: true}
+		history, _ := transactions.GetTransactionHistory(accountId)
+		for _, txn := range history {
+			ref := ledgerRef(txn)
+			known[ref] = true
+
+			moved := ledger.NetByRef(ref, customer, hold)
+			want := Money{Currency: moved.Currency}
+			switch {
+			case txn.Status == Pending:
+				report.issue("%s %s %v still PENDING (%s), moved %v so far", accountId, txn.Type, txn.Amount, txn.Reason, moved)
+				continue
+			case txn.Status != Completed:
+				// REVERSED / FAILED => nothing
+			case txn.Type == Deposit || txn.Type == TransferIn:
+				want = txn.Amount
+			case txn.Type == Withdraw || txn.Type == TransferOut:
+				want = txn.Amount.Neg()
+			}
+			if moved != want {
+				report.issue("%s %s %s %v: ledger moved %v, expected %v", accountId, txn.Id, txn.Type, txn.Status, moved, want)
+			}
+		}
+
+		for _, entry := range ledger.Entries(customer) {
+			if !known[entry.Ref] {
+				report.issue("%s: ledger entry #%d (%s) has no transaction", accountId, entry.Id, entry.Ref)
+			}
+		}
+	}
+	return report
+}
+
+// ledgerRef - both rows of a transfer share one ledger entry, posted
+// under the payer's transaction id (see CreateTransfer)
+func ledgerRef(txn *Transaction) string {
+	if txn.Type == TransferIn {
+		return strings.TrimSuffix(txn.Id, "-IN")
+	}
+	return txn.Id
+}
+
+// ============================================
+// LEDGER DEMO
+// ============================================
+
+func testLedger(ledger *Ledger, transactions TransactionService, accounts *AccountServiceV1, atms map[string]CountedCash) {
+	fmt.Println("🧮 Why integers: ten ₹0.10 fees")
+	floatTotal, moneyTotal := 0.0, Paise(0)
+	for i := 0; i < 10; i++ {
+		floatTotal += 0.10
+		moneyTotal = moneyTotal.Add(Paise(10))
+	}
+	fmt.Printf("   float64: %.17f (== 1.0? %v)\n", floatTotal, floatTotal == 1.0)
+	fmt.Printf("   Money:   %v (== ₹1.00? %v)\n", moneyTotal, moneyTotal == Rupees(1))
+	if _, err := ParseRupees(10.005); err != nil {
+		fmt.Println("   ✅ keypad ₹10.005 rejected:", err)
+	}
+	fmt.Println()
+
+	fmt.Println("📒 Entries touching customer:ACC-001")
+	for _, entry := range ledger.Entries(CustomerAccount("ACC-001")) {
+		fmt.Printf("   #%-3d %-24s %-16s", entry.Id, entry.Ref, entry.Memo)
+		for _, p := range entry.Postings {
+			fmt.Printf("  %s %s %v", p.Side, p.Account, p.Amount)
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+
+	fmt.Println("🔎 End-of-day reconciliation, ATM-001")
+	fmt.Print(Reconcile(ledger, transactions, accounts.AccountIds(), atms))
+	fmt.Println()
+
+	fmt.Println("🔎 Reconciliation after a stuck withdraw (confirm failed, cash out)")
+	rig := newSagaRig("confirm")
+	rig.atm.Withdraw(rig.session, Rupees(1500))
+	fmt.Print(Reconcile(rig.ledger, rig.txns, rig.accounts.AccountIds(), map[string]CountedCash{rig.atm.ATMid: rig.dispenser}))
+	fmt.Println()
+
+	fmt.Println("🔎 ...and after someone edits a balance behind the ledger's back")
+	rig = newSagaRig("")
+	rig.ledger.Post("ADJ-1", "manual fix", Debit(BankReserves, Rupees(100)), Credit(CustomerAccount(rig.account.Id), Rupees(100)))
+	fmt.Print(Reconcile(rig.ledger, rig.txns, rig.accounts.AccountIds(), map[string]CountedCash{rig.atm.ATMid: rig.dispenser}))
+}
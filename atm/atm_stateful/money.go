@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ============================================
+// MONEY
+// Integer minor units + currency. float64 rupees drifted:
+//
+//	0.1 + 0.2 = 0.30000000000000004   => ₹ balances that never reconcile
+//
+// Money{Amount: 1050, Currency: INR} is exactly ₹10.50. Floats are only
+// accepted at the edge (keypad, note faces) and converted once, rejecting
+// anything that isn't a whole number of paise.
+// ============================================
+
+type Currency string
+
+const INR Currency = "INR"
+
+var (
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrSubMinorUnit     = errors.New("amount has fractions of a paisa")
+)
+
+type Money struct {
+	Amount   int64 // minor units (paise)
+	Currency Currency
+}
+
+func Rupees(rupees int64) Money {
+	return Money{Amount: rupees * 100, Currency: INR}
+}
+
+func Paise(paise int64) Money {
+	return Money{Amount: paise, Currency: INR}
+}
+
+// ParseRupees - keypad / float input => Money, only whole paise
+func ParseRupees(rupees float64) (Money, error) {
+	paise := math.Round(rupees * 100)
+	if math.Abs(paise-rupees*100) > 1e-6 || math.IsNaN(rupees) || math.IsInf(rupees, 0) {
+		return Money{}, fmt.Errorf("%w: %v", ErrSubMinorUnit, rupees)
+	}
+	return Paise(int64(paise)), nil
+}
+
+// NoteValue - count notes of a face value (note faces are whole rupees)
+func NoteValue(denom float64, count int) Money {
+	return Paise(int64(math.Round(denom*100)) * int64(count))
+}
+
+// checkCurrency - amounts from outside (API, keypad) are checked where
+// they enter ATMService / AccountService, so the arithmetic below never
+// sees two currencies. No currency at all is refused too: ambiguous
+func checkCurrency(amount Money, want Currency) error {
+	if amount.Currency != want {
+		return fmt.Errorf("%w: %v given, %s expected", ErrCurrencyMismatch, amount, want)
+	}
+	return nil
+}
+
+// compatible - zero value Money{} (no currency yet) adds to anything,
+// so `var total Money` works as an accumulator
+func (m Money) compatible(other Money) Currency {
+	switch {
+	case m.Currency == other.Currency || other.Currency == "":
+		return m.Currency
+	case m.Currency == "":
+		return other.Currency
+	}
+	// inputs went through checkCurrency => mixing here is a programming
+	// error, like an index out of range
+	panic(fmt.Errorf("%w: %s vs %s", ErrCurrencyMismatch, m.Currency, other.Currency))
+}
+
+func (m Money) Add(other Money) Money {
+	return Money{Amount: m.Amount + other.Amount, Currency: m.compatible(other)}
+}
+
+func (m Money) Sub(other Money) Money {
+	return Money{Amount: m.Amount - other.Amount, Currency: m.compatible(other)}
+}
+
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+func (m Money) Times(n int) Money {
+	return Money{Amount: m.Amount * int64(n), Currency: m.Currency}
+}
+
+// Cmp - -1, 0, +1
+func (m Money) Cmp(other Money) int {
+	m.compatible(other)
+	switch {
+	case m.Amount < other.Amount:
+		return -1
+	case m.Amount > other.Amount:
+		return 1
+	}
+	return 0
+}
+
+func (m Money) LessThan(other Money) bool    { return m.Cmp(other) < 0 }
+func (m Money) GreaterThan(other Money) bool { return m.Cmp(other) > 0 }
+func (m Money) IsZero() bool                 { return m.Amount == 0 }
+func (m Money) IsPositive() bool             { return m.Amount > 0 }
+
+var currencySymbols = map[Currency]string{INR: "₹"}
+
+// String - ₹1234.50, -₹20.00
+func (m Money) String() string {
+	sign, amount := "", m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	symbol, ok := currencySymbols[m.Currency]
+	if !ok && m.Currency != "" {
+		symbol = string(m.Currency) + " "
+	}
+	return fmt.Sprintf("%s%s%d.%02d", sign, symbol, amount/100, amount%100)
+}
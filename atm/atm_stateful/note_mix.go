@@ -0,0 +1,296 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// ============================================
+// NOTE MIX - which notes make up an amount
+// Old planNotes was greedy, largest note first:
+//
+//	inventory 500×10, 200×20   amount 600
+//	greedy:  500 → 100 left, 200 doesn't fit → ErrCannotDispense
+//	DP:      200×3 ✓
+//
+// Now a bounded knapsack over the cassettes: best[s] = cheapest way to
+// make s from the denominations seen so far, each denomination used at
+// most as often as the cassette holds => finds a mix whenever one exists.
+// "Cheapest" is decided by a MixObjective, and a customer can ask for
+// some notes up front ("2×100 please"), the DP fills the rest.
+// ============================================
+
+var ErrInvalidNoteMix = errors.New("requested note mix is not possible")
+
+// MixObjective - cost of taking `used` notes out of a cassette holding
+// `available`. Costs are summed over cassettes, lowest total wins.
+// Cost(denom, 0, available) must be 0
+type MixObjective interface {
+	Name() string
+	Cost(denom float64, used, available int) float64
+}
+
+// FewestNotes - smallest bundle, what greedy tried to do
+type FewestNotes struct{}
+
+func (FewestNotes) Name() string { return "fewest notes" }
+
+func (FewestNotes) Cost(denom float64, used, available int) float64 {
+	return float64(used)
+}
+
+// BalancedDepletion - drain cassettes evenly: taking a share of a nearly
+// empty cassette costs more than the same share of a full one, so scarce
+// notes are kept for the amounts that need them. Squared => many small
+// bites from different cassettes beat one big bite from one
+type BalancedDepletion struct{}
+
+func (BalancedDepletion) Name() string { return "balanced depletion" }
+
+func (BalancedDepletion) Cost(denom float64, used, available int) float64 {
+	if used == 0 {
+		return 0
+	}
+	share := float64(used) / float64(available)
+	return share*share + 0.001*float64(used) // tie-break: fewer notes
+}
+
+// PlanNoteMix - notes to hand out for amount.
+// requested = notes the customer asked for (at least that many of each),
+// nil = let the objective pick everything
+func PlanNoteMix(amount Money, inventory, requested map[float64]int, objective MixObjective) (map[float64]int, error) {
+	if objective == nil {
+		objective = FewestNotes{}
+	}
+
+	// Customer's notes first
+	remaining := amount
+	for denom, count := range requested {
+		if count < 0 || count > inventory[denom] {
+			return nil, fmt.Errorf("%w: %d × %.0f, ATM has %d", ErrInvalidNoteMix, count, denom, inventory[denom])
+		}
+		remaining = remaining.Sub(NoteValue(denom, count))
+	}
+	if remaining.Amount < 0 {
+		return nil, fmt.Errorf("%w: requested notes add up to more than %v", ErrInvalidNoteMix, amount)
+	}
+
+	// Everything in whole units (gcd of the denominations) => int DP
+	var denoms []float64
+	for denom, count := range inventory {
+		if count-requested[denom] > 0 {
+			denoms = append(denoms, denom)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(denoms)))
+
+	unit := int64(0)
+	for _, denom := range denoms {
+		unit = gcd(unit, toPaise(denom))
+	}
+	target := remaining.Amount
+	if target == 0 {
+		return withRequested(nil, requested), nil
+	}
+	if unit == 0 || target%unit != 0 {
+		return nil, ErrCannotDispense
+	}
+	size := int(target / unit)
+
+	// best[s] = min cost for sum s, take[i][s] = notes of denoms[i] used
+	inf := math.Inf(1)
+	best := make([]float64, size+1)
+	for s := 1; s <= size; s++ {
+		best[s] = inf
+	}
+	take := make([][]int, len(denoms))
+
+	for i, denom := range denoms {
+		step := int(toPaise(denom) / unit)
+		asked := requested[denom]
+		available := inventory[denom]
+		maxExtra := available - asked
+
+		next := make([]float64, size+1)
+		take[i] = make([]int, size+1)
+		for s := 0; s <= size; s++ {
+			next[s] = inf
+			for c := 0; c <= maxExtra && c*step <= s; c++ {
+				prev := best[s-c*step]
+				if math.IsInf(prev, 1) {
+					continue
+				}
+				cost := prev + objective.Cost(denom, asked+c, available) - objective.Cost(denom, asked, available)
+				if cost < next[s] {
+					next[s] = cost
+					take[i][s] = c
+				}
+			}
+		}
+		best = next
+	}
+
+	if math.IsInf(best[size], 1) {
+		return nil, ErrCannotDispense
+	}
+
+	// Walk back through the choices
+	used := make(map[float64]int)
+	s := size
+	for i := len(denoms) - 1; i >= 0; i-- {
+		c := take[i][s]
+		if c > 0 {
+			used[denoms[i]] = c
+		}
+		s -= c * int(toPaise(denoms[i])/unit)
+	}
+	return withRequested(used, requested), nil
+}
+
+func withRequested(used, requested map[float64]int) map[float64]int {
+	if used == nil {
+		used = make(map[float64]int)
+	}
+	for denom, count := range requested {
+		if count > 0 {
+			used[denom] += count
+		}
+	}
+	return used
+}
+
+// toPaise - ₹ amounts as integer paise, no float drift in the DP
+func toPaise(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// ============================================
+// NOTE MIX DEMO
+// The greedy failure, both objectives side by side, a customer mix and
+// a randomized property check: every plan must add up exactly, stay
+// within the inventory, and exist whenever brute force finds a mix
+// ============================================
+
+func testNoteMix() {
+	show := func(label string, rupees int64, inventory, requested map[float64]int, objective MixObjective) {
+		amount := Rupees(rupees)
+		notes, err := PlanNoteMix(amount, inventory, requested, objective)
+		if err != nil {
+			fmt.Printf("   ❌ %-34s %v: %v\n", label, amount, err)
+			return
+		}
+		fmt.Printf("   ✅ %-34s %v = %s\n", label, amount, formatNotes(notes))
+	}
+
+	fmt.Println("💵 Note mix for 500×10, 200×20")
+	twoCassettes := map[float64]int{500: 10, 200: 20}
+	show("600 (greedy said cannot dispense)", 600, twoCassettes, nil, FewestNotes{})
+	show("900", 900, twoCassettes, nil, FewestNotes{})
+	show("300 (no such mix)", 300, twoCassettes, nil, FewestNotes{})
+	fmt.Println()
+
+	fmt.Println("⚖️  Objectives for 500×2, 200×30, 100×40 (500s are scarce)")
+	lowOn500 := map[float64]int{500: 2, 200: 30, 100: 40}
+	show(FewestNotes{}.Name(), 1000, lowOn500, nil, FewestNotes{})
+	show(BalancedDepletion{}.Name(), 1000, lowOn500, nil, BalancedDepletion{})
+	fmt.Println()
+
+	fmt.Println("🙋 Customer-requested mix")
+	show("2×100 please, rest any", 1200, lowOn500, map[float64]int{100: 2}, FewestNotes{})
+	show("5×500 please (ATM has 2)", 2500, lowOn500, map[float64]int{500: 5}, FewestNotes{})
+	fmt.Println()
+
+	fmt.Println("🎲 Property check: 2,000 random inventories / amounts")
+	rng := rand.New(rand.NewSource(42))
+	allDenoms := []float64{2000, 500, 200, 100, 50}
+	failures := 0
+	for i := 0; i < 2000; i++ {
+		inventory := map[float64]int{}
+		for _, denom := range allDenoms {
+			if rng.Intn(3) > 0 {
+				inventory[denom] = rng.Intn(6)
+			}
+		}
+		amount := Rupees(int64(50 * (1 + rng.Intn(80))))
+		objective := []MixObjective{FewestNotes{}, BalancedDepletion{}}[i%2]
+
+		notes, err := PlanNoteMix(amount, inventory, nil, objective)
+		feasible := bruteForceFeasible(amount, inventory, allDenoms)
+
+		switch {
+		case err != nil && feasible:
+			failures++
+			checkFailed("   ❌ %v from %v: %v, but a mix exists\n", amount, inventory, err)
+		case err == nil && !feasible:
+			failures++
+			checkFailed("   ❌ %v from %v: got %v, brute force found none\n", amount, inventory, notes)
+		case err == nil:
+			if problem := checkPlan(amount, inventory, notes); problem != "" {
+				failures++
+				checkFailed("   ❌ %v from %v: %s\n", amount, inventory, problem)
+			}
+		}
+	}
+	if failures == 0 {
+		fmt.Println("   ✅ exact sums, within inventory, no missed mixes")
+	}
+}
+
+// checkPlan - "" when notes add up to amount without overdrawing a cassette
+func checkPlan(amount Money, inventory, notes map[float64]int) string {
+	for denom, count := range notes {
+		if count < 0 || count > inventory[denom] {
+			return fmt.Sprintf("%d × %.0f but cassette holds %d", count, denom, inventory[denom])
+		}
+	}
+	if total := notesTotal(notes); total != amount {
+		return fmt.Sprintf("notes add up to %v", total)
+	}
+	return ""
+}
+
+func bruteForceFeasible(amount Money, inventory map[float64]int, denoms []float64) bool {
+	var try func(i int, left int64) bool
+	try = func(i int, left int64) bool {
+		if left == 0 {
+			return true
+		}
+		if i == len(denoms) || left < 0 {
+			return false
+		}
+		for c := 0; c <= inventory[denoms[i]]; c++ {
+			if try(i+1, left-NoteValue(denoms[i], c).Amount) {
+				return true
+			}
+		}
+		return false
+	}
+	return try(0, amount.Amount)
+}
+
+func formatNotes(notes map[float64]int) string {
+	var denoms []float64
+	for denom := range notes {
+		denoms = append(denoms, denom)
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(denoms)))
+
+	out := ""
+	for i, denom := range denoms {
+		if i > 0 {
+			out += " + "
+		}
+		out += fmt.Sprintf("%.0f×%d", denom, notes[denom])
+	}
+	return out
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ============================================
+// TRANSFER / MINI STATEMENT / CHANGE PIN
+//
+//	OpTransfer       PIN_VALIDATED → PAYEE_ENTRY → AMOUNT_ENTRY → READY_TO_EXECUTE
+//	OpMiniStatement  PIN_VALIDATED → READY_TO_EXECUTE
+//	OpChangePIN      PIN_VALIDATED → CHANGE_PIN (current, new, confirm) → IDLE
+//
+// Transfers are same-bank only (other banks go through the switch) and
+// move money under one AccountService lock => never half-done.
+// Every operation is a transaction row and gets a receipt.
+// ============================================
+
+var (
+	ErrPayeeNotFound    = errors.New("payee account not found")
+	ErrPayeeSameAccount = errors.New("cannot transfer to the same account")
+	ErrPayeeOtherBank   = errors.New("payee is with another bank, use NEFT/IMPS")
+	ErrInvalidAmount    = errors.New("amount must be greater than zero")
+	ErrPINUnchanged     = errors.New("new PIN must differ from the current PIN")
+	ErrPINMismatch      = errors.New("PINs do not match")
+)
+
+// miniStatementSize - transactions on a mini statement
+const miniStatementSize = 5
+
+// financialTypes - what a mini statement lists (no balance inquiries)
+var financialTypes = []TransactionType{Withdraw, Deposit, TransferOut, TransferIn}
+
+// Payee - what the customer sees to confirm before entering the amount
+type Payee struct {
+	AccountId string
+	BankId    string
+	Masked    string
+}
+
+// ============================================
+// ACCOUNT SERVICE
+// ============================================
+
+func (s *AccountServiceV1) ValidatePayee(fromAccountId, payeeAccountId string) (*Payee, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	from, exists := s.accounts[fromAccountId]
+	if !exists {
+		return nil, ErrAccountNotFound
+	}
+	payee, exists := s.accounts[payeeAccountId]
+	if !exists {
+		return nil, ErrPayeeNotFound
+	}
+	if payee.Id == from.Id {
+		return nil, ErrPayeeSameAccount
+	}
+	if payee.BankId != from.BankId {
+		return nil, ErrPayeeOtherBank
+	}
+	return &Payee{AccountId: payee.Id, BankId: payee.BankId, Masked: maskCard(payee.Id)}, nil
+}
+
+// Transfer - one ledger entry, customer:from => customer:to
+func (s *AccountServiceV1) Transfer(fromAccountId, toAccountId string, amount Money, ref string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.accounts[toAccountId]; !exists {
+		return ErrPayeeNotFound
+	}
+	return s.move(fromAccountId, ref, "transfer", CustomerAccount(fromAccountId), amount, CustomerAccount(toAccountId), ErrInsufficientFunds)
+}
+
+// ============================================
+// TRANSACTION SERVICE
+// ============================================
+
+// CreateTransfer - TRANSFER_OUT on the payer, TRANSFER_IN on the payee,
+// each pointing at the other account, both PENDING until the ledger
+// entry is posted; returns the payer's row (payee's = Id + "-IN")
+func (s *TransactionServiceV1) CreateTransfer(fromAccountId, toAccountId, cardNumber, atmId string, amount Money) (*Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	out := &Transaction{
+		Id:           generateTransactionId(),
+		AccountId:    fromAccountId,
+		Counterparty: toAccountId,
+		CardNumber:   cardNumber,
+		Amount:       amount,
+		Type:         TransferOut,
+		Status:       Pending,
+		ATMId:        atmId,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	in := *out
+	in.Id = out.Id + "-IN"
+	in.AccountId, in.Counterparty = toAccountId, fromAccountId
+	in.CardNumber = ""
+	in.Type = TransferIn
+
+	for _, txn := range []*Transaction{out, &in} {
+		s.totalTransactions[txn.Id] = txn
+		s.accountTransactions[txn.AccountId] = append(s.accountTransactions[txn.AccountId], txn)
+	}
+	return out, nil
+}
+
+// LastTransactions - newest first, at most n, only the given types
+// (none = all). Copies => safe to keep while sagas update the rows
+func (s *TransactionServiceV1) LastTransactions(accountId string, n int, types ...TransactionType) ([]Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	history, ok := s.accountTransactions[accountId]
+	if !ok {
+		return nil, ErrTransactionsDoesNotExist
+	}
+
+	var last []Transaction
+	for i := len(history) - 1; i >= 0 && len(last) < n; i-- {
+		if len(types) > 0 && !hasType(types, history[i].Type) {
+			continue
+		}
+		last = append(last, *history[i])
+	}
+	return last, nil
+}
+
+func hasType(types []TransactionType, t TransactionType) bool {
+	for _, candidate := range types {
+		if candidate == t {
+			return true
+		}
+	}
+	return false
+}
+
+// ============================================
+// RECEIPTS
+// ============================================
+
+func (s *ReceiptServiceV1) GenerateMiniStatement(transactionId string, transactions []Transaction) (*Receipt, error) {
+	receipt, err := s.GenerateReceipt(transactionId)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt.Summary = fmt.Sprintf("Mini statement, last %d transactions", len(transactions))
+	for _, txn := range transactions {
+		sign := "-"
+		if txn.Type == Deposit || txn.Type == TransferIn {
+			sign = "+"
+		}
+		receipt.Lines = append(receipt.Lines, fmt.Sprintf("%s  %-12s %s%v  %s",
+			txn.CreatedAt.Format("02 Jan 15:04"), txn.Type, sign, txn.Amount, txn.Status))
+	}
+	return receipt, nil
+}
+
+// receiptSummary - first line of the receipt, per transaction type
+func receiptSummary(txn *Transaction) string {
+	switch txn.Type {
+	case TransferOut:
+		return fmt.Sprintf("Transferred %v to %s", txn.Amount, maskCard(txn.Counterparty))
+	case TransferIn:
+		return fmt.Sprintf("Received %v from %s", txn.Amount, maskCard(txn.Counterparty))
+	case PinChange:
+		return "PIN changed successfully"
+	default:
+		return fmt.Sprintf("%s of %v completed", txn.Type, txn.Amount)
+	}
+}
+
+func printReceipt(receipt *Receipt) {
+	if receipt == nil {
+		return
+	}
+	fmt.Println("   🧾 ----------------------------------------")
+	fmt.Printf("   🧾 %s\n", receipt.Summary)
+	for _, line := range receipt.Lines {
+		fmt.Printf("   🧾   %s\n", line)
+	}
+	fmt.Printf("   🧾 Balance: %v   Ref: %s\n", receipt.Balance, receipt.TransactionId)
+	fmt.Println("   🧾 ----------------------------------------")
+}
+
+// ============================================
+// ATM SERVICE
+// ============================================
+
+func (s *ATMServiceV1) ValidatePayee(session *Session, payeeAccountId string) (*Payee, error) {
+	accountId, err := s.checkSession(session)
+	if err != nil {
+		return nil, err
+	}
+	return s.AccountService.ValidatePayee(accountId, payeeAccountId)
+}
+
+func (s *ATMServiceV1) Transfer(session *Session, payeeAccountId string, amount Money) (*Receipt, error) {
+	accountId, err := s.checkSession(session)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCurrency(amount, s.Currency); err != nil {
+		return nil, err
+	}
+	if _, err := s.AccountService.ValidatePayee(accountId, payeeAccountId); err != nil {
+		return nil, err
+	}
+
+	// rows first => the ledger entry always has a transaction to point at
+	txn, err := s.TransactionService.CreateTransfer(accountId, payeeAccountId, session.CardNumber, s.ATMid, amount)
+	if err != nil {
+		return nil, err
+	}
+	status, reason := Completed, ""
+	err = s.AccountService.Transfer(accountId, payeeAccountId, amount, txn.Id)
+	if err != nil {
+		status, reason = Failed, err.Error()
+	}
+	s.TransactionService.UpdateStatus(txn.Id, status, reason)
+	s.TransactionService.UpdateStatus(txn.Id+"-IN", status, reason)
+	if err != nil {
+		return nil, err
+	}
+	return s.ReceiptService.GenerateReceipt(txn.Id)
+}
+
+func (s *ATMServiceV1) MiniStatement(session *Session, n int) ([]Transaction, *Receipt, error) {
+	accountId, err := s.checkSession(session)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// fetched before recording this request => not on its own statement
+	last, err := s.TransactionService.LastTransactions(accountId, n, financialTypes...)
+	if err != nil && !errors.Is(err, ErrTransactionsDoesNotExist) {
+		return nil, nil, err
+	}
+
+	txn, err := s.TransactionService.CreateTransaction(accountId, session.CardNumber, s.ATMid, Paise(0), MiniStatement)
+	if err != nil {
+		return nil, nil, err
+	}
+	receipt, err := s.ReceiptService.GenerateMiniStatement(txn.Id, last)
+	if err != nil {
+		return nil, nil, err
+	}
+	return last, receipt, nil
+}
+
+// ChangePIN - the current PIN is checked again (and counts towards the
+// lockout), the bank stores only the new hash
+func (s *ATMServiceV1) ChangePIN(session *Session, currentPin, newPin string) (*Receipt, error) {
+	accountId, err := s.checkSession(session)
+	if err != nil {
+		return nil, err
+	}
+	if !validPINFormat(newPin) {
+		return nil, ErrPINFormat
+	}
+	if subtle.ConstantTimeCompare([]byte(currentPin), []byte(newPin)) == 1 {
+		return nil, ErrPINUnchanged
+	}
+	if err := s.verifyPin(session.CardNumber, currentPin); err != nil {
+		return nil, err
+	}
+
+	if err := s.BankService.ChangePin(session.CardNumber, currentPin, newPin); err != nil {
+		return nil, err
+	}
+	s.audit(AuditPinChanged, session.CardNumber, "")
+
+	txn, err := s.TransactionService.CreateTransaction(accountId, session.CardNumber, s.ATMid, Paise(0), PinChange)
+	if err != nil {
+		return nil, err
+	}
+	return s.ReceiptService.GenerateReceipt(txn.Id)
+}
+
+// ============================================
+// STATES
+// ============================================
+
+// PayeeEntryState - payee account number, validated before the amount
+type PayeeEntryState struct {
+	BaseATMState
+}
+
+func (s *PayeeEntryState) Name() StateName { return StatePayeeEntry }
+
+func (s *PayeeEntryState) EnterPayee(ctx *ATMController, payeeAccountId string) error {
+	payee, err := ctx.atmService.ValidatePayee(ctx.session, payeeAccountId)
+	if err != nil {
+		fmt.Println("❌ Payee rejected:", err)
+		fmt.Println("📌 Enter payee account number:")
+		return err
+	}
+
+	ctx.payee = payee
+	fmt.Printf("✅ Payee: %s (%s)\n", payee.Masked, payee.BankId)
+	if err := ctx.fire(EventPayeeAccepted); err != nil {
+		return err
+	}
+	fmt.Println("📌 Enter amount:")
+	return nil
+}
+
+// ChangePINState - current PIN, new PIN, new PIN again. The PINs live in
+// this state value only, replaced by a fresh one when CHANGE_PIN is left
+type ChangePINState struct {
+	BaseATMState
+	current string
+	newPin  string
+}
+
+func (s *ChangePINState) Name() StateName { return StateChangePIN }
+
+func (s *ChangePINState) EnterPIN(ctx *ATMController, pin string) error {
+	switch {
+	case s.current == "":
+		s.current = pin
+		fmt.Println("📌 Enter new PIN:")
+		return nil
+
+	case s.newPin == "":
+		if !validPINFormat(pin) {
+			fmt.Println("❌", ErrPINFormat)
+			fmt.Println("📌 Enter new PIN:")
+			return ErrPINFormat
+		}
+		s.newPin = pin
+		fmt.Println("📌 Re-enter new PIN:")
+		return nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(pin), []byte(s.newPin)) != 1 {
+		s.newPin = ""
+		fmt.Println("❌", ErrPINMismatch)
+		fmt.Println("📌 Enter new PIN:")
+		return ErrPINMismatch
+	}
+
+	receipt, err := ctx.atmService.ChangePIN(ctx.session, s.current, s.newPin)
+	s.current, s.newPin = "", ""
+	switch {
+	case errors.Is(err, ErrInvalidPIN):
+		fmt.Println("❌", err)
+		fmt.Println("📌 Enter current PIN:")
+		return err
+	case err != nil:
+		fmt.Println("❌ PIN change failed:", err)
+		fmt.Println("💳 Card ejected")
+		ctx.fire(EventDone)
+		return err
+	}
+
+	fmt.Println("✅ PIN changed")
+	printReceipt(receipt)
+	fmt.Println("💳 Please take your card")
+	return ctx.fire(EventDone)
+}
+
+// ============================================
+// DEMO
+// ============================================
+
+func testBranchOperations() {
+	rig := newATMRig(DefaultWithdrawalLimits())
+	atmServ, _ := rig.addATM("ATM-OPS", demoNotes())
+	acctServ := rig.accounts
+
+	for _, open := range []struct {
+		account *Account
+		opening int64
+	}{
+		{&Account{Id: "ACC-1001", UserId: "USER-A", BankId: "BANK-001", AccountType: Savings, DailyLimit: Rupees(20000)}, 20000},
+		{&Account{Id: "ACC-2002", UserId: "USER-B", BankId: "BANK-001", AccountType: Savings, DailyLimit: Rupees(20000)}, 1000},
+		{&Account{Id: "ACC-3003", UserId: "USER-C", BankId: "BANK-002", AccountType: Savings, DailyLimit: Rupees(20000)}, 1000},
+	} {
+		acctServ.OpenAccount(open.account, Rupees(open.opening))
+	}
+	rig.issueCard("CARD-1001", "USER-A", "ACC-1001", "Asha Rao", "1357")
+
+	session, _ := atmServ.Authenticate("CARD-1001", "1357")
+	atmServ.Withdraw(session, Rupees(2000))
+	atmServ.Deposit(session, Rupees(1500), map[float64]int{500: 3})
+	atmServ.EndSession(session)
+
+	fmt.Println("🔁 Transfer ₹2,500 to ACC-2002")
+	controller := NewATMController(atmServ)
+	controller.InsertCard("CARD-1001")
+	controller.EnterPIN("1357")
+	controller.SelectOperation(OpTransfer)
+	controller.EnterPayee("ACC-1001") // own account
+	controller.EnterPayee("ACC-3003") // other bank
+	controller.EnterPayee("ACC-2002")
+	controller.EnterAmount(2500)
+	controller.Execute()
+	payer, _ := acctServ.Balance("ACC-1001")
+	payee, _ := acctServ.Balance("ACC-2002")
+	fmt.Printf("   📊 ACC-1001 %v, ACC-2002 %v\n\n", payer, payee)
+
+	fmt.Println("📃 Mini statement")
+	controller.InsertCard("CARD-1001")
+	controller.EnterPIN("1357")
+	controller.SelectOperation(OpMiniStatement)
+	controller.Execute()
+	fmt.Println()
+
+	fmt.Println("🔑 Change PIN 1357 → 8642")
+	controller.InsertCard("CARD-1001")
+	controller.EnterPIN("1357")
+	controller.SelectOperation(OpChangePIN)
+	controller.EnterPIN("1357")
+	controller.EnterPIN("8642")
+	controller.EnterPIN("8640") // typo
+	controller.EnterPIN("8642")
+	controller.EnterPIN("8642")
+	if _, err := atmServ.Authenticate("CARD-1001", "1357"); err != nil {
+		fmt.Println("   ✅ Old PIN rejected:", err)
+	}
+	if session, err := atmServ.Authenticate("CARD-1001", "8642"); err == nil {
+		fmt.Println("   ✅ New PIN accepted")
+		atmServ.EndSession(session)
+	}
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================
+// PIN SECURITY
+// Before: ValidatePin always said yes, nobody counted wrong PINs and
+// ATMController kept the raw PIN in memory for the whole session.
+// Now:
+//
+//	Bank       stores HMAC(salt, PIN block) only, constant-time compare
+//	CardService counts wrong PINs per card, N within Window => BlockCard
+//	ATMService  Authenticate(card, PIN) once => *Session, the PIN is gone
+//	AuditLog    every auth failure / lock / unblock is recorded
+//
+// Admin unblock (UnblockCard) needs an admin id + reason, and clears
+// the failure counter
+// ============================================
+
+var (
+	ErrCardLocked     = errors.New("too many wrong PINs, card blocked")
+	ErrInvalidSession = errors.New("session expired or invalid, insert card again")
+	ErrPINFormat      = errors.New("PIN must be 4 to 6 digits")
+	ErrNotBlocked     = errors.New("card is not blocked")
+	ErrAdminRequired  = errors.New("admin id and reason are required")
+)
+
+const (
+	sessionTTL    = 5 * time.Minute
+	pinHashRounds = 4096 // slows down offline guessing of a 10^4..10^6 space
+)
+
+// ============================================
+// AUDIT
+// ============================================
+
+type AuditEventType string
+
+const (
+	AuditCardRejected   AuditEventType = "CARD_REJECTED"   // invalid / blocked / expired card
+	AuditPinFailed      AuditEventType = "PIN_FAILED"      // wrong PIN, card still usable
+	AuditCardLocked     AuditEventType = "CARD_LOCKED"     // too many wrong PINs
+	AuditCardBlocked    AuditEventType = "CARD_BLOCKED"    // blocked for fraud (velocity)
+	AuditSessionInvalid AuditEventType = "SESSION_INVALID" // expired / unknown session used
+	AuditCardUnblocked  AuditEventType = "CARD_UNBLOCKED"  // admin unblock
+	AuditPinChanged     AuditEventType = "PIN_CHANGED"     // customer changed PIN at the ATM
+)
+
+type AuditEvent struct {
+	Time       time.Time
+	Type       AuditEventType
+	CardNumber string
+	ATMId      string
+	Actor      string // admin id for admin actions
+	Detail     string
+}
+
+type AuditLog interface {
+	Record(event AuditEvent)
+}
+
+type AuditLogV1 struct {
+	events []AuditEvent
+	mu     sync.Mutex
+}
+
+func NewAuditLogV1() *AuditLogV1 {
+	return &AuditLogV1{}
+}
+
+func (l *AuditLogV1) Record(event AuditEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *AuditLogV1) Events() []AuditEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]AuditEvent(nil), l.events...)
+}
+
+// ============================================
+// PIN STORAGE (Bank side)
+// ISO 9564 format 0 PIN block, the PIN mixed with the card number:
+//
+//	PIN field  0 | len | PIN digits | F padding     "041234FFFFFFFFFF"
+//	PAN field  0000 | 12 rightmost card digits      "0000000000000001"
+//	PIN block  PIN field XOR PAN field
+//
+// Same PIN on two cards => different blocks, plus a per-card salt =>
+// the stored hashes say nothing about which cards share a PIN
+// ============================================
+
+type pinRecord struct {
+	salt []byte
+	hash []byte
+}
+
+// dummyPin - compared against for unknown cards, so "no such card" and
+// "wrong PIN" take the same time
+var dummyPin = pinRecord{salt: make([]byte, 16), hash: make([]byte, sha256.Size)}
+
+func validPINFormat(pin string) bool {
+	if len(pin) < 4 || len(pin) > 6 {
+		return false
+	}
+	for _, c := range pin {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// pinBlock - ErrPINFormat for anything validPINFormat refuses: keypad
+// input reaches here unchecked (Authenticate, ChangePIN)
+func pinBlock(cardNumber, pin string) ([]byte, error) {
+	if !validPINFormat(pin) {
+		return nil, ErrPINFormat
+	}
+	pinField := fmt.Sprintf("0%d%s", len(pin), pin)
+	pinField += strings.Repeat("F", 16-len(pinField))
+
+	pinBytes, err := hex.DecodeString(pinField)
+	if err != nil {
+		return nil, ErrPINFormat
+	}
+	block := panField(cardNumber)
+	for i := range block {
+		block[i] ^= pinBytes[i]
+	}
+	return block, nil
+}
+
+// panField - 0000 + 12 rightmost card digits (non-digits skipped), 8 bytes
+func panField(cardNumber string) []byte {
+	digits := ""
+	for _, c := range cardNumber {
+		if c >= '0' && c <= '9' {
+			digits += string(c)
+		}
+	}
+	if len(digits) > 12 {
+		digits = digits[len(digits)-12:]
+	}
+	field := make([]byte, 8)
+	for i, c := range strings.Repeat("0", 16-len(digits)) + digits {
+		field[i/2] |= byte(c-'0') << (4 * uint(1-i%2))
+	}
+	return field
+}
+
+// pinFromBlock - inverse of pinBlock, what the host does with field 52
+// (see iso8583.go). In this demo field 52 is the plain ISO 9564-0 block,
+// NOT encrypted: anyone who sees the message and knows the PAN gets the
+// PIN back with one XOR. A real ATM encrypts it under a PIN key (TPK/ZPK)
+// before it leaves the keypad
+func pinFromBlock(cardNumber string, block []byte) (string, error) {
+	if len(block) != 8 {
+		return "", ErrPINFormat
+	}
+	pinField := panField(cardNumber)
+	for i := range pinField {
+		pinField[i] ^= block[i]
+	}
+	digits := hex.EncodeToString(pinField)
+	n := int(digits[1] - '0')
+	if digits[0] != '0' || n < 4 || n > 6 || strings.Trim(digits[2+n:], "f") != "" {
+		return "", ErrPINFormat
+	}
+	pin := digits[2 : 2+n]
+	if !validPINFormat(pin) {
+		return "", ErrPINFormat
+	}
+	return pin, nil
+}
+
+// dummyPinBlock - hashed in place of a malformed PIN: same work, and it
+// can't match a real block (PIN length 0)
+var dummyPinBlock = make([]byte, 8)
+
+func hashPin(salt []byte, cardNumber, pin string) []byte {
+	sum, err := pinBlock(cardNumber, pin)
+	if err != nil {
+		sum = dummyPinBlock
+	}
+	for i := 0; i < pinHashRounds; i++ {
+		mac := hmac.New(sha256.New, salt)
+		mac.Write(sum)
+		sum = mac.Sum(nil)
+	}
+	return sum
+}
+
+// SetPin - card issuance / PIN change, only the salted hash is kept
+func (s *BankServiceV1) SetPin(cardNumber, pin string) error {
+	if !validPINFormat(pin) {
+		return ErrPINFormat
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("pin salt: %w", err)
+	}
+	record := pinRecord{salt: salt, hash: hashPin(salt, cardNumber, pin)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pins == nil {
+		s.pins = make(map[string]pinRecord)
+	}
+	s.pins[cardNumber] = record
+	return nil
+}
+
+// ChangePin - SetPin, but only with the card's current PIN
+func (s *BankServiceV1) ChangePin(cardNumber, currentPin, newPin string) error {
+	ok, err := s.ValidatePin(cardNumber, currentPin)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidPIN
+	}
+	return s.SetPin(cardNumber, newPin)
+}
+
+func (s *BankServiceV1) ValidatePin(cardNumber, pin string) (bool, error) {
+	s.mu.RLock()
+	record, ok := s.pins[cardNumber]
+	s.mu.RUnlock()
+	if !ok {
+		record = dummyPin
+	}
+
+	// hash even malformed PINs => timing says nothing about the input
+	got := hashPin(record.salt, cardNumber, pin)
+	match := subtle.ConstantTimeCompare(got, record.hash) == 1
+	return ok && match && validPINFormat(pin), nil
+}
+
+// ============================================
+// PIN LOCKOUT (CardService side)
+// ============================================
+
+type PinLockoutPolicy struct {
+	MaxAttempts int           // wrong PINs before the card is blocked
+	Window      time.Duration // only failures this recent count
+}
+
+func DefaultPinLockoutPolicy() PinLockoutPolicy {
+	return PinLockoutPolicy{MaxAttempts: 3, Window: 24 * time.Hour}
+}
+
+// RecordPinFailure - one more wrong PIN; blocks the card on the last
+// allowed attempt (ErrCardLocked), else says how many are left
+func (s *CardServiceV1) RecordPinFailure(cardNumber, atmId string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	card, exists := s.Cards[cardNumber]
+	if !exists {
+		return 0, ErrCardDoesNotExist
+	}
+
+	now := s.Now()
+	recent := s.pinFailures[cardNumber][:0]
+	for _, at := range s.pinFailures[cardNumber] {
+		if now.Sub(at) < s.lockout.Window {
+			recent = append(recent, at)
+		}
+	}
+	recent = append(recent, now)
+	s.pinFailures[cardNumber] = recent
+
+	if len(recent) >= s.lockout.MaxAttempts {
+		card.Status = Blocked
+		s.audit.Record(AuditEvent{Time: now, Type: AuditCardLocked, CardNumber: cardNumber, ATMId: atmId,
+			Detail: fmt.Sprintf("%d wrong PINs within %v", len(recent), s.lockout.Window)})
+		return 0, ErrCardLocked
+	}
+	return s.lockout.MaxAttempts - len(recent), nil
+}
+
+// ResetPinFailures - correct PIN, start counting from zero again
+func (s *CardServiceV1) ResetPinFailures(cardNumber string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pinFailures, cardNumber)
+}
+
+// UnblockCard - admin flow (branch / call centre after verifying the
+// customer), never reachable from the ATM itself
+func (s *CardServiceV1) UnblockCard(cardNumber, adminId, reason string) error {
+	if adminId == "" || reason == "" {
+		return ErrAdminRequired
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	card, exists := s.Cards[cardNumber]
+	if !exists {
+		return ErrCardDoesNotExist
+	}
+	if card.Status != Blocked {
+		return ErrNotBlocked
+	}
+
+	card.Status = Active
+	delete(s.pinFailures, cardNumber)
+	s.audit.Record(AuditEvent{Time: s.Now(), Type: AuditCardUnblocked, CardNumber: cardNumber, Actor: adminId, Detail: reason})
+	return nil
+}
+
+// ============================================
+// SESSION (ATMService side)
+// ============================================
+
+// Session - proof that card + PIN were checked, what the ATMController
+// keeps instead of the PIN
+type Session struct {
+	Token      string
+	CardNumber string
+	AccountId  string
+	ExpiresAt  time.Time
+}
+
+func (s *ATMServiceV1) Authenticate(cardNumber, pin string) (*Session, error) {
+	//1. validate card
+	if err := s.CardService.ValidateCard(cardNumber); err != nil {
+		s.audit(AuditCardRejected, cardNumber, err.Error())
+		return nil, err
+	}
+
+	//2. validate pin
+	if err := s.verifyPin(cardNumber, pin); err != nil {
+		return nil, err
+	}
+
+	accountId, err := s.CardService.GetAccountDetails(cardNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("session token: %w", err)
+	}
+	session := &Session{
+		Token:      hex.EncodeToString(token),
+		CardNumber: cardNumber,
+		AccountId:  accountId,
+		ExpiresAt:  time.Now().Add(sessionTTL),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[session.Token] = session
+	return session, nil
+}
+
+// verifyPin - wrong PIN counts towards the lockout, right one resets it
+func (s *ATMServiceV1) verifyPin(cardNumber, pin string) error {
+	isValidPIN, err := s.BankService.ValidatePin(cardNumber, pin)
+	if err != nil {
+		return err
+	}
+	if !isValidPIN {
+		left, err := s.CardService.RecordPinFailure(cardNumber, s.ATMid)
+		if err != nil {
+			return err // CardService audited the lock
+		}
+		s.audit(AuditPinFailed, cardNumber, fmt.Sprintf("%d attempt(s) left", left))
+		return fmt.Errorf("%w: %d attempt(s) left", ErrInvalidPIN, left)
+	}
+	s.CardService.ResetPinFailures(cardNumber)
+	return nil
+}
+
+// EndSession - card ejected, the token is worthless from now on
+func (s *ATMServiceV1) EndSession(session *Session) {
+	if session == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, session.Token)
+}
+
+// checkSession - session issued here, not expired, card still active
+// (velocity rules can block it mid-session) => its account
+func (s *ATMServiceV1) checkSession(session *Session) (string, error) {
+	if session == nil {
+		return "", ErrInvalidSession
+	}
+
+	s.mu.Lock()
+	issued, ok := s.sessions[session.Token]
+	if ok && time.Now().After(issued.ExpiresAt) {
+		delete(s.sessions, session.Token)
+		ok = false
+	}
+	s.mu.Unlock()
+
+	if !ok || issued.CardNumber != session.CardNumber {
+		s.audit(AuditSessionInvalid, session.CardNumber, "unknown or expired session")
+		return "", ErrInvalidSession
+	}
+	if err := s.CardService.ValidateCard(issued.CardNumber); err != nil {
+		s.audit(AuditCardRejected, issued.CardNumber, err.Error())
+		return "", err
+	}
+	return issued.AccountId, nil
+}
+
+func (s *ATMServiceV1) audit(eventType AuditEventType, cardNumber, detail string) {
+	if s.Audit == nil {
+		return
+	}
+	s.Audit.Record(AuditEvent{Type: eventType, CardNumber: cardNumber, ATMId: s.ATMid, Detail: detail})
+}
+
+// ============================================
+// PIN SECURITY DEMO
+// ============================================
+
+func testPinSecurity() {
+	rig := newATMRig(DefaultWithdrawalLimits())
+	atmServ, _ := rig.addATM("ATM-PIN", demoNotes())
+	bank, cardServ, audit := rig.bank, rig.cards, rig.audit
+
+	rig.accounts.OpenAccount(&Account{Id: "ACC-PIN", UserId: "USER-PIN", BankId: "BANK-001", AccountType: Savings, DailyLimit: Rupees(20000)}, Rupees(5000))
+	rig.issueCard("CARD-4321", "USER-PIN", "ACC-PIN", "Pin Tester", "2468")
+
+	record := bank.pins["CARD-4321"]
+	fmt.Printf("🔒 Stored for CARD-4321: salt=%s… hash=%s… (no PIN)\n\n",
+		hex.EncodeToString(record.salt)[:8], hex.EncodeToString(record.hash)[:16])
+
+	fmt.Println("⌨️  Malformed keypad input is just a wrong PIN")
+	for _, pin := range []string{"24x8", "24680135792468", ""} {
+		ok, err := bank.ValidatePin("CARD-4321", pin)
+		fmt.Printf("   ✅ %q => match=%v err=%v\n", pin, ok, err)
+	}
+	fmt.Println()
+
+	fmt.Println("🔑 Wrong PIN three times via the ATM")
+	controller := NewATMController(atmServ)
+	controller.InsertCard("CARD-4321")
+	for _, pin := range []string{"1111", "2222", "3333"} {
+		controller.EnterPIN(pin)
+	}
+	fmt.Println()
+
+	fmt.Println("🔑 Right PIN after the lockout")
+	if _, err := atmServ.Authenticate("CARD-4321", "2468"); err != nil {
+		fmt.Println("   ✅ Rejected:", err)
+	}
+	fmt.Println()
+
+	fmt.Println("🛠️  Admin unblock")
+	if err := cardServ.UnblockCard("CARD-4321", "", ""); err != nil {
+		fmt.Println("   ✅ Without admin id rejected:", err)
+	}
+	if err := cardServ.UnblockCard("CARD-4321", "ADMIN-7", "customer verified at branch"); err == nil {
+		fmt.Println("   ✅ Unblocked by ADMIN-7")
+	}
+	if session, err := atmServ.Authenticate("CARD-4321", "2468"); err == nil {
+		balance, _ := atmServ.CheckBalance(session)
+		fmt.Printf("   ✅ Right PIN works again, balance %v\n", balance)
+		atmServ.EndSession(session)
+		if _, err := atmServ.CheckBalance(session); err != nil {
+			fmt.Println("   ✅ Ended session rejected:", err)
+		}
+	}
+	fmt.Println()
+
+	fmt.Println("📋 Audit log")
+	for _, event := range audit.Events() {
+		who := event.ATMId
+		if event.Actor != "" {
+			who = event.Actor
+		}
+		fmt.Printf("   %s %-16s %-9s %-8s %s\n", event.Time.Format("15:04:05"), event.Type, event.CardNumber, who, event.Detail)
+	}
+}
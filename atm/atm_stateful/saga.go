@@ -0,0 +1,321 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ============================================
+// SAGA - Withdraw / Deposit as reversible steps
+//
+// Old Withdraw: Dispense() then DebitAccount()
+// => debit fails AFTER the cash left the machine, and a failed
+// CreateTransaction left txn nil => GenerateReceipt(txn.Id) panicked.
+//
+// Now the transaction row is written FIRST (PENDING) and every step
+// has a compensating action:
+//
+//	WITHDRAW  reserve_funds ──► reserve_notes ──► bank_debit ──► dispense ──► confirm
+//	undo      release funds     release notes     Reverse        (pivot)      (retried)
+//
+//	DEPOSIT   credit_account ──► bank_credit ──► store_notes
+//	undo      reverse credit     Reverse         (last step, notes go back to customer)
+//
+// bank_debit / bank_credit go through BankService: a no-op for the
+// in-process BankServiceV1, an ISO 8583 0200 for HostBankService. A host
+// that doesn't answer in time gets an 0420 reversal from HostBankService
+// and the step fails => the saga undoes everything before it. Undoing an
+// approved bank step is BankService.Reverse: an 0420 for that 0200's
+// STAN, not a new opposite 0200 the host would post as a fresh txn
+//
+// A step fails before the pivot => completed steps are undone in reverse
+// order, transaction REVERSED. After the pivot (cash is in the customer's
+// hand) there is nothing to undo: later steps are retried and if they
+// still fail the transaction stays PENDING for reconciliation.
+// Transaction.Step always holds the last completed step (persisted by
+// TransactionService) => after a crash we know how far a saga got.
+// ============================================
+
+var (
+	ErrNeedsReconciliation = errors.New("transaction could not be finished or reversed, left PENDING for reconciliation")
+	ErrInjectedFault       = errors.New("injected fault")
+)
+
+const pivotRetries = 3
+
+type SagaStep struct {
+	Name string
+	Do   func() error
+	Undo func() error // nil => pivot: can't be undone once done
+}
+
+type Saga struct {
+	txn          *Transaction
+	transactions TransactionService
+	faultHook    func(step string) error // fault injection, nil in production
+	steps        []SagaStep
+}
+
+func NewSaga(txn *Transaction, transactions TransactionService, faultHook func(step string) error) *Saga {
+	return &Saga{txn: txn, transactions: transactions, faultHook: faultHook}
+}
+
+func (s *Saga) Step(name string, do func() error, undo func() error) {
+	s.steps = append(s.steps, SagaStep{Name: name, Do: do, Undo: undo})
+}
+
+func (s *Saga) Run() error {
+	var done []SagaStep
+	pastPivot := false
+
+	for _, step := range s.steps {
+		attempts := 1
+		if pastPivot {
+			attempts = pivotRetries
+		}
+
+		var err error
+		for i := 0; i < attempts; i++ {
+			if err = s.runStep(step); err == nil {
+				break
+			}
+		}
+
+		if err != nil {
+			if pastPivot {
+				// can't give the cash back => finish it later, never lose it
+				last := done[len(done)-1].Name
+				s.transactions.UpdateStatus(s.txn.Id, Pending, fmt.Sprintf("%s failed after %s: %v", step.Name, last, err))
+				return fmt.Errorf("%w: %s: %v", ErrNeedsReconciliation, step.Name, err)
+			}
+			return s.compensate(done, step.Name, err)
+		}
+
+		s.transactions.RecordStep(s.txn.Id, step.Name)
+		done = append(done, step)
+		if step.Undo == nil {
+			pastPivot = true
+		}
+	}
+
+	s.transactions.UpdateStatus(s.txn.Id, Completed, "")
+	return nil
+}
+
+func (s *Saga) runStep(step SagaStep) error {
+	if s.faultHook != nil {
+		if err := s.faultHook(step.Name); err != nil {
+			return err
+		}
+	}
+	return step.Do()
+}
+
+// compensate - undo completed steps, newest first
+func (s *Saga) compensate(done []SagaStep, failedStep string, cause error) error {
+	var undoErrs []error
+	for i := len(done) - 1; i >= 0; i-- {
+		if done[i].Undo == nil {
+			continue
+		}
+		if err := done[i].Undo(); err != nil {
+			undoErrs = append(undoErrs, fmt.Errorf("undo %s: %w", done[i].Name, err))
+		}
+	}
+
+	reason := fmt.Sprintf("%s failed: %v", failedStep, cause)
+	if len(undoErrs) > 0 {
+		s.transactions.UpdateStatus(s.txn.Id, Pending, reason+"; "+errors.Join(undoErrs...).Error())
+		return fmt.Errorf("%w: %s: %v", ErrNeedsReconciliation, failedStep, errors.Join(append([]error{cause}, undoErrs...)...))
+	}
+	s.transactions.UpdateStatus(s.txn.Id, Reversed, reason)
+	return fmt.Errorf("%s: %w", failedStep, cause)
+}
+
+// ============================================
+// WITHDRAW / DEPOSIT SAGAS
+// ============================================
+
+func (s *ATMServiceV1) withdrawSaga(txn *Transaction, accountId string, amount Money, requested map[float64]int) error {
+	var notes map[float64]int
+	saga := NewSaga(txn, s.TransactionService, s.FaultHook)
+
+	saga.Step("reserve_funds",
+		func() error { return s.AccountService.ReserveFunds(accountId, amount, txn.Id) },
+		func() error { return s.AccountService.ReleaseFunds(accountId, amount, txn.Id) },
+	)
+	saga.Step("reserve_notes",
+		func() (err error) {
+			notes, err = s.CashDispenserService.ReserveNotes(amount, requested)
+			if err == nil {
+				s.TransactionService.RecordNotes(txn.Id, notes) // forecasting only, never fails the step
+			}
+			return err
+		},
+		func() error { return s.CashDispenserService.ReleaseNotes(notes) },
+	)
+	saga.Step("bank_debit", // last step before the pivot: the host says yes or no
+		func() error { return s.BankService.DebitAccount(accountId, amount, txn.Id) },
+		func() error { return s.BankService.Reverse(txn.Id) },
+	)
+	saga.Step("dispense",
+		func() error { return s.CashDispenserService.DispenseNotes(notes) },
+		nil, // pivot: cash is out
+	)
+	saga.Step("confirm",
+		func() error { return s.AccountService.CaptureFunds(accountId, amount, ATMCashAccount(s.ATMid), txn.Id) },
+		nil,
+	)
+	return saga.Run()
+}
+
+// depositSaga - credit first because it can be undone; storing the notes
+// mixes them into the cassettes, so it is the last thing we do
+func (s *ATMServiceV1) depositSaga(txn *Transaction, accountId string, amount Money, denominations map[float64]int) error {
+	saga := NewSaga(txn, s.TransactionService, s.FaultHook)
+
+	saga.Step("credit_account",
+		func() error {
+			return s.AccountService.CreditAccount(accountId, amount, ATMCashAccount(s.ATMid), txn.Id)
+		},
+		func() error { return s.AccountService.DebitAccount(accountId, amount, ATMCashAccount(s.ATMid), txn.Id) },
+	)
+	saga.Step("bank_credit",
+		func() error { return s.BankService.CreditAccount(accountId, amount, txn.Id) },
+		func() error { return s.BankService.Reverse(txn.Id) },
+	)
+	saga.Step("store_notes",
+		func() error { return s.CashDispenserService.Deposit(denominations) },
+		nil,
+	)
+	return saga.Run()
+}
+
+// ============================================
+// FAULT INJECTION DEMO
+// Fresh ATM per case, FaultHook fails exactly one step, then we check
+// that money and notes ended up where the transaction status says
+// ============================================
+
+type sagaRig struct {
+	atm       *ATMServiceV1
+	session   *Session
+	txns      *TransactionServiceV1
+	accounts  *AccountServiceV1
+	ledger    *Ledger
+	account   *Account
+	dispenser *CashDispenserV1
+}
+
+func newSagaRig(failStep string) *sagaRig {
+	rig := newATMRig(DefaultWithdrawalLimits())
+	atmServ, dispenser := rig.addATM("ATM-FI", demoNotes())
+
+	account := &Account{Id: "ACC-FI", UserId: "USER-FI", BankId: "BANK-001", AccountType: Savings, DailyLimit: Rupees(20000)}
+	rig.accounts.OpenAccount(account, Rupees(10000))
+	rig.issueCard("CARD-FI", "USER-FI", account.Id, "Fault Injector", "1234")
+
+	atmServ.FaultHook = func(step string) error {
+		if step == failStep {
+			return ErrInjectedFault
+		}
+		return nil
+	}
+	session, _ := atmServ.Authenticate("CARD-FI", "1234")
+	return &sagaRig{atm: atmServ, session: session, txns: rig.txns, accounts: rig.accounts, ledger: rig.ledger, account: account, dispenser: dispenser}
+}
+
+// lastTxn - the one transaction the case created
+func (r *sagaRig) lastTxn() *Transaction {
+	history, _ := r.txns.GetTransactionHistory(r.account.Id)
+	if len(history) == 0 {
+		return nil
+	}
+	return history[len(history)-1]
+}
+
+func testSagaFaults() {
+	type expect struct {
+		status  TransactionStatus
+		step    string // last completed step
+		balance int64  // ₹ book balance after (available + on hold)
+		cash    int64  // ₹ ATM sellable cash after
+	}
+	const rupees = 1500
+	amount := Rupees(rupees)
+
+	withdrawCases := []struct {
+		failStep string
+		want     expect
+	}{
+		{"reserve_funds", expect{Reversed, "", 10000, 12000}},
+		{"reserve_notes", expect{Reversed, "reserve_funds", 10000, 12000}},
+		{"bank_debit", expect{Reversed, "reserve_notes", 10000, 12000}},
+		{"dispense", expect{Reversed, "bank_debit", 10000, 12000}},
+		// cash is out, capture keeps failing => hold stays, reconcile later
+		{"confirm", expect{Pending, "dispense", 10000, 12000 - rupees}},
+		{"", expect{Completed, "confirm", 10000 - rupees, 12000 - rupees}},
+	}
+
+	fmt.Println("💥 Withdraw ₹1,500 - fail one step at a time")
+	for _, tc := range withdrawCases {
+		rig := newSagaRig(tc.failStep)
+		err := rig.atm.Withdraw(rig.session, amount)
+		report(rig, "fail "+orNone(tc.failStep), err, tc.want.status, tc.want.step, tc.want.balance, tc.want.cash)
+
+		if tc.failStep == "confirm" && !errors.Is(err, ErrNeedsReconciliation) {
+			checkFailed("      ❗ expected ErrNeedsReconciliation\n")
+		}
+		if held := rig.ledger.Balance(HoldAccount(rig.account.Id)); tc.failStep != "confirm" && !held.IsZero() {
+			checkFailed("      ❗ %v still on hold\n", held)
+		}
+	}
+	fmt.Println()
+
+	depositCases := []struct {
+		failStep string
+		want     expect
+	}{
+		{"credit_account", expect{Reversed, "", 10000, 12000}},
+		{"bank_credit", expect{Reversed, "credit_account", 10000, 12000}},
+		{"store_notes", expect{Reversed, "bank_credit", 10000, 12000}},
+		{"", expect{Completed, "store_notes", 10000 + rupees, 12000 + rupees}},
+	}
+
+	fmt.Println("💥 Deposit ₹1,500 - fail one step at a time")
+	for _, tc := range depositCases {
+		rig := newSagaRig(tc.failStep)
+		err := rig.atm.Deposit(rig.session, amount, map[float64]int{500: 3})
+		report(rig, "fail "+orNone(tc.failStep), err, tc.want.status, tc.want.step, tc.want.balance, tc.want.cash)
+	}
+}
+
+func report(rig *sagaRig, name string, err error, status TransactionStatus, step string, balance, cash int64) {
+	txn := rig.lastTxn()
+	if txn == nil {
+		checkFailed("   ❌ %-20s no transaction recorded (err: %v)\n", name, err)
+		return
+	}
+
+	book, _ := rig.accounts.Balance(rig.account.Id)
+	held := rig.ledger.Balance(HoldAccount(rig.account.Id))
+	ok := txn.Status == status && txn.Step == step &&
+		book == Rupees(balance) && rig.dispenser.GetCurrentBalance() == Rupees(cash)
+	mark := "✅"
+	if !ok {
+		mark = "❌"
+		checkFailures++
+	}
+	fmt.Printf("   %s %-20s %-9s step=%-13s balance=%v held=%v cash=%v\n",
+		mark, name, txn.Status, orNone(txn.Step), book, held, rig.dispenser.GetCurrentBalance())
+	if err != nil {
+		fmt.Printf("      error: %v\n", err)
+	}
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================
+// SESSION TIMEOUTS & CARD RETENTION
+// Before: a customer who walked away left the ATM in CardInsertState /
+// PINValidatedState forever, with a live session for the next person.
+// Now every state can have an inactivity timeout:
+//
+//	State               After  Then
+//	------------------  -----  -------------------------------------
+//	CARD_INSERTED       30s    eject card            => IDLE
+//	PIN_VALIDATED       30s    capture card          => CARD_RETAINED
+//	AMOUNT_ENTRY        30s    capture card          => CARD_RETAINED
+//	DENOMINATION_ENTRY  60s    return notes + card   => IDLE
+//	READY_TO_EXECUTE    30s    capture card          => CARD_RETAINED
+//	PAYEE_ENTRY         30s    capture card          => CARD_RETAINED
+//	CHANGE_PIN          30s    capture card          => CARD_RETAINED
+//	CARD_RETAINED       15s    "contact your branch" => IDLE
+//
+// Timeouts are checked lazily before every input and by CheckTimeouts
+// (called from StartTimeoutWatcher or the hardware main loop). Every
+// timeout writes a journal entry, like a real ATM's electronic journal.
+//
+// All state changes go through the transition table (state_machine.go),
+// which wipes whatever session data the next state must not see (see
+// sessionWipe); a timeout is just the TIMEOUT event
+// ============================================
+
+type StateName string
+
+const (
+	StateIdle              StateName = "IDLE"
+	StateCardInserted      StateName = "CARD_INSERTED"
+	StatePINValidated      StateName = "PIN_VALIDATED"
+	StateAmountEntry       StateName = "AMOUNT_ENTRY"
+	StateDenominationEntry StateName = "DENOMINATION_ENTRY"
+	StateReadyToExecute    StateName = "READY_TO_EXECUTE"
+	StateCardRetained      StateName = "CARD_RETAINED"
+	StatePayeeEntry        StateName = "PAYEE_ENTRY"
+	StateChangePIN         StateName = "CHANGE_PIN"
+)
+
+// StateTimeout - After without input => eject (IDLE) or, with Retain,
+// keep the card (CARD_RETAINED). After <= 0 disables it
+type StateTimeout struct {
+	After  time.Duration
+	Retain bool
+}
+
+func DefaultStateTimeouts() map[StateName]StateTimeout {
+	return map[StateName]StateTimeout{
+		StateCardInserted:      {After: 30 * time.Second},
+		StatePINValidated:      {After: 30 * time.Second, Retain: true},
+		StateAmountEntry:       {After: 30 * time.Second, Retain: true},
+		StateDenominationEntry: {After: 60 * time.Second},
+		StateReadyToExecute:    {After: 30 * time.Second, Retain: true},
+		StatePayeeEntry:        {After: 30 * time.Second, Retain: true},
+		StateChangePIN:         {After: 30 * time.Second, Retain: true},
+		StateCardRetained:      {After: 15 * time.Second},
+	}
+}
+
+// ============================================
+// JOURNAL
+// ============================================
+
+type JournalEvent string
+
+const (
+	JournalTimeout      JournalEvent = "TIMEOUT"
+	JournalCardRetained JournalEvent = "CARD_RETAINED"
+	JournalCardEjected  JournalEvent = "CARD_EJECTED"
+)
+
+type JournalEntry struct {
+	Time   time.Time
+	Event  JournalEvent
+	State  StateName // state the ATM was in
+	Card   string    // masked
+	Detail string
+}
+
+type Journal interface {
+	Write(entry JournalEntry)
+}
+
+type JournalV1 struct {
+	entries []JournalEntry
+	mu      sync.Mutex
+}
+
+func NewJournalV1() *JournalV1 {
+	return &JournalV1{}
+}
+
+func (j *JournalV1) Write(entry JournalEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = append(j.entries, entry)
+}
+
+func (j *JournalV1) Entries() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]JournalEntry(nil), j.entries...)
+}
+
+// maskCard - journals are read by engineers, keep only the last 4
+func maskCard(cardNumber string) string {
+	if len(cardNumber) <= 4 {
+		return cardNumber
+	}
+	return strings.Repeat("*", len(cardNumber)-4) + cardNumber[len(cardNumber)-4:]
+}
+
+// ============================================
+// CARD RETAINED STATE
+// Card is in the capture bin, nothing is accepted until the screen
+// times out back to IDLE (or the engineer cancels)
+// ============================================
+
+type CardRetainedState struct {
+	BaseATMState
+}
+
+func (s *CardRetainedState) Name() StateName { return StateCardRetained }
+
+func (s *CardRetainedState) Cancel(ctx *ATMController) error {
+	fmt.Println("🏧 Card stays retained, ATM ready for the next customer")
+	return ctx.fire(EventCancel)
+}
+
+// ============================================
+// CONTROLLER PLUMBING
+// ============================================
+
+// handle - one customer input: expire first (a late key press must not
+// revive an abandoned session), then dispatch, then count it as activity
+func (ctx *ATMController) handle(event func() error) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.expire()
+	err := event()
+	ctx.lastActivity = ctx.Now()
+	return err
+}
+
+// sessionWipe - drop the session data the next state has no business
+// seeing: back to IDLE / CARD_RETAINED => everything (session ended),
+// back to an earlier step => what was entered after that step
+func (ctx *ATMController) sessionWipe(next StateName) {
+	switch next {
+	case StateIdle, StateCardRetained:
+		ctx.reset()
+	case StateCardInserted:
+		ctx.atmService.EndSession(ctx.session)
+		ctx.session = nil
+		fallthrough
+	case StatePINValidated:
+		ctx.operation = ""
+		fallthrough
+	case StatePayeeEntry, StateChangePIN:
+		ctx.payee = nil
+		fallthrough
+	case StateAmountEntry, StateDenominationEntry:
+		ctx.amount = Money{}
+		ctx.denominations = nil
+	}
+}
+
+// CheckTimeouts - for the hardware loop / StartTimeoutWatcher, true if
+// a timeout fired
+func (ctx *ATMController) CheckTimeouts() bool {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	return ctx.expire()
+}
+
+// StartTimeoutWatcher - CheckTimeouts every interval until stop is called
+func (ctx *ATMController) StartTimeoutWatcher(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				ctx.CheckTimeouts()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+	}
+}
+
+// RetainedCards - cards in the capture bin, for the replenishment crew
+func (ctx *ATMController) RetainedCards() []string {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	return append([]string(nil), ctx.retainedCards...)
+}
+
+// State - current state's name
+func (ctx *ATMController) State() StateName {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	return ctx.machine.State()
+}
+
+// expire - caller holds mu
+func (ctx *ATMController) expire() bool {
+	state := ctx.machine.State()
+	timeout, ok := ctx.Timeouts[state]
+	if !ok || timeout.After <= 0 {
+		return false
+	}
+	now := ctx.Now()
+	idle := now.Sub(ctx.lastActivity)
+	if idle < timeout.After {
+		return false
+	}
+
+	card := ctx.cardNumber
+	ctx.retainCard = timeout.Retain && card != ""
+	defer func() { ctx.retainCard = false }()
+	if !ctx.machine.Can(EventTimeout) {
+		return false // IDLE has nowhere to time out to
+	}
+	next := StateIdle
+	if ctx.retainCard {
+		next = StateCardRetained
+	}
+
+	ctx.journal(now, JournalTimeout, state, card, fmt.Sprintf("no input for %v (limit %v) => %s", idle.Round(time.Second), timeout.After, next))
+	switch {
+	case next == StateCardRetained:
+		ctx.journal(now, JournalCardRetained, state, card, "card moved to capture bin")
+		fmt.Println("⌛ Session timed out - card retained, please contact your branch")
+	case card != "":
+		ctx.journal(now, JournalCardEjected, state, card, "card returned after timeout")
+		fmt.Println("⌛ Session timed out - 💳 card ejected")
+	default:
+		fmt.Println("⌛ Screen timed out - ATM ready")
+	}
+
+	ctx.fire(EventTimeout) // entering CARD_RETAINED captures the card
+	return true
+}
+
+func (ctx *ATMController) journal(at time.Time, event JournalEvent, state StateName, card, detail string) {
+	if ctx.Journal == nil {
+		return
+	}
+	ctx.Journal.Write(JournalEntry{Time: at, Event: event, State: state, Card: maskCard(card), Detail: detail})
+}
+
+// ============================================
+// TIMEOUT DEMO
+// Fake clock: time only moves when the demo says so
+// ============================================
+
+func testSessionTimeouts(atmServ ATMService) {
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	advance := func(d time.Duration) {
+		now = now.Add(d)
+		fmt.Printf("   ⏩ +%v\n", d)
+	}
+	newController := func() *ATMController {
+		controller := NewATMController(atmServ)
+		controller.Now = func() time.Time { return now }
+		controller.lastActivity = now
+		return controller
+	}
+
+	fmt.Println("⏱️  Card inserted, no PIN for 31s")
+	c1 := newController()
+	c1.InsertCard("CARD-001")
+	advance(31 * time.Second)
+	c1.CheckTimeouts()
+	fmt.Printf("   ✅ state=%s\n\n", c1.State())
+
+	fmt.Println("⏱️  PIN entered, customer walks away")
+	c2 := newController()
+	c2.InsertCard("CARD-001")
+	c2.EnterPIN("1234")
+	advance(20 * time.Second)
+	c2.CheckTimeouts() // 20s < 30s, nothing happens
+	advance(15 * time.Second)
+	err := c2.SelectOperation(OpBalance) // too late: expires first, then rejected
+	fmt.Printf("   ✅ late key press rejected: %v\n", err)
+	fmt.Printf("   ✅ state=%s retained=%v session wiped=%v\n", c2.State(), c2.RetainedCards(), c2.session == nil)
+	advance(16 * time.Second)
+	c2.CheckTimeouts()
+	fmt.Printf("   ✅ state=%s\n\n", c2.State())
+
+	fmt.Println("⏱️  Activity keeps the session alive")
+	c3 := newController()
+	c3.InsertCard("CARD-001")
+	advance(25 * time.Second)
+	c3.EnterPIN("1234")
+	advance(25 * time.Second)
+	c3.SelectOperation(OpWithdraw)
+	advance(25 * time.Second)
+	fmt.Printf("   ✅ no timeout after 75s of steady input: state=%s\n", c3.State())
+	c3.Cancel()
+	fmt.Println()
+
+	fmt.Println("⏱️  Real clock + watcher goroutine (50ms timeout)")
+	c4 := NewATMController(atmServ)
+	c4.Timeouts = map[StateName]StateTimeout{StateCardInserted: {After: 50 * time.Millisecond}}
+	stop := c4.StartTimeoutWatcher(10 * time.Millisecond)
+	c4.InsertCard("CARD-001")
+	time.Sleep(150 * time.Millisecond)
+	stop()
+	fmt.Printf("   ✅ state=%s\n\n", c4.State())
+
+	fmt.Println("📓 Journal (c1, c2)")
+	for _, controller := range []*ATMController{c1, c2} {
+		for _, entry := range controller.Journal.(*JournalV1).Entries() {
+			fmt.Printf("   %s %-13s %-17s %s %s\n", entry.Time.Format("15:04:05"), entry.Event, entry.State, entry.Card, entry.Detail)
+		}
+	}
+}
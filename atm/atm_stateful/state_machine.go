@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"yourname/atm/fsm"
+)
+
+// ============================================
+// ATM STATE MACHINE
+// Before: every state method called ctx.transition(&NextState{}) itself,
+// so the diagram below existed only in the reader's head and any state
+// could jump anywhere. Now the table is declared once (atm/fsm): state
+// methods still do the work (PIN check, dispensing, printing) but only
+// report what happened as an event; the table decides where that leads.
+//
+//	From                 Event             Guard            To
+//	-------------------  ----------------  ---------------  ------------------
+//	IDLE                 INSERT_CARD                        CARD_INSERTED
+//	CARD_INSERTED        PIN_ACCEPTED                       PIN_VALIDATED
+//	CARD_INSERTED        CARD_REFUSED      (locked card)    IDLE
+//	PIN_VALIDATED        SELECT_OPERATION  withdraw         AMOUNT_ENTRY
+//	PIN_VALIDATED        SELECT_OPERATION  deposit          DENOMINATION_ENTRY
+//	PIN_VALIDATED        SELECT_OPERATION  balance / mini   READY_TO_EXECUTE
+//	PIN_VALIDATED        SELECT_OPERATION  transfer         PAYEE_ENTRY
+//	PIN_VALIDATED        SELECT_OPERATION  change pin       CHANGE_PIN
+//	PAYEE_ENTRY          PAYEE_ACCEPTED                     AMOUNT_ENTRY
+//	AMOUNT_ENTRY         AMOUNT_ENTERED                     READY_TO_EXECUTE
+//	DENOMINATION_ENTRY   CASH_COUNTED                       READY_TO_EXECUTE
+//	READY_TO_EXECUTE     DONE                               IDLE
+//	CHANGE_PIN           DONE                               IDLE
+//	any but IDLE         CANCEL                             IDLE
+//	session states       TIMEOUT           retain           CARD_RETAINED
+//	any but IDLE         TIMEOUT                            IDLE
+//
+// Hooks: entering CARD_RETAINED moves the card to the capture bin,
+// leaving CHANGE_PIN forgets the PINs typed so far, and every transition
+// runs sessionWipe and counts as activity for the timeouts
+// ============================================
+
+type ATMEvent string
+
+const (
+	EventInsertCard      ATMEvent = "INSERT_CARD"
+	EventPINAccepted     ATMEvent = "PIN_ACCEPTED"
+	EventCardRefused     ATMEvent = "CARD_REFUSED"
+	EventSelectOperation ATMEvent = "SELECT_OPERATION"
+	EventPayeeAccepted   ATMEvent = "PAYEE_ACCEPTED"
+	EventAmountEntered   ATMEvent = "AMOUNT_ENTERED"
+	EventCashCounted     ATMEvent = "CASH_COUNTED"
+	EventDone            ATMEvent = "DONE"
+	EventCancel          ATMEvent = "CANCEL"
+	EventTimeout         ATMEvent = "TIMEOUT"
+)
+
+var ErrUnknownOperation = errors.New("unknown operation")
+
+type atmTransition = fsm.Transition[StateName, ATMEvent]
+
+type atmMachine = fsm.Machine[StateName, ATMEvent, *ATMController]
+
+// atmStates - built and validated once, shared by every controller
+var atmStates = newATMStates()
+
+func newATMStates() *fsm.Definition[StateName, ATMEvent, *ATMController] {
+	def := fsm.New[StateName, ATMEvent, *ATMController](StateIdle,
+		StateIdle, StateCardInserted, StatePINValidated, StatePayeeEntry, StateAmountEntry,
+		StateDenominationEntry, StateChangePIN, StateReadyToExecute, StateCardRetained)
+
+	def.Permit(StateIdle, EventInsertCard, StateCardInserted)
+	def.Permit(StateCardInserted, EventPINAccepted, StatePINValidated)
+	def.Permit(StateCardInserted, EventCardRefused, StateIdle)
+
+	operation := func(ops ...OperationType) fsm.Guard[*ATMController] {
+		return func(ctx *ATMController) bool {
+			for _, op := range ops {
+				if ctx.operation == op {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	def.PermitIf(StatePINValidated, EventSelectOperation, StateAmountEntry, "withdraw", operation(OpWithdraw))
+	def.PermitIf(StatePINValidated, EventSelectOperation, StateDenominationEntry, "deposit", operation(OpDeposit))
+	def.PermitIf(StatePINValidated, EventSelectOperation, StateReadyToExecute, "balance / mini", operation(OpBalance, OpMiniStatement))
+	def.PermitIf(StatePINValidated, EventSelectOperation, StatePayeeEntry, "transfer", operation(OpTransfer))
+	def.PermitIf(StatePINValidated, EventSelectOperation, StateChangePIN, "change pin", operation(OpChangePIN))
+
+	def.Permit(StatePayeeEntry, EventPayeeAccepted, StateAmountEntry)
+	def.Permit(StateAmountEntry, EventAmountEntered, StateReadyToExecute)
+	def.Permit(StateDenominationEntry, EventCashCounted, StateReadyToExecute)
+	def.Permit(StateReadyToExecute, EventDone, StateIdle)
+	def.Permit(StateChangePIN, EventDone, StateIdle)
+
+	retain := func(ctx *ATMController) bool { return ctx.retainCard }
+	for _, state := range def.States() {
+		if state == StateIdle {
+			continue
+		}
+		def.Permit(state, EventCancel, StateIdle)
+		if state != StateCardRetained {
+			def.PermitIf(state, EventTimeout, StateCardRetained, "retain", retain)
+		}
+		def.Permit(state, EventTimeout, StateIdle)
+	}
+
+	def.OnEnter(StateCardRetained, func(ctx *ATMController, t atmTransition) {
+		ctx.retainedCards = append(ctx.retainedCards, ctx.cardNumber)
+	})
+	def.OnExit(StateChangePIN, func(ctx *ATMController, t atmTransition) {
+		ctx.states[StateChangePIN] = &ChangePINState{}
+	})
+	def.OnTransition(func(ctx *ATMController, t atmTransition) {
+		ctx.sessionWipe(t.To)
+		ctx.lastActivity = ctx.Now()
+	})
+
+	if err := def.Validate(); err != nil {
+		panic(err) // the table is code: a bad one is a bug, not a runtime error
+	}
+	return def
+}
+
+// newStateHandlers - what each state does with customer input, one set
+// per controller (ChangePINState holds the PINs typed so far)
+func newStateHandlers() map[StateName]ATMState {
+	states := make(map[StateName]ATMState)
+	for _, state := range []ATMState{
+		&IdleState{}, &CardInsertState{}, &PINValidatedState{}, &PayeeEntryState{}, &AmountEntryState{},
+		&DenomiantionAndAmountEntryState{}, &ChangePINState{}, &ReadyToExecuteState{}, &CardRetainedState{},
+	} {
+		states[state.Name()] = state
+	}
+	return states
+}
+
+// fire - the only way to change state; caller holds mu
+func (ctx *ATMController) fire(event ATMEvent) error {
+	_, err := ctx.machine.Fire(event)
+	return err
+}
+
+// currentState - handler for the state the machine is in
+func (ctx *ATMController) currentState() ATMState {
+	return ctx.states[ctx.machine.State()]
+}
+
+// History - transitions of this controller, oldest first
+func (ctx *ATMController) History() []atmTransition {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	return ctx.machine.History()
+}
+
+// ============================================
+// STATE MACHINE DEMO
+// ============================================
+
+func testStateMachine(atmServ ATMService) {
+	fmt.Println("🔎 Validate:", atmStates.Validate())
+
+	broken := fsm.New[StateName, ATMEvent, *ATMController](StateIdle, StateIdle, StateCardInserted, StateCardRetained)
+	broken.Permit(StateIdle, EventInsertCard, StateCardInserted)
+	broken.Permit(StateCardInserted, EventCancel, StateIdle)
+	fmt.Println("🔎 Validate (CARD_RETAINED has no way in):", broken.Validate())
+	fmt.Println()
+
+	controller := NewATMController(atmServ)
+	controller.InsertCard("CARD-001")
+	controller.EnterPIN("1234")
+	err := controller.SelectOperation("LOTTERY")
+	fmt.Printf("   ✅ unknown operation refused by every guard: %v (state=%s)\n", err, controller.State())
+	controller.SelectOperation(OpBalance)
+	controller.Execute()
+	err = controller.Execute()
+	fmt.Printf("   ✅ no EXECUTE in %s: %v\n", controller.State(), err)
+
+	fmt.Println()
+	fmt.Println("📜 History")
+	for _, t := range controller.History() {
+		fmt.Printf("   %-18s --%-16s--> %s\n", t.From, t.Event, t.To)
+	}
+
+	fmt.Println()
+	fmt.Println("🖼️  Graphviz (dot -Tpng atm.dot -o atm.png)")
+	for _, line := range strings.Split(strings.TrimSpace(atmStates.DOT("ATM")), "\n") {
+		fmt.Println("   " + line)
+	}
+}
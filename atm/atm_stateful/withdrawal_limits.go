@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ============================================
+// WITHDRAWAL LIMITS
+// Old CanWithdraw only checked `amount > DailyLimit` for ONE withdrawal
+// => 5 withdrawals of 19,999 in a row all passed a 20,000 limit.
+// Now every check looks back at TransactionServiceV1 history:
+//
+//	Limit                                   Window            Error
+//	--------------------------------------  ----------------  --------------------------
+//	Account.DailyLimit (amount)             rolling 24h       ErrDailyLimitExceeded
+//	CardDailyLimit (amount, per card)       rolling 24h       ErrCardDailyLimitExceeded
+//	MaxWithdrawalsPerDay (count, per card)  rolling 24h       ErrWithdrawalCountExceeded
+//	VelocityRules (count, per card per ATM) e.g. 10 minutes   ErrVelocityLimitExceeded
+//
+// Rolling = last 24h from now, not "since midnight": no burst at 23:59 + 00:01
+// Completed and in-flight (PENDING) withdrawals count, a declined or
+// REVERSED attempt never uses up a limit. History is read back as far as
+// the longest VelocityRule.Window (may be > 24h, e.g. 5 per week), the
+// daily limits only look at the last 24h of it
+// ============================================
+
+type WithdrawalLimits struct {
+	CardDailyLimit       Money // zero = only Account.DailyLimit applies
+	MaxWithdrawalsPerDay int   // 0 = no count limit
+	VelocityRules        []VelocityRule
+}
+
+// VelocityRule - more than MaxWithdrawals on one ATM within Window
+// => ErrVelocityLimitExceeded, ATMServiceV1 blocks the card
+type VelocityRule struct {
+	ATMId          string // "" = every ATM, else only withdrawals on this ATM
+	MaxWithdrawals int
+	Window         time.Duration
+}
+
+const limitWindow = 24 * time.Hour
+
+// DefaultWithdrawalLimits - "more than 3 withdrawals in 10 minutes" blocks
+func DefaultWithdrawalLimits() WithdrawalLimits {
+	return WithdrawalLimits{
+		CardDailyLimit:       Rupees(25000),
+		MaxWithdrawalsPerDay: 5,
+		VelocityRules: []VelocityRule{
+			{MaxWithdrawals: 3, Window: 10 * time.Minute},
+		},
+	}
+}
+
+func (s *AccountServiceV1) checkLimits(accountId, cardNumber, atmId string, amount, accountDailyLimit Money) error {
+	lookback := limitWindow
+	for _, rule := range s.limits.VelocityRules {
+		if rule.Window > lookback {
+			lookback = rule.Window
+		}
+	}
+	history, err := s.recentWithdrawals(accountId, lookback)
+	if err != nil {
+		return err
+	}
+	now := s.Now()
+
+	accountTotal, cardTotal := Paise(0), Paise(0)
+	cardCount := 0
+	for _, txn := range history {
+		if now.Sub(txn.CreatedAt) >= limitWindow {
+			continue // only a velocity rule looks back this far
+		}
+		accountTotal = accountTotal.Add(txn.Amount)
+		if txn.CardNumber == cardNumber {
+			cardTotal = cardTotal.Add(txn.Amount)
+			cardCount++
+		}
+	}
+
+	// Velocity first: a burst is suspicious even while under the amounts
+	for _, rule := range s.limits.VelocityRules {
+		if rule.ATMId != "" && rule.ATMId != atmId {
+			continue
+		}
+		inWindow := 0
+		for _, txn := range history {
+			if txn.CardNumber == cardNumber && txn.ATMId == atmId && now.Sub(txn.CreatedAt) < rule.Window {
+				inWindow++
+			}
+		}
+		if inWindow+1 > rule.MaxWithdrawals {
+			return ErrVelocityLimitExceeded
+		}
+	}
+
+	if accountTotal.Add(amount).GreaterThan(accountDailyLimit) {
+		return ErrDailyLimitExceeded
+	}
+	if s.limits.CardDailyLimit.IsPositive() && cardTotal.Add(amount).GreaterThan(s.limits.CardDailyLimit) {
+		return ErrCardDailyLimitExceeded
+	}
+	if s.limits.MaxWithdrawalsPerDay > 0 && cardCount+1 > s.limits.MaxWithdrawalsPerDay {
+		return ErrWithdrawalCountExceeded
+	}
+	return nil
+}
+
+// recentWithdrawals - completed / in-flight withdrawals within window
+func (s *AccountServiceV1) recentWithdrawals(accountId string, window time.Duration) ([]*Transaction, error) {
+	if s.transactions == nil {
+		return nil, nil
+	}
+	history, err := s.transactions.GetTransactionHistory(accountId)
+	if errors.Is(err, ErrTransactionsDoesNotExist) {
+		return nil, nil // first withdrawal ever
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	since := s.Now().Add(-window)
+	var recent []*Transaction
+	for _, txn := range history {
+		counts := txn.Status == Completed || txn.Status == Pending
+		if txn.Type == Withdraw && counts && txn.CreatedAt.After(since) {
+			recent = append(recent, txn)
+		}
+	}
+	return recent, nil
+}
+
+// testConcurrentLimits - 6 × ₹4,000 at once against a ₹10,000 daily
+// limit: exactly 2 get through; then a "3 per 48h" rule still sees
+// withdrawals made 30h ago
+func testConcurrentLimits() {
+	rig := newATMRig(WithdrawalLimits{
+		VelocityRules: []VelocityRule{{MaxWithdrawals: 3, Window: 48 * time.Hour}},
+	})
+	atmServ, _ := rig.addATM("ATM-LIM", map[float64]int{500: 100})
+	rig.accounts.OpenAccount(&Account{
+		Id: "ACC-LIM", UserId: "USER-LIM", BankId: "BANK-001",
+		AccountType: Savings, DailyLimit: Rupees(10000),
+	}, Rupees(50000))
+	rig.issueCard("CARD-LIM", "USER-LIM", "ACC-LIM", "Race Condition", "1234")
+	session, _ := atmServ.Authenticate("CARD-LIM", "1234")
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	dispensed := 0
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if atmServ.Withdraw(session, Rupees(4000)) == nil {
+				mu.Lock()
+				dispensed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	mark := "✅"
+	if dispensed != 2 {
+		mark = "❌"
+		checkFailures++
+	}
+	fmt.Printf("   %s 6 concurrent ₹4,000 withdrawals, limit ₹10,000: %d dispensed\n", mark, dispensed)
+
+	// 30h later: the daily limit is free again, the 48h velocity rule is not
+	rig.accounts.Now = func() time.Time { return time.Now().Add(30 * time.Hour) }
+	atmServ.Withdraw(session, Rupees(500))
+	err := atmServ.Withdraw(session, Rupees(500))
+	if errors.Is(err, ErrVelocityLimitExceeded) {
+		fmt.Printf("   ✅ 4th withdrawal within 48h: %v\n", err)
+	} else {
+		checkFailed("   ❌ 4th withdrawal within 48h: expected ErrVelocityLimitExceeded, got %v\n", err)
+	}
+}
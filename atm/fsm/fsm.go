@@ -0,0 +1,309 @@
+// Package fsm - declarative finite state machine: the transition table is
+// data, declared once, instead of next-state decisions scattered across
+// State pattern methods (atm/with_state_pattern.go, atm/atm_stateful)
+//
+// Problems with the hand-written State pattern:
+//   - every state method picks the next state itself => the full diagram
+//     only exists in the reader's head
+//   - nothing stops a state from jumping anywhere, and a state no path
+//     leads to can sit in the code forever
+//   - "how did the machine get here?" has no answer
+//
+// Here:
+//   - transitions are declared: From + Event => To, with an optional
+//     Guard; the first declared transition whose guard passes wins
+//   - OnEnter / OnExit hooks per state, OnTransition for every change
+//   - every transition is recorded in the machine's History
+//   - Validate rejects unknown and unreachable states, DOT draws the table
+//
+// A Definition is built once and shared; each Machine is one running
+// instance bound to its context value C (the object the hooks work on).
+// A Machine is not safe for concurrent use: its owner serializes Fire.
+//
+// Usage:
+//
+//	def := fsm.New[State, Event, *Light](Off, Off, On, Dimmed)
+//	def.Permit(Off, Press, On)
+//	def.OnEnter(On, func(l *Light, t fsm.Transition[State, Event]) { ... })
+//	if err := def.Validate(); err != nil { ... }
+//	m := def.NewMachine(light)
+//	m.Fire(Press)
+package fsm
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoTransition     = errors.New("fsm: no transition for event")
+	ErrGuardRejected    = errors.New("fsm: transition rejected by guard")
+	ErrUnknownState     = errors.New("fsm: unknown state")
+	ErrUnreachableState = errors.New("fsm: unreachable state")
+)
+
+// DefaultHistoryLimit - transitions a Machine keeps, oldest dropped first
+const DefaultHistoryLimit = 100
+
+// Guard - true => the transition may be taken
+type Guard[C any] func(ctx C) bool
+
+// Hook - runs on entering / leaving a state or on every transition
+type Hook[S, E comparable, C any] func(ctx C, t Transition[S, E])
+
+// Rule - one row of the transition table
+type Rule[S, E comparable, C any] struct {
+	From      S
+	Event     E
+	To        S
+	Guard     Guard[C] // nil => always allowed
+	GuardName string   // label for DOT, e.g. "deposit"
+}
+
+// Transition - one transition that happened
+type Transition[S, E comparable] struct {
+	From  S
+	Event E
+	To    S
+	At    time.Time
+}
+
+// Definition - states, transition table and hooks
+type Definition[S, E comparable, C any] struct {
+	initial      S
+	states       []S // declaration order, used by Validate and DOT
+	known        map[S]bool
+	rules        []Rule[S, E, C]
+	onEnter      map[S][]Hook[S, E, C]
+	onExit       map[S][]Hook[S, E, C]
+	onTransition []Hook[S, E, C]
+}
+
+// New - initial state plus every state of the machine (initial included)
+func New[S, E comparable, C any](initial S, states ...S) *Definition[S, E, C] {
+	d := &Definition[S, E, C]{
+		initial: initial,
+		known:   make(map[S]bool),
+		onEnter: make(map[S][]Hook[S, E, C]),
+		onExit:  make(map[S][]Hook[S, E, C]),
+	}
+	for _, state := range states {
+		if !d.known[state] {
+			d.known[state] = true
+			d.states = append(d.states, state)
+		}
+	}
+	return d
+}
+
+// Permit - event in from always leads to to
+func (d *Definition[S, E, C]) Permit(from S, event E, to S) *Definition[S, E, C] {
+	d.rules = append(d.rules, Rule[S, E, C]{From: from, Event: event, To: to})
+	return d
+}
+
+// PermitIf - event in from leads to to when guard passes; several
+// PermitIf for the same from + event are tried in declaration order
+func (d *Definition[S, E, C]) PermitIf(from S, event E, to S, guardName string, guard Guard[C]) *Definition[S, E, C] {
+	d.rules = append(d.rules, Rule[S, E, C]{From: from, Event: event, To: to, Guard: guard, GuardName: guardName})
+	return d
+}
+
+func (d *Definition[S, E, C]) OnEnter(state S, hook Hook[S, E, C]) *Definition[S, E, C] {
+	d.onEnter[state] = append(d.onEnter[state], hook)
+	return d
+}
+
+func (d *Definition[S, E, C]) OnExit(state S, hook Hook[S, E, C]) *Definition[S, E, C] {
+	d.onExit[state] = append(d.onExit[state], hook)
+	return d
+}
+
+// OnTransition - after the OnEnter hooks of every transition
+func (d *Definition[S, E, C]) OnTransition(hook Hook[S, E, C]) *Definition[S, E, C] {
+	d.onTransition = append(d.onTransition, hook)
+	return d
+}
+
+// States - in declaration order
+func (d *Definition[S, E, C]) States() []S {
+	return append([]S(nil), d.states...)
+}
+
+// Rules - the transition table in declaration order
+func (d *Definition[S, E, C]) Rules() []Rule[S, E, C] {
+	return append([]Rule[S, E, C](nil), d.rules...)
+}
+
+// Validate - every state used by a rule or hook is declared, and every
+// declared state can be reached from the initial one (guards ignored:
+// a state only a guard can reach is still reachable)
+func (d *Definition[S, E, C]) Validate() error {
+	if !d.known[d.initial] {
+		return fmt.Errorf("%w: initial state %v", ErrUnknownState, d.initial)
+	}
+	var unknown []string
+	check := func(state S, where string) {
+		if !d.known[state] {
+			unknown = append(unknown, fmt.Sprintf("%v (%s)", state, where))
+		}
+	}
+	for _, rule := range d.rules {
+		check(rule.From, fmt.Sprintf("from of %v", rule.Event))
+		check(rule.To, fmt.Sprintf("to of %v", rule.Event))
+	}
+	for state := range d.onEnter {
+		check(state, "OnEnter")
+	}
+	for state := range d.onExit {
+		check(state, "OnExit")
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnknownState, strings.Join(unknown, ", "))
+	}
+
+	reached := map[S]bool{d.initial: true}
+	queue := []S{d.initial}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for _, rule := range d.rules {
+			if rule.From == state && !reached[rule.To] {
+				reached[rule.To] = true
+				queue = append(queue, rule.To)
+			}
+		}
+	}
+	var unreachable []string
+	for _, state := range d.states {
+		if !reached[state] {
+			unreachable = append(unreachable, fmt.Sprint(state))
+		}
+	}
+	if len(unreachable) > 0 {
+		return fmt.Errorf("%w from %v: %s", ErrUnreachableState, d.initial, strings.Join(unreachable, ", "))
+	}
+	return nil
+}
+
+// DOT - Graphviz diagram of the table:  dot -Tpng atm.dot -o atm.png
+func (d *Definition[S, E, C]) DOT(name string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", strconv.Quote(name))
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [shape=box, style=rounded];\n")
+	b.WriteString("\t__start [shape=point];\n")
+	for _, state := range d.states {
+		fmt.Fprintf(&b, "\t%s;\n", quote(state))
+	}
+	fmt.Fprintf(&b, "\t__start -> %s;\n", quote(d.initial))
+	for _, rule := range d.rules {
+		label := fmt.Sprint(rule.Event)
+		if rule.Guard != nil {
+			guardName := rule.GuardName
+			if guardName == "" {
+				guardName = "guard"
+			}
+			label += " [" + guardName + "]"
+		}
+		fmt.Fprintf(&b, "\t%s -> %s [label=%s];\n", quote(rule.From), quote(rule.To), strconv.Quote(label))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func quote(v any) string {
+	return strconv.Quote(fmt.Sprint(v))
+}
+
+// Machine - one running instance of a Definition
+type Machine[S, E comparable, C any] struct {
+	def     *Definition[S, E, C]
+	ctx     C
+	state   S
+	history []Transition[S, E]
+
+	Now          func() time.Time // injectable clock for History
+	HistoryLimit int              // <= 0 => DefaultHistoryLimit
+}
+
+// NewMachine - starts in the initial state; no hooks run for it.
+// The Definition must not change once machines are running
+func (d *Definition[S, E, C]) NewMachine(ctx C) *Machine[S, E, C] {
+	return &Machine[S, E, C]{
+		def:   d,
+		ctx:   ctx,
+		state: d.initial,
+		Now:   time.Now,
+	}
+}
+
+func (m *Machine[S, E, C]) State() S {
+	return m.state
+}
+
+// Can - Fire(event) would succeed right now
+func (m *Machine[S, E, C]) Can(event E) bool {
+	_, err := m.match(event)
+	return err == nil
+}
+
+// Fire - takes the first permitted transition for event: OnExit hooks of
+// the old state, state change, History, OnEnter hooks of the new state,
+// OnTransition hooks. Self transitions run the hooks too
+func (m *Machine[S, E, C]) Fire(event E) (Transition[S, E], error) {
+	rule, err := m.match(event)
+	if err != nil {
+		return Transition[S, E]{}, err
+	}
+
+	t := Transition[S, E]{From: m.state, Event: event, To: rule.To, At: m.Now()}
+	for _, hook := range m.def.onExit[t.From] {
+		hook(m.ctx, t)
+	}
+	m.state = t.To
+	m.record(t)
+	for _, hook := range m.def.onEnter[t.To] {
+		hook(m.ctx, t)
+	}
+	for _, hook := range m.def.onTransition {
+		hook(m.ctx, t)
+	}
+	return t, nil
+}
+
+func (m *Machine[S, E, C]) match(event E) (Rule[S, E, C], error) {
+	declared := false
+	for _, rule := range m.def.rules {
+		if rule.From != m.state || rule.Event != event {
+			continue
+		}
+		declared = true
+		if rule.Guard == nil || rule.Guard(m.ctx) {
+			return rule, nil
+		}
+	}
+	if declared {
+		return Rule[S, E, C]{}, fmt.Errorf("%w: %v in %v", ErrGuardRejected, event, m.state)
+	}
+	return Rule[S, E, C]{}, fmt.Errorf("%w: %v in %v", ErrNoTransition, event, m.state)
+}
+
+func (m *Machine[S, E, C]) record(t Transition[S, E]) {
+	limit := m.HistoryLimit
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	m.history = append(m.history, t)
+	if len(m.history) > limit {
+		m.history = append([]Transition[S, E](nil), m.history[len(m.history)-limit:]...)
+	}
+}
+
+// History - oldest first
+func (m *Machine[S, E, C]) History() []Transition[S, E] {
+	return append([]Transition[S, E](nil), m.history...)
+}
@@ -1,50 +1,64 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
 
-// State interface
-type LightState interface {
-	PressButton(light *Light)
-}
+	"yourname/atm/fsm"
+)
 
-// Context
-type Light struct { //light has lightstate interface and light also implements lightstate interface
-	state LightState
-}
+// State - the light's states are now names in a transition table
+// (atm/fsm) instead of one struct per state that picks the next state
+// itself. Same behaviour, but the whole diagram is declared in one place
+type LightState string
 
-func NewLight() *Light {
-	return &Light{
-		state: &OffState{},
-	}
-}
+const (
+	OffState    LightState = "OFF"
+	OnState     LightState = "ON"
+	DimmedState LightState = "DIMMED"
+)
 
-func (l *Light) PressButton() {
-	l.state.PressButton(l)
-}
+type LightEvent string
 
-func (l *Light) SetState(state LightState) {
-	l.state = state
-}
+const PressButton LightEvent = "PRESS"
+
+// OFF -> ON -> DIMMED -> OFF, every entry prints what the light does now
+var lightStates = newLightStates()
 
-type OffState struct{}
+func newLightStates() *fsm.Definition[LightState, LightEvent, *Light] {
+	def := fsm.New[LightState, LightEvent, *Light](OffState, OffState, OnState, DimmedState)
+	def.Permit(OffState, PressButton, OnState)
+	def.Permit(OnState, PressButton, DimmedState)
+	def.Permit(DimmedState, PressButton, OffState)
 
-func (s *OffState) PressButton(light *Light) {
-	fmt.Println("Light is on")
-	light.SetState(&OnState{})
+	def.OnEnter(OnState, func(l *Light, t fsm.Transition[LightState, LightEvent]) { fmt.Println("Light is on") })
+	def.OnEnter(DimmedState, func(l *Light, t fsm.Transition[LightState, LightEvent]) { fmt.Println("Light is DIMMED") })
+	def.OnEnter(OffState, func(l *Light, t fsm.Transition[LightState, LightEvent]) { fmt.Println("Light is OFF") })
+
+	if err := def.Validate(); err != nil {
+		panic(err) // the table is code: a bad one is a bug, not a runtime error
+	}
+	return def
 }
 
-type OnState struct{}
+// Context
+type Light struct { //light has a machine, the machine knows the states
+	machine *fsm.Machine[LightState, LightEvent, *Light]
+}
 
-func (s *OnState) PressButton(light *Light) {
-	fmt.Println("Light is DIMMED")
-	light.SetState(&DimmedState{})
+func NewLight() *Light {
+	light := &Light{}
+	light.machine = lightStates.NewMachine(light)
+	return light
 }
 
-type DimmedState struct{}
+func (l *Light) PressButton() {
+	if _, err := l.machine.Fire(PressButton); err != nil {
+		fmt.Println("❌", err)
+	}
+}
 
-func (s *DimmedState) PressButton(light *Light) {
-	fmt.Println("Light is OFF")
-	light.SetState(&OffState{})
+func (l *Light) State() LightState {
+	return l.machine.State()
 }
 
 func main() {
@@ -53,4 +67,10 @@ func main() {
 	light.PressButton() // ON → DIMMED
 	light.PressButton() // DIMMED → OFF
 	light.PressButton() // OFF → ON
+
+	fmt.Println("Now:", light.State())
+	for _, t := range light.machine.History() {
+		fmt.Printf("  %s --%s--> %s\n", t.From, t.Event, t.To)
+	}
+	fmt.Print(lightStates.DOT("light"))
 }
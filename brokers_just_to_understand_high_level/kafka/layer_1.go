@@ -19,9 +19,10 @@ import (
 // ============================================
 
 type Message struct {
-	Offset int64  // Position in partition
-	Key    string // Used for routing
-	Value  string // Actual data
+	Offset  int64             // Position in partition
+	Key     string            // Used for routing
+	Value   string            // Actual data
+	Headers map[string]string // Metadata (traceparent ...), broker never reads it
 }
 
 type Request struct {
@@ -31,6 +32,7 @@ type Request struct {
 	Offset     int64
 	Key        string
 	Value      string
+	Headers    map[string]string // PRODUCE only
 	GroupID    string
 	ConsumerID string
 }
@@ -107,7 +109,7 @@ func (b *Broker) CreateTopic(name string, numPartitions int) {
 // PRODUCE - Producer writes message
 // ============================================
 
-func (b *Broker) Produce(topic, key, value string) error {
+func (b *Broker) Produce(topic, key, value string, headers map[string]string) error {
 	b.mu.RLock()
 	t, exists := b.topics[topic]
 	b.mu.RUnlock()
@@ -126,9 +128,10 @@ func (b *Broker) Produce(topic, key, value string) error {
 	// Create message
 	offset := int64(len(partition.messages))
 	msg := Message{
-		Offset: offset,
-		Key:    key,
-		Value:  value,
+		Offset:  offset,
+		Key:     key,
+		Value:   value,
+		Headers: headers, // stored as is, like real Kafka record headers
 	}
 
 	// Append to partition log
@@ -308,7 +311,7 @@ func (b *Broker) handleClient(conn net.Conn) {
 
 		case "PRODUCE":
 			// Producer writing message
-			err := b.Produce(req.Topic, req.Key, req.Value)
+			err := b.Produce(req.Topic, req.Key, req.Value, req.Headers)
 			if err != nil {
 				resp.Error = err.Error()
 			}
@@ -10,7 +10,7 @@ import (
 	"fmt"
 	"time"
 
-	"yourname/kafkaclient"
+	"yourname/brokers_just_to_understand_high_level/kafka/kafkaclient"
 )
 
 func main() {
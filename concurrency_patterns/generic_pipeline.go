@@ -0,0 +1,134 @@
+// generic_pipeline.go
+// Same Generator -> Square -> FanIn as fan_in_fan_out_pattern_recommended.go,
+// built from the generic stages in concurrency_patterns/pipeline
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"yourname/concurrency_patterns/pipeline"
+)
+
+func square(ctx context.Context, n int) (int, error) {
+	select {
+	case <-time.After(100 * time.Millisecond):
+		return n * n, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func main() {
+	start := time.Now()
+
+	// 1. Fan-Out / Ordered Fan-In (any type, results in input order)
+	p, _ := pipeline.New(context.Background())
+	input := pipeline.Sequence(p, pipeline.From(p, 1, 2, 3, 4, 5))
+	workers := pipeline.FanOut(p, input, 3, pipeline.MapSequenced(square))
+	results, err := pipeline.Collect(p, pipeline.OrderedFanIn(p, workers...))
+	fmt.Printf("Squares in order: %v err=%v (took %v, ~200ms)\n", results, err, time.Since(start).Round(10*time.Millisecond))
+
+	// 2. Different element type, same stages: strings -> Filter -> Map -> Batch
+	p, _ = pipeline.New(context.Background())
+	words := pipeline.From(p, "order", "", "payment", "shipment", "", "refund")
+	nonEmpty := pipeline.Filter(p, words, func(w string) bool { return w != "" })
+	upper := pipeline.Map(p, nonEmpty, func(ctx context.Context, w string) (string, error) {
+		return strings.ToUpper(w), nil
+	})
+	batches, err := pipeline.Collect(p, pipeline.Batch(p, upper, 2, 50*time.Millisecond))
+	fmt.Printf("Batches: %v err=%v\n", batches, err)
+
+	// 3. Tee + RateLimit
+	p, _ = pipeline.New(context.Background())
+	copies := pipeline.Tee(p, pipeline.From(p, "a", "b", "c"), 2)
+	limited := pipeline.RateLimit(p, copies[0], 50*time.Millisecond)
+	audit := make(chan []string)
+	go func() {
+		var seen []string
+		for item := range copies[1] {
+			seen = append(seen, item)
+		}
+		audit <- seen
+	}()
+	rateStart := time.Now()
+	sent, _ := pipeline.Collect(p, limited)
+	fmt.Printf("Rate limited %v in %v, audit copy %v\n", sent, time.Since(rateStart).Round(10*time.Millisecond), <-audit)
+
+	// 4. Error propagation: one failing item cancels every stage
+	p, _ = pipeline.New(context.Background())
+	failing := pipeline.Map(p, pipeline.From(p, 1, 2, 3, 4), func(ctx context.Context, n int) (int, error) {
+		if n == 3 {
+			return 0, errors.New("cannot process 3")
+		}
+		return n, nil
+	})
+	partial, err := pipeline.Collect(p, failing)
+	fmt.Printf("Failing pipeline: got %v, err=%v\n", partial, err)
+
+	// 5. Parent timed out => Wait reports it, only Stop() counts as a clean end
+	parent, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	p, _ = pipeline.New(parent)
+	cut, err := pipeline.Collect(p, pipeline.Map(p, pipeline.From(p, 1, 2, 3, 4), square))
+	cancel()
+	fmt.Printf("Parent timeout: got %v, err=%v\n", cut, err)
+
+	// 6. Filter after Sequence leaves gaps, OrderedFanIn still delivers all
+	p, _ = pipeline.New(context.Background())
+	tagged := pipeline.Sequence(p, pipeline.From(p, 1, 2, 3, 4, 5, 6))
+	odd := pipeline.Filter(p, tagged, func(item pipeline.Sequenced[int]) bool { return item.Value%2 == 1 })
+	workers = pipeline.FanOut(p, odd, 2, pipeline.MapSequenced(square))
+	gapped, err := pipeline.Collect(p, pipeline.OrderedFanIn(p, workers...))
+	fmt.Printf("Odd squares with gaps: %v err=%v\n", gapped, err)
+
+	// 7. Bad stage arguments fail the pipeline instead of panicking
+	p, _ = pipeline.New(context.Background())
+	limitedBad := pipeline.RateLimit(p, pipeline.From(p, 1, 2, 3), 0)
+	none, err := pipeline.Collect(p, limitedBad)
+	fmt.Printf("RateLimit(0): got %v, ErrInvalidStage=%v (%v)\n", none, errors.Is(err, pipeline.ErrInvalidStage), err)
+	p, _ = pipeline.New(context.Background())
+	pipeline.FanOut(p, pipeline.From(p, 1, 2, 3), -1, square)
+	err = p.Wait()
+	fmt.Printf("FanOut(-1): ErrInvalidStage=%v (%v)\n", errors.Is(err, pipeline.ErrInvalidStage), err)
+
+	testNoGoroutineLeaks()
+}
+
+// testNoGoroutineLeaks - consumer stops after 2 values of a long pipeline
+// With the int-only Generator/Square/FanIn every stage would block on send
+// forever. Here Stop() cancels ctx and all stages exit.
+func testNoGoroutineLeaks() {
+	before := runtime.NumGoroutine()
+
+	nums := make([]int, 1000)
+	for i := range nums {
+		nums[i] = i
+	}
+
+	p, _ := pipeline.New(context.Background())
+	workers := pipeline.FanOut(p, pipeline.From(p, nums...), 4, square)
+	merged := pipeline.FanIn(p, workers...)
+	tees := pipeline.Tee(p, merged, 2)
+
+	<-tees[0] // read only one value, then walk away
+	p.Stop()
+	err := p.Wait()
+
+	// exited goroutines take a moment to disappear from the count
+	after := runtime.NumGoroutine()
+	for deadline := time.Now().Add(time.Second); after > before && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		after = runtime.NumGoroutine()
+	}
+	fmt.Printf("\nLeak check: goroutines before=%d after=%d leaked=%d err=%v\n", before, after, after-before, err)
+	if after > before || err != nil {
+		fmt.Println("❌ leak check failed")
+		os.Exit(1)
+	}
+}
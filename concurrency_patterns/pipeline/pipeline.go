@@ -0,0 +1,445 @@
+// Package pipeline - generic, cancellable versions of the patterns in
+// concurrency_patterns/ (Generator, Square, FanIn ...)
+//
+// Problems with the int-only versions:
+//   - copy-paste for every element type
+//   - consumer stops reading => every upstream goroutine blocks on
+//     `out <- n` forever => goroutine leak
+//   - a failing stage has no way to stop the others
+//
+// Here every stage:
+//   - is generic (Map[T, U], Filter[T] ...)
+//   - selects on ctx.Done() for EVERY send and receive => never leaks
+//   - reports errors to the Pipeline, first error cancels all stages
+//
+// Usage:
+//
+//	p, ctx := pipeline.New(ctx)
+//	nums := pipeline.From(p, 1, 2, 3)
+//	squares := pipeline.Map(p, nums, square)
+//	results, err := pipeline.Collect(p, squares)
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// errStopped - cancel cause of Stop, the only cancellation Wait swallows
+var errStopped = errors.New("pipeline stopped")
+
+// ErrInvalidStage - a stage was built with a bad argument (negative
+// worker count, non-positive interval); the pipeline fails, Wait returns it
+var ErrInvalidStage = errors.New("invalid stage")
+
+// Pipeline - shared state of all stages: one ctx, first error, goroutines
+type Pipeline struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	wg     sync.WaitGroup
+}
+
+// New - returns the pipeline and its ctx (cancelled on Stop or first error)
+func New(parent context.Context) (*Pipeline, context.Context) {
+	ctx, cancel := context.WithCancelCause(parent)
+	return &Pipeline{ctx: ctx, cancel: cancel}, ctx
+}
+
+// Stop - consumer doesn't want more values, all stages exit
+func (p *Pipeline) Stop() {
+	p.cancel(errStopped)
+}
+
+// Wait - blocks until every stage goroutine exited,
+// returns the first error (nil if pipeline just ran dry or was Stopped).
+// Parent ctx cancelled / timed out => its error (context.Canceled,
+// DeadlineExceeded or its cause): the results are cut short, not complete
+func (p *Pipeline) Wait() error {
+	p.wg.Wait()
+	err := context.Cause(p.ctx)
+	if errors.Is(err, errStopped) {
+		return nil
+	}
+	return err
+}
+
+func (p *Pipeline) fail(err error) {
+	p.cancel(err) // only first cause is kept
+}
+
+// invalid - fails the pipeline with ErrInvalidStage, the stage then
+// builds no goroutines and hands back closed / no channels
+func (p *Pipeline) invalid(format string, args ...any) {
+	p.fail(fmt.Errorf("%w: %s", ErrInvalidStage, fmt.Sprintf(format, args...)))
+}
+
+// goStage - every stage goroutine goes through here so Wait can track it
+func (p *Pipeline) goStage(fn func()) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		fn()
+	}()
+}
+
+// send - false if pipeline was cancelled while waiting for the reader
+func send[T any](ctx context.Context, out chan<- T, v T) bool {
+	select {
+	case out <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// ============================================
+// SOURCES & SINKS
+// ============================================
+
+// From - Generator for any type
+func From[T any](p *Pipeline, items ...T) <-chan T {
+	out := make(chan T)
+	p.goStage(func() {
+		defer close(out)
+		for _, item := range items {
+			if !send(p.ctx, out, item) {
+				return
+			}
+		}
+	})
+	return out
+}
+
+// Collect - drain in, then wait for all stages
+func Collect[T any](p *Pipeline, in <-chan T) ([]T, error) {
+	var items []T
+	for item := range in {
+		items = append(items, item)
+	}
+	return items, p.Wait()
+}
+
+// ============================================
+// STAGES
+// ============================================
+
+// Map - fn error stops the whole pipeline
+func Map[T, U any](p *Pipeline, in <-chan T, fn func(context.Context, T) (U, error)) <-chan U {
+	out := make(chan U)
+	p.goStage(func() {
+		defer close(out)
+		mapLoop(p, in, out, fn)
+	})
+	return out
+}
+
+func mapLoop[T, U any](p *Pipeline, in <-chan T, out chan<- U, fn func(context.Context, T) (U, error)) {
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case item, ok := <-in:
+			if !ok {
+				return
+			}
+			result, err := fn(p.ctx, item)
+			if err != nil {
+				p.fail(err)
+				return
+			}
+			if !send(p.ctx, out, result) {
+				return
+			}
+		}
+	}
+}
+
+func Filter[T any](p *Pipeline, in <-chan T, keep func(T) bool) <-chan T {
+	out := make(chan T)
+	p.goStage(func() {
+		defer close(out)
+		for {
+			select {
+			case <-p.ctx.Done():
+				return
+			case item, ok := <-in:
+				if !ok {
+					return
+				}
+				if keep(item) && !send(p.ctx, out, item) {
+					return
+				}
+			}
+		}
+	})
+	return out
+}
+
+// Batch - groups items, emits when batch is full OR maxWait passed since
+// first item of the batch (so a slow trickle still gets flushed)
+func Batch[T any](p *Pipeline, in <-chan T, size int, maxWait time.Duration) <-chan []T {
+	out := make(chan []T)
+	p.goStage(func() {
+		defer close(out)
+
+		var batch []T
+		var flush <-chan time.Time
+		var timer *time.Timer
+
+		emit := func() bool {
+			if timer != nil {
+				timer.Stop()
+			}
+			flush = nil
+			if len(batch) == 0 {
+				return true
+			}
+			ok := send(p.ctx, out, batch)
+			batch = nil
+			return ok
+		}
+
+		for {
+			select {
+			case <-p.ctx.Done():
+				return
+			case <-flush:
+				if !emit() {
+					return
+				}
+			case item, ok := <-in:
+				if !ok {
+					emit()
+					return
+				}
+				batch = append(batch, item)
+				if len(batch) == 1 && maxWait > 0 {
+					timer = time.NewTimer(maxWait)
+					flush = timer.C
+				}
+				if len(batch) >= size && !emit() {
+					return
+				}
+			}
+		}
+	})
+	return out
+}
+
+// FanOut - n workers read the SAME input, each has its own output
+// (Square workers from fan_in_fan_out_pattern_recommended.go)
+// n < 0 fails the pipeline (ErrInvalidStage), no outputs
+func FanOut[T, U any](p *Pipeline, in <-chan T, n int, fn func(context.Context, T) (U, error)) []<-chan U {
+	if n < 0 {
+		p.invalid("FanOut n = %d", n)
+		return nil
+	}
+	outs := make([]<-chan U, n)
+	for i := 0; i < n; i++ {
+		out := make(chan U)
+		outs[i] = out
+		p.goStage(func() {
+			defer close(out)
+			mapLoop(p, in, out, fn)
+		})
+	}
+	return outs
+}
+
+// FanIn - merge channels, order = whoever is ready first
+func FanIn[T any](p *Pipeline, ins ...<-chan T) <-chan T {
+	out := make(chan T)
+	var wg sync.WaitGroup
+
+	for _, in := range ins {
+		in := in
+		wg.Add(1)
+		p.goStage(func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-p.ctx.Done():
+					return
+				case item, ok := <-in:
+					if !ok {
+						return
+					}
+					if !send(p.ctx, out, item) {
+						return
+					}
+				}
+			}
+		})
+	}
+
+	p.goStage(func() {
+		wg.Wait()
+		close(out)
+	})
+	return out
+}
+
+// ============================================
+// ORDERED FAN-IN
+// FanOut workers finish in random order: 4, 1, 9 ...
+// Tag items with a sequence number BEFORE fan-out, OrderedFanIn
+// buffers early arrivals and emits strictly by sequence: 1, 4, 9 ...
+//
+// Gaps: a stage between Sequence and OrderedFanIn that drops items
+// (Filter) leaves sequence numbers that never arrive. Everything after
+// a gap is held back until the inputs close, then emitted in sequence
+// order - nothing is lost, but it isn't streamed either. Filter before
+// Sequence (or after OrderedFanIn) to keep it streaming
+// ============================================
+
+type Sequenced[T any] struct {
+	Seq   int
+	Value T
+}
+
+// Sequence - tag every item with its input position
+func Sequence[T any](p *Pipeline, in <-chan T) <-chan Sequenced[T] {
+	seq := 0
+	return Map(p, in, func(ctx context.Context, item T) (Sequenced[T], error) {
+		seq++
+		return Sequenced[T]{Seq: seq, Value: item}, nil
+	})
+}
+
+// MapSequenced - wraps fn so it keeps the sequence number (for FanOut)
+func MapSequenced[T, U any](fn func(context.Context, T) (U, error)) func(context.Context, Sequenced[T]) (Sequenced[U], error) {
+	return func(ctx context.Context, item Sequenced[T]) (Sequenced[U], error) {
+		result, err := fn(ctx, item.Value)
+		return Sequenced[U]{Seq: item.Seq, Value: result}, err
+	}
+}
+
+func OrderedFanIn[T any](p *Pipeline, ins ...<-chan Sequenced[T]) <-chan T {
+	merged := FanIn(p, ins...)
+	out := make(chan T)
+	p.goStage(func() {
+		defer close(out)
+
+		next := 1
+		early := make(map[int]T) // arrived before their turn
+		for {
+			select {
+			case <-p.ctx.Done():
+				return
+			case item, ok := <-merged:
+				if !ok {
+					flushEarly(p, out, early)
+					return
+				}
+				early[item.Seq] = item.Value
+				for {
+					value, ready := early[next]
+					if !ready {
+						break
+					}
+					delete(early, next)
+					next++
+					if !send(p.ctx, out, value) {
+						return
+					}
+				}
+			}
+		}
+	})
+	return out
+}
+
+// flushEarly - inputs closed with gaps left: whatever is still waiting
+// for a sequence number that will never come goes out in order
+func flushEarly[T any](p *Pipeline, out chan<- T, early map[int]T) {
+	seqs := make([]int, 0, len(early))
+	for seq := range early {
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+	for _, seq := range seqs {
+		if !send(p.ctx, out, early[seq]) {
+			return
+		}
+	}
+}
+
+// Tee - every item goes to ALL n outputs
+// Slowest reader sets the pace (each item waits until all outputs took it)
+// n < 0 fails the pipeline (ErrInvalidStage), no outputs
+func Tee[T any](p *Pipeline, in <-chan T, n int) []<-chan T {
+	if n < 0 {
+		p.invalid("Tee n = %d", n)
+		return nil
+	}
+	outs := make([]chan T, n)
+	readOnly := make([]<-chan T, n)
+	for i := range outs {
+		outs[i] = make(chan T)
+		readOnly[i] = outs[i]
+	}
+
+	p.goStage(func() {
+		defer func() {
+			for _, out := range outs {
+				close(out)
+			}
+		}()
+		for {
+			select {
+			case <-p.ctx.Done():
+				return
+			case item, ok := <-in:
+				if !ok {
+					return
+				}
+				for _, out := range outs {
+					if !send(p.ctx, out, item) {
+						return
+					}
+				}
+			}
+		}
+	})
+	return readOnly
+}
+
+// RateLimit - at most one item per interval (e.g. 100ms = 10/sec)
+// interval <= 0 fails the pipeline (ErrInvalidStage), out is closed
+func RateLimit[T any](p *Pipeline, in <-chan T, interval time.Duration) <-chan T {
+	out := make(chan T)
+	if interval <= 0 {
+		p.invalid("RateLimit interval = %v", interval)
+		close(out)
+		return out
+	}
+	p.goStage(func() {
+		defer close(out)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.ctx.Done():
+				return
+			case item, ok := <-in:
+				if !ok {
+					return
+				}
+				select {
+				case <-ticker.C:
+				case <-p.ctx.Done():
+					return
+				}
+				if !send(p.ctx, out, item) {
+					return
+				}
+			}
+		}
+	})
+	return out
+}
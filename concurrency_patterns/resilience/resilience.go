@@ -0,0 +1,539 @@
+// Package resilience - timeout, retry, circuit breaker, bulkhead and hedging
+// as middleware around any func(ctx) (T, error)
+//
+// Grew out of DoWorkWithTimeout in concurrency_patterns/timeout_pattern.go:
+//
+//	DoWorkWithTimeout(work func() string, timeout)
+//	  - work can't be told to stop => keeps running after timeout (leak)
+//	  - only string results, only timeouts
+//
+// Here:
+//
+//	call := resilience.Chain(chargeStripe,
+//	    resilience.WithBulkhead[*Receipt](bulkhead),      // outermost
+//	    resilience.WithRetry[*Receipt](retryPolicy),
+//	    resilience.WithBreaker[*Receipt](breaker),        // sees every attempt
+//	    resilience.WithTimeout[*Receipt](2*time.Second),  // innermost, per attempt
+//	)
+//	receipt, err := call(ctx)
+//
+// Middlewares are applied in the order given: first one wraps everything.
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	ErrTimeout      = errors.New("operation timed out")
+	ErrCircuitOpen  = errors.New("circuit breaker is open")
+	ErrBulkheadFull = errors.New("bulkhead is full")
+	ErrBadConfig    = errors.New("invalid resilience config")
+)
+
+// Func - anything that can fail: gateway call, DB query, HTTP request
+type Func[T any] func(ctx context.Context) (T, error)
+
+type Middleware[T any] func(next Func[T]) Func[T]
+
+// Chain - Chain(fn, A, B, C) = A(B(C(fn)))
+func Chain[T any](fn Func[T], middlewares ...Middleware[T]) Func[T] {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		fn = middlewares[i](fn)
+	}
+	return fn
+}
+
+// ============================================
+// TIMEOUT
+// ============================================
+
+// WithTimeout - fn gets a ctx that expires after d
+// Unlike DoWorkWithTimeout the work is TOLD to stop (ctx), and the result
+// channel is buffered so a late result never blocks the goroutine
+func WithTimeout[T any](d time.Duration) Middleware[T] {
+	return func(next Func[T]) Func[T] {
+		return func(ctx context.Context) (T, error) {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+
+			type outcome struct {
+				value T
+				err   error
+			}
+			done := make(chan outcome, 1)
+			go func() {
+				value, err := next(ctx)
+				done <- outcome{value, err}
+			}()
+
+			select {
+			case out := <-done:
+				return out.value, out.err
+			case <-ctx.Done():
+				var zero T
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					return zero, fmt.Errorf("%w after %v", ErrTimeout, d)
+				}
+				return zero, ctx.Err()
+			}
+		}
+	}
+}
+
+// ============================================
+// RETRY
+// ============================================
+
+// Backoff - how long to wait before attempt n (n starts at 1 for first retry)
+type Backoff interface {
+	Delay(retry int) time.Duration
+}
+
+type ConstantBackoff struct {
+	Interval time.Duration
+}
+
+func (b ConstantBackoff) Delay(retry int) time.Duration {
+	return b.Interval
+}
+
+// ExponentialBackoff - Base, 2*Base, 4*Base ... capped at Max
+// Jitter (0..1) randomizes each delay by ±Jitter so that many clients
+// retrying together don't hit the server in waves
+type ExponentialBackoff struct {
+	Base   time.Duration
+	Max    time.Duration
+	Jitter float64
+}
+
+func (b ExponentialBackoff) Delay(retry int) time.Duration {
+	delay := b.Base << (retry - 1)
+	if delay <= 0 || (b.Max > 0 && delay > b.Max) {
+		delay = b.Max // also guards shift overflow
+	}
+	if b.Jitter > 0 {
+		spread := float64(delay) * b.Jitter
+		delay += time.Duration(spread * (2*rand.Float64() - 1))
+	}
+	return delay
+}
+
+type RetryPolicy struct {
+	MaxAttempts int // including the first call
+	Backoff     Backoff
+	// RetryIf - which errors are worth retrying (default: all except
+	// ErrCircuitOpen and ctx cancellation)
+	RetryIf func(err error) bool
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if p.RetryIf != nil {
+		return p.RetryIf(err)
+	}
+	return !errors.Is(err, ErrCircuitOpen) && !errors.Is(err, context.Canceled)
+}
+
+func WithRetry[T any](policy RetryPolicy) Middleware[T] {
+	return func(next Func[T]) Func[T] {
+		return func(ctx context.Context) (T, error) {
+			var value T
+			var err error
+			for attempt := 1; ; attempt++ {
+				value, err = next(ctx)
+				if err == nil || attempt >= policy.MaxAttempts || !policy.retryable(err) {
+					return value, err
+				}
+
+				var delay time.Duration
+				if policy.Backoff != nil {
+					delay = policy.Backoff.Delay(attempt)
+				}
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					return value, errors.Join(err, ctx.Err())
+				}
+			}
+		}
+	}
+}
+
+// ============================================
+// CIRCUIT BREAKER
+//
+//	        failure rate >= threshold
+//	CLOSED ─────────────────────────────► OPEN
+//	  ▲                                    │
+//	  │ probes succeed                     │ OpenTimeout passed
+//	  │                                    ▼
+//	  └───────────────────────────────  HALF-OPEN ──any probe fails──► OPEN
+//
+// Failure rate is measured over a ROLLING window (e.g. last 10s in
+// 1s buckets) so old failures age out instead of counting forever
+// ============================================
+
+type CircuitState string
+
+const (
+	StateClosed   CircuitState = "CLOSED"
+	StateOpen     CircuitState = "OPEN"
+	StateHalfOpen CircuitState = "HALF_OPEN"
+)
+
+// BreakerConfig - zero values get the defaults in brackets
+type BreakerConfig struct {
+	Window           time.Duration // rolling window length (10s)
+	Buckets          int           // window split into this many buckets (10)
+	MinRequests      int           // don't trip on 1 failure out of 1 call (1)
+	FailureThreshold float64       // 0.5 = open at 50% failures (0.5), max 1
+	OpenTimeout      time.Duration // how long to stay open before probing (5s)
+	HalfOpenProbes   int           // successful probes needed to close (1)
+
+	// IsFailure - default: any error except caller cancellation.
+	// A call that isn't a failure but ended in context.Canceled counts
+	// as neither: the caller left, the dependency told us nothing
+	IsFailure     func(err error) bool
+	OnStateChange func(from, to CircuitState)
+	Now           func() time.Time // injectable clock, default time.Now
+}
+
+type bucket struct {
+	start    time.Time
+	success  int
+	failures int
+}
+
+type CircuitBreaker struct {
+	config   BreakerConfig
+	state    CircuitState
+	buckets  []bucket
+	openedAt time.Time
+
+	probesInFlight int
+	probeSuccesses int
+
+	mu sync.Mutex
+}
+
+func NewCircuitBreaker(config BreakerConfig) (*CircuitBreaker, error) {
+	if config.Window < 0 || config.OpenTimeout < 0 || config.Buckets < 0 || config.MinRequests < 0 || config.HalfOpenProbes < 0 {
+		return nil, fmt.Errorf("%w: negative breaker setting", ErrBadConfig)
+	}
+	if config.FailureThreshold < 0 || config.FailureThreshold > 1 {
+		return nil, fmt.Errorf("%w: failure threshold %v not in (0, 1]", ErrBadConfig, config.FailureThreshold)
+	}
+	if config.Window == 0 {
+		config.Window = 10 * time.Second
+	}
+	if config.Buckets == 0 {
+		config.Buckets = 10
+	}
+	if config.Window/time.Duration(config.Buckets) <= 0 {
+		return nil, fmt.Errorf("%w: window %v too short for %d buckets", ErrBadConfig, config.Window, config.Buckets)
+	}
+	if config.MinRequests == 0 {
+		config.MinRequests = 1
+	}
+	if config.FailureThreshold == 0 {
+		config.FailureThreshold = 0.5
+	}
+	if config.OpenTimeout == 0 {
+		config.OpenTimeout = 5 * time.Second
+	}
+	if config.HalfOpenProbes == 0 {
+		config.HalfOpenProbes = 1
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	if config.IsFailure == nil {
+		config.IsFailure = func(err error) bool {
+			return err != nil && !errors.Is(err, context.Canceled)
+		}
+	}
+	return &CircuitBreaker{
+		config:  config,
+		state:   StateClosed,
+		buckets: make([]bucket, config.Buckets),
+	}, nil
+}
+
+// outcome - failure, success, or neither (caller cancelled)
+func (cb *CircuitBreaker) outcome(err error) (failure, neutral bool) {
+	if cb.config.IsFailure(err) {
+		return true, false
+	}
+	return false, errors.Is(err, context.Canceled)
+}
+
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.refreshState(cb.config.Now())
+	return cb.state
+}
+
+// Allow - ask before calling; call done(err) with the outcome
+func (cb *CircuitBreaker) Allow() (done func(err error), err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	now := cb.config.Now()
+	cb.refreshState(now)
+
+	switch cb.state {
+	case StateOpen:
+		return nil, ErrCircuitOpen
+	case StateHalfOpen:
+		if cb.probesInFlight+cb.probeSuccesses >= cb.config.HalfOpenProbes {
+			return nil, ErrCircuitOpen // enough probes already
+		}
+		cb.probesInFlight++
+		return func(err error) { cb.onProbeDone(err) }, nil
+	}
+
+	return func(err error) { cb.onDone(err) }, nil
+}
+
+// refreshState - OPEN -> HALF_OPEN once OpenTimeout passed; caller holds mu
+func (cb *CircuitBreaker) refreshState(now time.Time) {
+	if cb.state == StateOpen && now.Sub(cb.openedAt) >= cb.config.OpenTimeout {
+		cb.setState(StateHalfOpen)
+		cb.probesInFlight = 0
+		cb.probeSuccesses = 0
+	}
+}
+
+func (cb *CircuitBreaker) onDone(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state != StateClosed {
+		return // breaker tripped while this call was running
+	}
+
+	failure, neutral := cb.outcome(err)
+	if neutral {
+		return
+	}
+	now := cb.config.Now()
+	b := cb.currentBucket(now)
+	if failure {
+		b.failures++
+	} else {
+		b.success++
+	}
+
+	total, failures := cb.windowCounts(now)
+	if total >= cb.config.MinRequests && float64(failures)/float64(total) >= cb.config.FailureThreshold {
+		cb.trip(now)
+	}
+}
+
+func (cb *CircuitBreaker) onProbeDone(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state != StateHalfOpen {
+		return
+	}
+	cb.probesInFlight--
+
+	failure, neutral := cb.outcome(err)
+	if failure {
+		cb.trip(cb.config.Now())
+		return
+	}
+	if neutral {
+		return // slot freed, next Allow sends another probe
+	}
+	cb.probeSuccesses++
+	if cb.probeSuccesses >= cb.config.HalfOpenProbes {
+		cb.buckets = make([]bucket, cb.config.Buckets) // fresh start
+		cb.setState(StateClosed)
+	}
+}
+
+func (cb *CircuitBreaker) trip(now time.Time) {
+	cb.openedAt = now
+	cb.setState(StateOpen)
+}
+
+func (cb *CircuitBreaker) setState(to CircuitState) {
+	from := cb.state
+	cb.state = to
+	if from != to && cb.config.OnStateChange != nil {
+		cb.config.OnStateChange(from, to)
+	}
+}
+
+func (cb *CircuitBreaker) bucketSize() time.Duration {
+	return cb.config.Window / time.Duration(cb.config.Buckets)
+}
+
+// currentBucket - ring buffer: slot is reused once its time has passed
+func (cb *CircuitBreaker) currentBucket(now time.Time) *bucket {
+	size := cb.bucketSize()
+	start := now.Truncate(size)
+	b := &cb.buckets[int(start.UnixNano()/int64(size))%len(cb.buckets)]
+	if !b.start.Equal(start) {
+		*b = bucket{start: start}
+	}
+	return b
+}
+
+func (cb *CircuitBreaker) windowCounts(now time.Time) (total, failures int) {
+	oldest := now.Add(-cb.config.Window)
+	for _, b := range cb.buckets {
+		if b.start.After(oldest) {
+			total += b.success + b.failures
+			failures += b.failures
+		}
+	}
+	return total, failures
+}
+
+func WithBreaker[T any](cb *CircuitBreaker) Middleware[T] {
+	return func(next Func[T]) Func[T] {
+		return func(ctx context.Context) (T, error) {
+			done, err := cb.Allow()
+			if err != nil {
+				var zero T
+				return zero, err
+			}
+			value, err := next(ctx)
+			done(err)
+			return value, err
+		}
+	}
+}
+
+// ============================================
+// BULKHEAD
+// Max N concurrent calls to one dependency, so a slow gateway
+// can't eat every goroutine / connection in the service
+// ============================================
+
+type Bulkhead struct {
+	slots   chan struct{}
+	maxWait time.Duration // 0 = wait until ctx done
+}
+
+func NewBulkhead(maxConcurrent int, maxWait time.Duration) *Bulkhead {
+	return &Bulkhead{
+		slots:   make(chan struct{}, maxConcurrent),
+		maxWait: maxWait,
+	}
+}
+
+func (b *Bulkhead) acquire(ctx context.Context) error {
+	var timeout <-chan time.Time
+	if b.maxWait > 0 {
+		timer := time.NewTimer(b.maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-timeout:
+		return ErrBulkheadFull
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", ErrBulkheadFull, ctx.Err())
+	}
+}
+
+func (b *Bulkhead) release() {
+	<-b.slots
+}
+
+func WithBulkhead[T any](b *Bulkhead) Middleware[T] {
+	return func(next Func[T]) Func[T] {
+		return func(ctx context.Context) (T, error) {
+			if err := b.acquire(ctx); err != nil {
+				var zero T
+				return zero, err
+			}
+			defer b.release()
+			return next(ctx)
+		}
+	}
+}
+
+// ============================================
+// HEDGED REQUESTS
+// Slow tail latency fix: if the first call hasn't answered after `after`,
+// fire another copy; first SUCCESS wins, the rest are cancelled
+// Only for idempotent calls (reads, or charges with an idempotency key)
+// ============================================
+
+// WithHedging - negative after / maxHedges => ErrBadConfig
+// (maxHedges 0 = no extra copies, just the one call)
+func WithHedging[T any](after time.Duration, maxHedges int) (Middleware[T], error) {
+	if after < 0 || maxHedges < 0 {
+		return nil, fmt.Errorf("%w: negative hedging setting (after %v, maxHedges %d)", ErrBadConfig, after, maxHedges)
+	}
+	return func(next Func[T]) Func[T] {
+		return func(ctx context.Context) (T, error) {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel() // stops the losers
+
+			type outcome struct {
+				value T
+				err   error
+			}
+			results := make(chan outcome, maxHedges+1) // buffered: losers never block
+
+			launch := func() {
+				go func() {
+					value, err := next(ctx)
+					results <- outcome{value, err}
+				}()
+			}
+
+			launch()
+			inFlight, launched := 1, 1
+			var lastErr error
+
+			timer := time.NewTimer(after)
+			defer timer.Stop()
+
+			for inFlight > 0 {
+				select {
+				case out := <-results:
+					inFlight--
+					if out.err == nil {
+						return out.value, nil
+					}
+					lastErr = out.err
+					// a copy failed fast: don't wait for the timer
+					if launched <= maxHedges {
+						launch()
+						inFlight++
+						launched++
+					}
+				case <-timer.C:
+					if launched <= maxHedges {
+						launch()
+						inFlight++
+						launched++
+						timer.Reset(after)
+					}
+				case <-ctx.Done():
+					var zero T
+					return zero, ctx.Err()
+				}
+			}
+
+			var zero T
+			return zero, lastErr
+		}
+	}, nil
+}
@@ -0,0 +1,164 @@
+// resilience_demo.go
+// timeout_pattern.go grown up: timeout + retry + circuit breaker + bulkhead
+// + hedging around a flaky payment gateway (see concurrency_patterns/resilience)
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"yourname/concurrency_patterns/resilience"
+)
+
+type Payment struct {
+	ID     string
+	Amount float64
+}
+
+// Same shape as payment_system's PaymentGateway
+type PaymentGateway interface {
+	Charge(ctx context.Context, payment *Payment) error
+}
+
+// FlakyStripeGateway - fails the first `failFirst` calls, each call takes `latency`
+type FlakyStripeGateway struct {
+	failFirst int64
+	latency   time.Duration
+	calls     int64
+}
+
+func (s *FlakyStripeGateway) Charge(ctx context.Context, payment *Payment) error {
+	call := atomic.AddInt64(&s.calls, 1)
+	select {
+	case <-time.After(s.latency):
+	case <-ctx.Done():
+		return ctx.Err() // told to stop => no leaked work
+	}
+	if call <= s.failFirst {
+		return fmt.Errorf("stripe: 503 service unavailable (call %d)", call)
+	}
+	fmt.Printf("  💳 [STRIPE] Charged $%.2f for %s (call %d)\n", payment.Amount, payment.ID, call)
+	return nil
+}
+
+// ResilientGateway - decorator: same PaymentGateway interface, so
+// PaymentService/factories don't change, they just get this instead
+type ResilientGateway struct {
+	gateway     PaymentGateway
+	middlewares []resilience.Middleware[struct{}]
+}
+
+func NewResilientGateway(gateway PaymentGateway, breaker *resilience.CircuitBreaker, bulkhead *resilience.Bulkhead) *ResilientGateway {
+	return &ResilientGateway{
+		gateway: gateway,
+		middlewares: []resilience.Middleware[struct{}]{
+			resilience.WithBulkhead[struct{}](bulkhead),
+			// retry OUTSIDE breaker: every attempt counts towards the failure
+			// rate, and retrying stops as soon as the breaker opens
+			resilience.WithRetry[struct{}](resilience.RetryPolicy{
+				MaxAttempts: 3,
+				Backoff:     resilience.ExponentialBackoff{Base: 20 * time.Millisecond, Max: 200 * time.Millisecond, Jitter: 0.2},
+			}),
+			resilience.WithBreaker[struct{}](breaker),
+			resilience.WithTimeout[struct{}](150 * time.Millisecond), // per attempt
+		},
+	}
+}
+
+func (r *ResilientGateway) Charge(ctx context.Context, payment *Payment) error {
+	call := resilience.Chain(func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, r.gateway.Charge(ctx, payment)
+	}, r.middlewares...)
+
+	_, err := call(ctx)
+	return err
+}
+
+func main() {
+	fmt.Println("=== Test 1: Retry hides 2 transient failures ===")
+	breaker, err := resilience.NewCircuitBreaker(resilience.BreakerConfig{
+		Window:           time.Second,
+		Buckets:          10,
+		MinRequests:      4,
+		FailureThreshold: 0.5,
+		OpenTimeout:      300 * time.Millisecond,
+		HalfOpenProbes:   1,
+		OnStateChange: func(from, to resilience.CircuitState) {
+			fmt.Printf("  ⚡ breaker %s -> %s\n", from, to)
+		},
+	})
+	if err != nil {
+		fmt.Println("breaker:", err)
+		return
+	}
+	bulkhead := resilience.NewBulkhead(5, 100*time.Millisecond)
+
+	stripe := NewResilientGateway(&FlakyStripeGateway{failFirst: 2, latency: 10 * time.Millisecond}, breaker, bulkhead)
+	err = stripe.Charge(context.Background(), &Payment{ID: "pay_1", Amount: 999})
+	fmt.Printf("Result: err=%v\n", err)
+
+	fmt.Println("\n=== Test 2: Slow gateway times out, timeouts trip the breaker ===")
+	slow := NewResilientGateway(&FlakyStripeGateway{latency: time.Second}, breaker, bulkhead)
+	start := time.Now()
+	err = slow.Charge(context.Background(), &Payment{ID: "pay_2", Amount: 10})
+	fmt.Printf("Result after %v: err=%v\n", time.Since(start).Round(10*time.Millisecond), err)
+
+	fmt.Println("\n=== Test 3: Breaker opens, fails fast, then recovers ===")
+	err = stripe.Charge(context.Background(), &Payment{ID: "pay_3", Amount: 5})
+	fmt.Printf("While open: err=%v (circuit open: %v)\n", err, errors.Is(err, resilience.ErrCircuitOpen))
+	time.Sleep(350 * time.Millisecond)
+	err = stripe.Charge(context.Background(), &Payment{ID: "pay_4", Amount: 5})
+	fmt.Printf("After OpenTimeout: err=%v, state=%s\n", err, breaker.State())
+
+	fmt.Println("\n=== Test 4: Hedged request beats a slow replica ===")
+	var calls int64
+	hedge, err := resilience.WithHedging[string](50*time.Millisecond, 1)
+	if err != nil {
+		fmt.Println("hedging:", err)
+		return
+	}
+	lookup := resilience.Chain(func(ctx context.Context) (string, error) {
+		latency := 10 * time.Millisecond
+		if atomic.AddInt64(&calls, 1) == 1 {
+			latency = 500 * time.Millisecond // first replica is stuck
+		}
+		select {
+		case <-time.After(latency):
+			return fmt.Sprintf("payment status from replica %d", atomic.LoadInt64(&calls)), nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}, hedge)
+	start = time.Now()
+	status, err := lookup(context.Background())
+	fmt.Printf("%q err=%v in %v (~60ms, not 500ms)\n", status, err, time.Since(start).Round(10*time.Millisecond))
+
+	fmt.Println("\n=== Test 5: Breaker config defaults, cancelled probe is neutral ===")
+	_, err = resilience.NewCircuitBreaker(resilience.BreakerConfig{FailureThreshold: 2})
+	fmt.Printf("Threshold 2: err=%v\n", err)
+	_, err = resilience.NewCircuitBreaker(resilience.BreakerConfig{Window: 5 * time.Nanosecond, Buckets: 10})
+	fmt.Printf("5ns window in 10 buckets: err=%v\n", err)
+	_, err = resilience.WithHedging[string](50*time.Millisecond, -1)
+	fmt.Printf("Hedging with -1 copies: err=%v\n", err)
+
+	now := time.Now()
+	defaults, _ := resilience.NewCircuitBreaker(resilience.BreakerConfig{Now: func() time.Time { return now }})
+	fail := errors.New("gateway down")
+	done, _ := defaults.Allow()
+	done(nil)
+	fmt.Printf("Zero config, 1 success: state=%s\n", defaults.State())
+	done, _ = defaults.Allow()
+	done(fail)
+	fmt.Printf("Then 1 failure (1/2 >= default 50%%): state=%s\n", defaults.State())
+	now = now.Add(5 * time.Second) // default OpenTimeout
+	done, _ = defaults.Allow()
+	done(context.Canceled)
+	fmt.Printf("Probe cancelled by its caller: state=%s\n", defaults.State())
+	done, _ = defaults.Allow()
+	done(nil)
+	fmt.Printf("Next probe succeeds: state=%s\n", defaults.State())
+}
@@ -1,5 +1,9 @@
 package main
 
+// NOTE: on timeout the goroutine below keeps running work() to the end,
+// nothing tells it to stop. See resilience/ (WithTimeout) for the
+// ctx-based version, plus retry, circuit breaker, bulkhead and hedging.
+
 // import (
 // 	"errors"
 // 	"fmt"
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"runtime"
 	"sync"
 	"time"
+
+	"yourname/observability/tracing"
+	"yourname/worker_pool/workerpool"
 )
 
 // UserService
@@ -186,95 +190,23 @@ type Product struct {
 // 	return quantity, nil
 // }
 
-type Job struct {
-	ID       int
-	Task     Task
-	Priority int // optional (for later advanced features)
-	Attempts int // how many times job was tried
-}
-
-type Result struct {
-	JobID  int
-	Err    error
-	Output interface{}
-}
-type WorkerPool struct {
-	NoOfWorkers    int
-	JobsChannel    chan Job
-	ResultsChannel chan Result
-	wg             sync.WaitGroup
-}
-
-func NewWorkerPool(numWorkers int, jobQueuesize int) *WorkerPool {
-	return &WorkerPool{
-		NoOfWorkers:    numWorkers,
-		JobsChannel:    make(chan Job, jobQueuesize),
-		ResultsChannel: make(chan Result, jobQueuesize),
-	}
-}
-
-func (w *WorkerPool) Start() {
-	for i := 1; i <= w.NoOfWorkers; i++ {
-		go w.worker(i) // Each worker runs in its own goroutine
-	}
-}
-func (w *WorkerPool) worker(workerID int) {
-	// Loop forever, processing jobs
-	for job := range w.JobsChannel { // Blocks until job arrives
-		//Execute the task
-		output, err := job.Task.Execute()
-
-		//Always send result(even if error occured, so
-		//that it does not block other workers)
-		w.ResultsChannel <- Result{
-			JobID:  job.ID,
-			Err:    err,
-			Output: output,
-		}
-		w.wg.Done()
-
-		// Optional: log
-		if err != nil {
-			fmt.Printf("Worker %d: Job %d failed: %v\n", workerID, job.ID, err)
-		} else {
-			fmt.Printf("Worker %d: Job %d completed\n", workerID, job.ID)
-		}
-	}
-}
-
-// Submit job to queue
-func (w *WorkerPool) SubmitJob(job Job) error {
-	//just send job  to channel
-	select {
-	case w.JobsChannel <- job:
-		w.wg.Add(1)
-		return nil
-	default:
-		return fmt.Errorf("job queue is full or pool is shut down")
-	}
-}
-
-func (w *WorkerPool) Wait() {
-	w.wg.Wait()
-}
-
-// Shutdown gracefully
-func (wp *WorkerPool) Shutdown() {
-	close(wp.JobsChannel)
-}
-
-type Task interface {
-	Execute() (interface{}, error)
-}
+// Why no local WorkerPool / Task / ResultsChannel anymore?
+//
+//	Caller A: submit CheckStock(laptop)  ─┐
+//	Caller B: submit CheckStock(phone)   ─┼─► ONE ResultsChannel ─► A reads B's answer!
+//	Caller C: submit AddStock(mouse)     ─┘   (and AddStock results were never read,
+//	                                           so channel filled up => workers blocked)
+//
+// The stock tasks below are workerpool.Task[int] and run on the shared
+// generic pool (worker_pool/workerpool): every submission gets its OWN
+// typed Future[int], panics are recovered there, IDs assigned there
 
 type CheckStockTask struct {
 	ProductId string
 	Inventory *Inventory
 }
 
-// but what if the return types of each function would have been different.
-// How we would have handled that case?
-func (r *CheckStockTask) Execute() (interface{}, error) {
+func (r *CheckStockTask) Execute(ctx context.Context) (int, error) {
 	r.Inventory.mu.Lock()
 	defer r.Inventory.mu.Unlock()
 	quantity, exists := r.Inventory.Stock[r.ProductId]
@@ -290,11 +222,12 @@ type AddStockTask struct {
 	Inventory *Inventory
 }
 
-func (r *AddStockTask) Execute() (interface{}, error) {
+// Execute - returns stock level after adding
+func (r *AddStockTask) Execute(ctx context.Context) (int, error) {
 	r.Inventory.mu.Lock()
 	defer r.Inventory.mu.Unlock()
 	r.Inventory.Stock[r.ProductId] += r.Quantity
-	return nil, nil
+	return r.Inventory.Stock[r.ProductId], nil
 }
 
 type RemoveStockTask struct {
@@ -303,29 +236,44 @@ type RemoveStockTask struct {
 	Inventory *Inventory
 }
 
-func (r *RemoveStockTask) Execute() (interface{}, error) {
+// Execute - returns stock level after removing
+func (r *RemoveStockTask) Execute(ctx context.Context) (int, error) {
 	r.Inventory.mu.Lock()
 	defer r.Inventory.mu.Unlock()
 
 	currentStock := r.Inventory.Stock[r.ProductId]
 	if currentStock < r.Quantity { // ← Check if enough stock exists!
-		return nil, fmt.Errorf("insufficient stock: have %d, need %d", currentStock, r.Quantity)
+		return currentStock, fmt.Errorf("insufficient stock: have %d, need %d", currentStock, r.Quantity)
 	}
 
 	r.Inventory.Stock[r.ProductId] -= r.Quantity
-	return nil, nil
+	return r.Inventory.Stock[r.ProductId], nil
 }
 
 type InventoryManager struct {
-	inventory    *Inventory
-	WorkerPool   *WorkerPool
-	jobIDCounter int
-	mu           sync.Mutex
+	inventory  *Inventory
+	WorkerPool *workerpool.Pool[int]
 }
 
+// Job priorities on the inventory pool: CheckStock has a caller blocked
+// on its answer, Add/RemoveStock are fire and forget => a stock check
+// never waits behind a backlog of mutations
+const (
+	StockMutationPriority = 0
+	StockCheckPriority    = 10
+)
+
 func NewInventoryManager(numWorkers, queueSize int) *InventoryManager {
+	return NewTracedInventoryManager(numWorkers, queueSize, nil)
+}
+
+// NewTracedInventoryManager - same, but every stock job becomes
+// workerpool.* spans under the caller's span (tracer nil = no spans).
+// The tracer has to go on the pool before Start, hence its own constructor
+func NewTracedInventoryManager(numWorkers, queueSize int, tracer *tracing.Tracer) *InventoryManager {
 	//initalise worker pool
-	workerPool := NewWorkerPool(numWorkers, queueSize)
+	workerPool := workerpool.NewPool[int](numWorkers, queueSize)
+	workerPool.SetTracer(tracer)
 	workerPool.Start()
 	// create inventory manager
 	return &InventoryManager{
@@ -335,64 +283,50 @@ func NewInventoryManager(numWorkers, queueSize int) *InventoryManager {
 		WorkerPool: workerPool,
 	}
 }
-func (im *InventoryManager) generateJobID() int {
-	im.mu.Lock()
-	defer im.mu.Unlock()
-	im.jobIDCounter++
-	return im.jobIDCounter
-}
 
-func (im *InventoryManager) AddStock(productId string, quantity int) error {
+// AddStock - fire and forget, caller can still Get() the future
+// to learn the new stock level (nobody HAS to read it)
+// ctx - cancels the submit, carries the caller's trace into the job spans
+func (im *InventoryManager) AddStock(ctx context.Context, productId string, quantity int) (*workerpool.Future[int], error) {
 	//submit job
-	job := Job{
-		ID: im.generateJobID(),
+	return im.WorkerPool.SubmitJob(ctx, workerpool.Job[int]{
 		Task: &AddStockTask{
 			ProductId: productId,
 			Quantity:  quantity,
 			Inventory: im.inventory,
 		},
-	}
-
-	return im.WorkerPool.SubmitJob(job)
+		Priority: StockMutationPriority,
+	})
 }
 
-func (im *InventoryManager) RemoveStock(productId string, quantity int) error {
+func (im *InventoryManager) RemoveStock(ctx context.Context, productId string, quantity int) (*workerpool.Future[int], error) {
 	// submit job
-	job := Job{
-		ID: im.generateJobID(),
+	return im.WorkerPool.SubmitJob(ctx, workerpool.Job[int]{
 		Task: &RemoveStockTask{
 			ProductId: productId,
 			Quantity:  quantity,
 			Inventory: im.inventory,
 		},
-	}
-
-	return im.WorkerPool.SubmitJob(job)
+		Priority: StockMutationPriority,
+	})
 }
 
 // check stock has to return the current quantity of a productId
-func (im *InventoryManager) CheckStock(productId string) (int, error) {
+func (im *InventoryManager) CheckStock(ctx context.Context, productId string) (int, error) {
 	// submit job
-	job := Job{
-		ID: im.generateJobID(),
+	future, err := im.WorkerPool.SubmitJob(ctx, workerpool.Job[int]{
 		Task: &CheckStockTask{
 			ProductId: productId,
 			Inventory: im.inventory,
 		},
-	}
-
-	err := im.WorkerPool.SubmitJob(job)
+		Priority: StockCheckPriority, // someone is waiting on this one
+	})
 	if err != nil {
 		return 0, err
 	}
 
-	//Wait for result because we want to return the quantity here itslef
-	result := <-im.WorkerPool.ResultsChannel
-	if result.Err != nil {
-		return 0, result.Err
-	}
-
-	return result.Output.(int), nil
+	//Wait for OUR result because we want to return the quantity here itslef
+	return future.Get()
 }
 
 type Inventory struct {
@@ -473,9 +407,41 @@ type OrderService struct {
 	cartService      *CartService
 	shippingService  *ShippingService
 	orders           map[string]*Order //[order_id, Order object] //in real life it will be repo which will store in db
+	tracer           *tracing.Tracer   // nil = no spans
+}
+
+// NewOrderService - pass the same tracer the inventory pool got
+// (NewTracedInventoryManager) => the stock checks land in the order's trace
+func NewOrderService(productService *ProductService, userService *UserService, paymentService PaymentGateway,
+	inventoryService *InventoryManager, cartService *CartService, shippingService *ShippingService, tracer *tracing.Tracer) *OrderService {
+	return &OrderService{
+		productService:   productService,
+		userService:      userService,
+		paymentService:   paymentService,
+		inventoryService: inventoryService,
+		cartService:      cartService,
+		shippingService:  shippingService,
+		orders:           make(map[string]*Order),
+		tracer:           tracer,
+	}
+}
+
+// PlaceOrder - root span of the order's trace, stock checks run on the
+// inventory pool as child spans (workerpool.job / execute ...)
+func (s *OrderService) PlaceOrder(ctx context.Context, userId string, cartId string, addressId string, paymentType PaymentType) (*Order, error) {
+	ctx, span := s.tracer.Start(ctx, "OrderService.PlaceOrder")
+	span.SetAttribute("user.id", userId)
+	defer span.End()
+
+	order, err := s.placeOrder(ctx, userId, cartId, addressId, paymentType)
+	if order != nil {
+		span.SetAttribute("order.id", order.Id)
+	}
+	span.RecordError(err)
+	return order, err
 }
 
-func (s *OrderService) PlaceOrder(userId string, cartId string, addressId string, paymentType PaymentType) (*Order, error) {
+func (s *OrderService) placeOrder(ctx context.Context, userId string, cartId string, addressId string, paymentType PaymentType) (*Order, error) {
 	// ==================== WHY THIS DESIGN? ====================
 	//
 	// PROBLEM: How to handle cart → order conversion?
@@ -548,7 +514,7 @@ func (s *OrderService) PlaceOrder(userId string, cartId string, addressId string
 
 		// IMPORTANT: Validate stock BEFORE accepting order
 		// Prevents overselling: If 5 in stock, can't order 10
-		stock, err := s.inventoryService.CheckStock(itemId)
+		stock, err := s.inventoryService.CheckStock(ctx, itemId)
 		if err != nil || stock < quantity {
 			return nil, fmt.Errorf("insufficient stock for %s (available: %d, requested: %d)",
 				product.Name, stock, quantity)
@@ -596,7 +562,7 @@ func (s *OrderService) PlaceOrder(userId string, cartId string, addressId string
 	//          Payment succeeds
 	//          Inventory: 50 → 48 (reduce by 2)
 	for _, orderItem := range orderItems {
-		// err := s.inventoryService.RemoveStock(orderItem.ProductId, orderItem.Quantity)
+		// err := s.inventoryService.RemoveStock(ctx, orderItem.ProductId, orderItem.Quantity)
 		if err != nil {
 			// TODO: ROLLBACK needed here!
 			// Payment succeeded but inventory update failed
@@ -870,8 +836,16 @@ func (r *RazorpayGateway) RefundPayment(paymentId string, amount float64) error
 type MockPaymentGateway struct {
 }
 
+// ProcessPayment - always succeeds, PlaceOrder needs a Payment back
 func (m *MockPaymentGateway) ProcessPayment(orderId string, amount float64, paymentType PaymentType) (*Payment, error) {
-	return nil, nil
+	return &Payment{
+		Id:        "PAY-" + orderId,
+		OrderId:   orderId,
+		Type:      paymentType,
+		Status:    Success,
+		Amount:    amount,
+		Timestamp: time.Now(),
+	}, nil
 }
 func (m *MockPaymentGateway) RefundPayment(paymentId string, amount float64) error {
 	return nil
@@ -961,19 +935,19 @@ func testHighLoadWithWorkerPool() {
 
 	//start worker pool
 	inventoryManager := NewInventoryManager(10, 100)
-	err := inventoryManager.AddStock("laptop", 1)
+	_, err := inventoryManager.AddStock(context.Background(), "laptop", 1)
 	if err != nil {
-		fmt.Printf("err ", err)
+		fmt.Printf("err %v\n", err)
 	}
-	quantity, err := inventoryManager.CheckStock("laptop")
+	quantity, err := inventoryManager.CheckStock(context.Background(), "laptop")
 	if err != nil {
-		fmt.Printf("err ", err)
+		fmt.Printf("err %v\n", err)
 	}
 
 	if quantity > 1 {
-		err = inventoryManager.RemoveStock("laptop", 1)
+		_, err = inventoryManager.RemoveStock(context.Background(), "laptop", 1)
 		if err != nil {
-			fmt.Printf("err ", err)
+			fmt.Printf("err %v\n", err)
 		}
 	}
 
@@ -998,4 +972,105 @@ func main() {
 
 	//simulate 100000 inventory operations
 	testHighLoadWithWorkerPool()
+
+	testConcurrentCheckStock()
+
+	testTracedPlaceOrder()
+}
+
+// testTracedPlaceOrder - OrderService and the inventory pool share one
+// tracer => each PlaceOrder is one trace: the root span, one
+// workerpool.job subtree per stock check, errors on the span that failed
+func testTracedPlaceOrder() {
+	fmt.Println("\n--- Traced PlaceOrder ---")
+	recorder := tracing.NewRecorder()
+	tracer := tracing.NewTracer("order-service", recorder)
+
+	inventory := NewTracedInventoryManager(2, 10, tracer)
+	defer inventory.WorkerPool.Shutdown(context.Background())
+	inventory.AddStock(context.Background(), "laptop", 5)
+	inventory.AddStock(context.Background(), "mouse", 20)
+	inventory.WorkerPool.Wait()
+
+	products := &ProductService{products: map[string]*Product{
+		"laptop": {Id: "laptop", Name: "Laptop", Price: 1000},
+		"mouse":  {Id: "mouse", Name: "Mouse", Price: 25},
+	}}
+	users := &UserService{Users: map[string]*User{
+		"user-1": {Id: "user-1", Name: "Asha", Addresses: []Address{{Id: "home", UserId: "user-1", City: "Pune"}}},
+	}}
+	carts := &CartService{carts: make(map[string]*Cart), productService: products, inventoryService: inventory}
+	orders := NewOrderService(products, users, &MockPaymentGateway{}, inventory, carts,
+		&ShippingService{provider: &FedX{}}, tracer)
+
+	place := func(label string, items map[string]int) {
+		for itemId, quantity := range items {
+			carts.AddToCart("user-1", itemId, quantity)
+		}
+		order, err := orders.PlaceOrder(context.Background(), "user-1", "", "home", UPI)
+		if err != nil {
+			fmt.Printf("%s: %v\n", label, err)
+		} else {
+			fmt.Printf("%s: order %s %s, total %.2f\n", label, order.Id, order.Status, order.TotalAmount)
+		}
+
+		tracer.Flush()
+		spans := recorder.Spans()
+		for i := len(spans) - 1; i >= 0; i-- {
+			if spans[i].Name == "OrderService.PlaceOrder" {
+				fmt.Print(tracing.FormatTree(spans, spans[i].SpanContext.TraceID))
+				break
+			}
+		}
+	}
+
+	place("2 laptops + 1 mouse", map[string]int{"laptop": 2, "mouse": 1})
+	place("10 laptops", map[string]int{"laptop": 10})
+}
+
+// testConcurrentCheckStock - stress test for correlated results
+// product-i has exactly i units, many callers check concurrently while
+// AddStock noise runs => with a shared ResultsChannel callers got each
+// other's answers, with futures every caller must get its own
+func testConcurrentCheckStock() {
+	const products = 50
+	const callersPerProduct = 20
+
+	inventoryManager := NewInventoryManager(10, 2000)
+	for i := 1; i <= products; i++ {
+		inventoryManager.AddStock(context.Background(), fmt.Sprintf("product-%d", i), i)
+	}
+	inventoryManager.WorkerPool.Wait()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	wrong, failed := 0, 0
+
+	for c := 0; c < callersPerProduct; c++ {
+		for i := 1; i <= products; i++ {
+			wg.Add(2)
+
+			// noise: results nobody reads must not block anything
+			go func() {
+				defer wg.Done()
+				inventoryManager.AddStock(context.Background(), "noise", 1)
+			}()
+
+			go func(i int) {
+				defer wg.Done()
+				quantity, err := inventoryManager.CheckStock(context.Background(), fmt.Sprintf("product-%d", i))
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					failed++
+				} else if quantity != i {
+					wrong++
+				}
+			}(i)
+		}
+	}
+	wg.Wait()
+
+	fmt.Printf("\nConcurrent CheckStock: %d calls, %d wrong answers, %d submit errors\n",
+		products*callersPerProduct, wrong, failed)
 }
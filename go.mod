@@ -0,0 +1,6 @@
+// One module for the whole repo: shared packages are imported by their
+// path from here (yourname/<folder>/<package>).
+// Demos still run from their own folder: `go run file.go` or `go run .`
+module yourname
+
+go 1.22
@@ -1,123 +1,104 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"yourname/observability/tracing"
+	"yourname/worker_pool/workerpool"
+)
+
+var (
+	ErrNoResponders = errors.New("no subscribers on topic to respond")
+	ErrNotARequest  = errors.New("message was published, not requested: nothing to reply to")
+	ErrNoRequester  = errors.New("requester is no longer waiting (timed out or already answered)")
 )
 
 type Message struct {
-	Topic string
-	Data  interface{}
+	Topic  string
+	Data   interface{}
+	Key    string // optional ordering key (e.g. orderID), "" = no ordering needed
+	Offset int64  // position in topic (assigned by Publish, like Kafka offset)
+
+	Metadata map[string]string // traceparent etc. (PublishContext / Request fill it)
+
+	// Request/Reply (empty for normal fire-and-forget Publish)
+	CorrelationID string // unique per Request, matches reply to request
+	ReplyTo       string // inbox the responder should answer on
+
+	pubsub *PubSub // so the responder can call msg.Reply(data)
+}
+
+// Reply - responder side of Request/Reply
+// Sends data back to the inbox of the request this message belongs to
+func (m Message) Reply(data interface{}) error {
+	if m.ReplyTo == "" || m.pubsub == nil {
+		return ErrNotARequest
+	}
+	return m.pubsub.reply(m.ReplyTo, Message{
+		Topic:         m.ReplyTo,
+		Data:          data,
+		CorrelationID: m.CorrelationID,
+	})
 }
 
 type Topic struct {
 	name        string
 	subscribers []*Subscriber
+	nextOffset  int64 // counter for assigning offsets to new messages
 	mu          sync.RWMutex
+
+	// sending - one token: one publish pushes to subscribers at a time
+	// (offsets arrive in order). A channel, not a mutex, so a publisher
+	// can give up waiting when its ctx ends
+	sending chan struct{}
 }
 
 type PubSub struct {
 	topics map[string]*Topic //[topic_name -> topic_object]
 	mu     sync.RWMutex
-}
-type Task interface {
-	Execute() (interface{}, error)
+
+	// Request/Reply: one inbox per in-flight request
+	inboxes   map[string]chan Message //[reply_to -> inbox]
+	inboxMu   sync.Mutex
+	requestID int64 // counter for correlation IDs
+
+	tracer *tracing.Tracer // optional, nil = no publish / process spans
 }
 
+// ProcessMessageTask - runs on the shared generic pool (worker_pool/workerpool)
+// as a workerpool.Task[struct{}]: handlers return nothing, so no result type
 type ProcessMessageTask struct {
 	msg     Message
 	handler func(Message)
+	done    func() // called after handler finishes (subscriber bookkeeping)
 }
 
-func (t *ProcessMessageTask) Execute() (interface{}, error) {
+func (t *ProcessMessageTask) Execute(ctx context.Context) (struct{}, error) {
+	defer t.done() // also on panic (pool recovers it), else Wait() hangs
 	t.handler(t.msg)
-	return nil, nil
-}
-
-type Job struct {
-	ID       int
-	Task     Task
-	Priority int // optional (for later advanced features)
-	Attempts int // how many times job was tried
-}
-
-type Result struct {
-	JobID  int
-	Err    error
-	Output interface{}
-}
-type WorkerPool struct {
-	NoOfWorkers    int
-	JobsChannel    chan Job
-	ResultsChannel chan Result
-	wg             sync.WaitGroup
-}
-
-func NewWorkerPool(numWorkers int, jobQueuesize int) *WorkerPool {
-	return &WorkerPool{
-		NoOfWorkers:    numWorkers,
-		JobsChannel:    make(chan Job, jobQueuesize),
-		ResultsChannel: make(chan Result, jobQueuesize),
-	}
-}
-
-func (w *WorkerPool) Start() {
-	for i := 1; i <= w.NoOfWorkers; i++ {
-		go w.worker(i) // Each worker runs in its own goroutine
-	}
-}
-
-func (w *WorkerPool) worker(workerID int) {
-	// Loop forever, processing jobs
-	for job := range w.JobsChannel { // Blocks until job arrives
-		//Execute the task
-		output, err := job.Task.Execute()
-
-		//Always send result(even if error occured, so
-		//that it does not block other workers)
-		w.ResultsChannel <- Result{
-			JobID:  job.ID,
-			Err:    err,
-			Output: output,
-		}
-		w.wg.Done()
-
-		// Optional: log
-		if err != nil {
-			fmt.Printf("Worker %d: Job %d failed: %v\n", workerID, job.ID, err)
-		}
-	}
-}
-
-// Submit job to queue
-func (w *WorkerPool) SubmitJob(job Job) error {
-	//just send job  to channel
-	select {
-	case w.JobsChannel <- job:
-		w.wg.Add(1)
-		return nil
-	default:
-		return fmt.Errorf("job queue is full or pool is shut down")
-	}
-}
-
-func (w *WorkerPool) Wait() {
-	w.wg.Wait()
-}
-
-// Shutdown gracefully
-func (wp *WorkerPool) Shutdown() {
-	close(wp.JobsChannel)
+	return struct{}{}, nil
 }
 
 func NewPubSub() *PubSub {
 	return &PubSub{
-		topics: make(map[string]*Topic),
+		topics:  make(map[string]*Topic),
+		inboxes: make(map[string]chan Message),
 	}
 }
 
+// SetTracer - "<topic> publish" span per Publish / Request, "<topic>
+// process" span per handled message (keyed lane or worker pool alike);
+// call before publishing
+func (ps *PubSub) SetTracer(tracer *tracing.Tracer) {
+	ps.tracer = tracer
+}
+
 func (ps *PubSub) CreateTopic(topicName string) error {
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
@@ -128,6 +109,7 @@ func (ps *PubSub) CreateTopic(topicName string) error {
 	topic := &Topic{
 		name:        topicName,
 		subscribers: []*Subscriber{},
+		sending:     make(chan struct{}, 1),
 	}
 	ps.topics[topicName] = topic
 	return nil
@@ -157,7 +139,9 @@ func (ps *PubSub) Subscribe(topicName string, subscriber *Subscriber) error {
 	return nil
 }
 
-func (ps *PubSub) Unsubscribe(topicName string, subscriberId string) error {
+// Unsubscribe - waits for the in-flight publish to finish first; that
+// publish may be stuck on a full subscriber => bounded by ctx like Publish
+func (ps *PubSub) Unsubscribe(ctx context.Context, topicName string, subscriberId string) error {
 	// Remove subscriber from topic
 
 	//Lock Pubsub to read topics map
@@ -169,6 +153,14 @@ func (ps *PubSub) Unsubscribe(topicName string, subscriberId string) error {
 		return errors.New("Topic does not exist")
 	}
 
+	//Wait for an in-flight publish, it may still push to this subscriber
+	select {
+	case topic.sending <- struct{}{}:
+	case <-ctx.Done():
+		return fmt.Errorf("unsubscribe '%s' from '%s': %w", subscriberId, topicName, ctx.Err())
+	}
+	defer func() { <-topic.sending }()
+
 	//Lock Topic to modify subscribers
 	topic.mu.Lock() //Lock the topic
 	var updatedSubscribers []*Subscriber
@@ -194,50 +186,247 @@ func (ps *PubSub) Unsubscribe(topicName string, subscriberId string) error {
 }
 
 func (ps *PubSub) Publish(topicName string, data interface{}) error {
+	//no key => no ordering guarantee, any worker can pick it up
+	return ps.PublishWithKey(topicName, "", data)
+}
+
+// PublishWithKey - messages with the same key are processed serially
+// (in offset order) by every subscriber, different keys still run in parallel
+// Example: key = orderID, so CONFIRMED is never processed after DELIVERED
+func (ps *PubSub) PublishWithKey(topicName string, key string, data interface{}) error {
+	return ps.PublishContext(context.Background(), topicName, key, data)
+}
+
+// PublishContext - like PublishWithKey, the trace in ctx goes along in
+// Message.Metadata => the handler's ctx continues it
+func (ps *PubSub) PublishContext(ctx context.Context, topicName string, key string, data interface{}) error {
+	_, err := ps.publish(ctx, Message{Topic: topicName, Data: data, Key: key})
+	return err
+}
+
+// publish - common path for Publish and Request
+// Returns number of subscribers the message was delivered to
+func (ps *PubSub) publish(ctx context.Context, msg Message) (int, error) {
+	ctx, span := ps.tracer.StartSpan(ctx, msg.Topic+" publish", tracing.SpanOptions{
+		Kind: tracing.KindProducer,
+		Attributes: []tracing.Attribute{
+			{Key: "messaging.destination.name", Value: msg.Topic},
+			{Key: "messaging.message.key", Value: msg.Key},
+		},
+	})
+	defer span.End()
+	msg.Metadata = make(map[string]string)
+	tracing.Inject(ctx, msg.Metadata)
+
 	//publish to all subscribers of this topic
 	//getTopic from topic name
 	ps.mu.RLock()
-	topic, ok := ps.topics[topicName]
+	topic, ok := ps.topics[msg.Topic]
 	ps.mu.RUnlock()
 
 	if !ok {
-		return errors.New("Topic does not exist")
+		err := errors.New("Topic does not exist")
+		span.RecordError(err)
+		return 0, err
 	}
 
-	topic.mu.RLock()
+	//Hold the sending token while assigning offset AND pushing to subscribers,
+	//otherwise two concurrent publishers could push offset 2 before offset 1
+	//A full subscriber blocks us here (backpressure) until ctx ends
+	select {
+	case topic.sending <- struct{}{}:
+	case <-ctx.Done():
+		span.RecordError(ctx.Err())
+		return 0, ctx.Err()
+	}
+	defer func() { <-topic.sending }()
+
+	topic.mu.Lock()
+	msg.Offset = topic.nextOffset
+	msg.pubsub = ps
+	topic.nextOffset++
+	subscribers := append([]*Subscriber(nil), topic.subscribers...)
+	topic.mu.Unlock()
+
 	//publish msg to all subscribers
-	subscribers := topic.subscribers
-	topic.mu.RUnlock()
+	//ctx ended half way => the rest never see this offset (a gap, like a
+	//Kafka consumer that skipped a record), the caller gets ctx.Err()
+	for i, subscriber := range subscribers {
+		if err := subscriber.deliver(ctx, msg); err != nil {
+			span.RecordError(err)
+			return i, err
+		}
+	}
+	return len(subscribers), nil
+}
+
+// Request - synchronous query over the bus (like NATS request/reply)
+//
+// Flow:
+//  1. Create a private inbox for THIS request (keyed by correlation ID)
+//  2. Publish the request with ReplyTo = inbox
+//  3. Responder handles it and calls msg.Reply(data) -> lands in our inbox
+//  4. Wait for the reply OR ctx timeout, whichever comes first
+//
+// If several subscribers reply, the first reply wins, the rest are dropped
+func (ps *PubSub) Request(ctx context.Context, topicName string, data interface{}) (interface{}, error) {
+	correlationID := fmt.Sprintf("req-%d", atomic.AddInt64(&ps.requestID, 1))
+	replyTo := "_INBOX." + correlationID
+
+	//buffer 1 => responder never blocks, even if we already timed out
+	inbox := make(chan Message, 1)
+	ps.inboxMu.Lock()
+	ps.inboxes[replyTo] = inbox
+	ps.inboxMu.Unlock()
+
+	//always remove inbox, so late replies get ErrNoRequester
+	defer func() {
+		ps.inboxMu.Lock()
+		delete(ps.inboxes, replyTo)
+		ps.inboxMu.Unlock()
+	}()
 
-	for _, subscriber := range subscribers {
-		subscriber.channel <- Message{Topic: topicName, Data: data}
+	delivered, err := ps.publish(ctx, Message{
+		Topic:         topicName,
+		Data:          data,
+		CorrelationID: correlationID,
+		ReplyTo:       replyTo,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("request %s on topic '%s': %w", correlationID, topicName, err)
+	}
+	if delivered == 0 {
+		return nil, ErrNoResponders
+	}
+
+	select {
+	case reply := <-inbox:
+		return reply.Data, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("request %s on topic '%s': %w", correlationID, topicName, ctx.Err())
+	}
+}
+
+// reply - route a reply to the requester's inbox
+func (ps *PubSub) reply(replyTo string, msg Message) error {
+	ps.inboxMu.Lock()
+	inbox, ok := ps.inboxes[replyTo]
+	ps.inboxMu.Unlock()
+
+	if !ok {
+		return ErrNoRequester
+	}
+
+	select {
+	case inbox <- msg:
+		return nil
+	default:
+		//someone already answered this request
+		return ErrNoRequester
 	}
-	return nil
 }
 
 type Subscriber struct {
-	id           string
-	channel      chan Message
-	handler      func(Message)
-	WorkerPool   *WorkerPool
-	jobIDCounter int
-	mu           sync.Mutex
+	id         string
+	channel    chan Message
+	handler    func(context.Context, Message)
+	WorkerPool *workerpool.Pool[struct{}] // for messages WITHOUT key (any order is fine)
+	lanes      []lane                     // for messages WITH key (one goroutine per lane)
+	pending    sync.WaitGroup             // messages delivered but not yet processed
 }
 
 func NewSubscriber(id string, handler func(Message)) *Subscriber {
+	return NewSubscriberContext(id, func(_ context.Context, msg Message) {
+		handler(msg)
+	})
+}
+
+// NewSubscriberContext - handler's ctx carries the publisher's trace
+// (and a "<topic> process" span if the PubSub has a tracer)
+// => work the handler starts shows up in the same trace
+func NewSubscriberContext(id string, handler func(context.Context, Message)) *Subscriber {
+	workerPool := workerpool.NewPool[struct{}](5, 50)
+
+	//Same parallelism for keyed messages as for the worker pool
+	lanes := make([]lane, workerPool.Size())
+	for i := range lanes {
+		lanes[i] = make(lane, laneBuffer)
+	}
+
 	return &Subscriber{
 		id:         id,
 		channel:    make(chan Message, 10),
 		handler:    handler,
-		WorkerPool: NewWorkerPool(5, 50),
+		WorkerPool: workerPool,
+		lanes:      lanes,
+	}
+}
+
+// deliver - called by PubSub.Publish
+// Keyed messages go straight to their lane, the rest to the channel
+// (dispatcher => worker pool). Full either way => wait until ctx ends
+// Counts the message as pending BEFORE it is queued, so Wait() can't
+// return while a message is still sitting in the channel or a lane
+func (s *Subscriber) deliver(ctx context.Context, msg Message) error {
+	queue := s.channel
+	if msg.Key != "" {
+		queue = s.laneFor(msg.Key)
 	}
+	s.pending.Add(1)
+	select {
+	case queue <- msg:
+		return nil
+	case <-ctx.Done():
+		s.pending.Done()
+		return ctx.Err()
+	}
+}
+
+// process - run the handler inside a "<topic> process" span that
+// continues the trace the publisher put in Metadata
+func (s *Subscriber) process(msg Message) {
+	var tracer *tracing.Tracer
+	if msg.pubsub != nil {
+		tracer = msg.pubsub.tracer
+	}
+	ctx := tracing.Extract(context.Background(), msg.Metadata)
+	ctx, span := tracer.StartSpan(ctx, msg.Topic+" process", tracing.SpanOptions{
+		Kind: tracing.KindConsumer,
+		Attributes: []tracing.Attribute{
+			{Key: "messaging.destination.name", Value: msg.Topic},
+			{Key: "messaging.message.key", Value: msg.Key},
+			{Key: "messaging.consumer.id", Value: s.id},
+		},
+	})
+	defer span.End()
+
+	// the worker pool recovers panics, a lane goroutine would take the
+	// whole process down => recover here, for both alike
+	defer func() {
+		if r := recover(); r != nil {
+			span.RecordError(fmt.Errorf("handler panic: %v", r))
+			fmt.Printf("[%s] Handler panicked at offset %d: %v\n", s.id, msg.Offset, r)
+		}
+	}()
+	s.handler(ctx, msg)
 }
 
-func (s *Subscriber) generateJobID() int {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.jobIDCounter++
-	return s.jobIDCounter
+// lane - FIFO of keyed messages drained by ONE goroutine
+// Bounded: a slow key can't pile up memory without limit. The PUBLISHER
+// fills it (deliver), not the dispatcher => a full lane makes that
+// publisher wait until its ctx ends (backpressure, like a full channel)
+// while the dispatcher keeps feeding the worker pool and the other lanes
+// keep draining
+type lane chan Message
+
+const laneBuffer = 10
+
+// laneFor - same key always hashes to the same lane
+// => same key always processed by the same goroutine => serial, in order
+func (s *Subscriber) laneFor(key string) lane {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return s.lanes[h.Sum32()%uint32(len(s.lanes))]
 }
 
 func (s *Subscriber) start() {
@@ -256,26 +445,57 @@ func (s *Subscriber) start() {
 	// PubSub = N Subscribers = N WorkerPools (each started once per subscriber)
 	s.WorkerPool.Start()
 
+	//Why lanes and not the worker pool for keyed messages?
+	//Worker pool: all workers read from ONE shared queue
+	//  => msg1 (CONFIRMED) goes to worker 1, msg2 (DELIVERED) goes to worker 2
+	//  => worker 2 may finish first => out of order!
+	//Lanes: hash(key) picks the lane, each lane has ONE goroutine
+	//  => all messages of a key are processed one by one, in offset order
+	//  => different keys land on different lanes => still parallel
+	for _, l := range s.lanes {
+		go func(l lane) {
+			for msg := range l {
+				s.process(msg)
+				s.pending.Done()
+			}
+		}(l)
+	}
+
 	//Now instead of starting the handler directly we will
 	//submit job to the specific task
 	go func() {
 		for msg := range s.channel {
-			job := Job{
-				ID: s.generateJobID(),
-				Task: &ProcessMessageTask{
-					msg:     msg,
-					handler: s.handler,
-				},
+			//Submit waits for queue space instead of dropping the message
+			_, err := s.WorkerPool.Submit(context.Background(), &ProcessMessageTask{
+				msg:     msg,
+				handler: s.process,
+				done:    s.pending.Done,
+			})
+			if err != nil {
+				fmt.Printf("[%s] Dropping message at offset %d: %v\n", s.id, msg.Offset, err)
+				s.pending.Done()
 			}
-			s.WorkerPool.SubmitJob(job)
 		}
+
+		//channel closed (Close() called) => nothing more to deliver,
+		//publishers are done with this subscriber too (Unsubscribe held
+		//the sending token) => safe to stop lanes and pool only now
+		for _, l := range s.lanes {
+			close(l)
+		}
+		s.WorkerPool.Shutdown(context.Background())
 	}()
 }
 
+// Wait - blocks until every delivered message (keyed or not) is processed
+func (s *Subscriber) Wait() {
+	s.pending.Wait()
+}
+
 func (s *Subscriber) Close() {
 	//stop the channel so nothing gets read from this
+	//(dispatcher goroutine then shuts down lanes and worker pool)
 	close(s.channel)
-	s.WorkerPool.Shutdown()
 }
 
 func main() {
@@ -304,10 +524,225 @@ func main() {
 
 	// Wait for all workers to finish
 	fmt.Println("\nWaiting for workers to process...")
-	sub1.WorkerPool.Wait()
+	sub1.Wait()
 
 	totalTime := time.Since(start)
 	fmt.Printf("\n✅ Total time: %v\n", totalTime)
 	fmt.Printf("Expected: ~4 seconds (10 messages / 5 workers * 2 sec)\n")
 	fmt.Printf("Without worker pool: ~20 seconds (10 messages * 2 sec)\n")
+
+	testOrderedPerKey()
+	testSlowKeyAndPanic()
+	testRequestReply()
+	testTracing()
+}
+
+// testOrderedPerKey - order status events keyed by orderID
+// Same order => processed serially in offset order
+// Different orders => processed in parallel
+func testOrderedPerKey() {
+	fmt.Println("\n--- Ordered per-key processing ---")
+	pubsub := NewPubSub()
+	pubsub.CreateTopic("order-status")
+
+	var mu sync.Mutex
+	seen := make(map[string][]string) //orderID -> statuses in processing order
+
+	sub := NewSubscriber("order-tracker", func(msg Message) {
+		time.Sleep(100 * time.Millisecond) // simulate work
+		mu.Lock()
+		seen[msg.Key] = append(seen[msg.Key], msg.Data.(string))
+		mu.Unlock()
+		fmt.Printf("[Tracker] %s -> %v (offset %d)\n", msg.Key, msg.Data, msg.Offset)
+	})
+	pubsub.Subscribe("order-status", sub)
+
+	statuses := []string{"PLACED", "CONFIRMED", "SHIPPED", "DELIVERED"}
+	start := time.Now()
+	for _, status := range statuses {
+		for _, orderID := range []string{"order-1", "order-2", "order-3"} {
+			pubsub.PublishWithKey("order-status", orderID, status)
+		}
+	}
+	sub.Wait()
+	sub.Close()
+
+	for orderID, got := range seen {
+		inOrder := len(got) == len(statuses)
+		for i := range got {
+			if inOrder && got[i] != statuses[i] {
+				inOrder = false
+			}
+		}
+		fmt.Printf("%s: %v in order=%v\n", orderID, got, inOrder)
+	}
+	fmt.Printf("Total time: %v (expected ~400ms: 4 statuses * 100ms, orders in parallel)\n", time.Since(start))
+}
+
+// testSlowKeyAndPanic - one key is slow, one key's handler panics:
+// the other keys are still processed right away and the process survives
+func testSlowKeyAndPanic() {
+	fmt.Println("\n--- Slow key + panicking handler ---")
+	pubsub := NewPubSub()
+	pubsub.CreateTopic("order-status")
+
+	start := time.Now()
+	var mu sync.Mutex
+	fast := 0
+	sub := NewSubscriber("order-tracker", func(msg Message) {
+		switch msg.Key {
+		case "order-slow":
+			time.Sleep(300 * time.Millisecond)
+		case "order-bad":
+			panic("nil shipment")
+		default:
+			mu.Lock()
+			fast++
+			mu.Unlock()
+		}
+	})
+	pubsub.Subscribe("order-status", sub)
+
+	// 20 events for the slow order: more than a lane holds => once it is
+	// full the publisher is held back until its ctx ends
+	queued, refused := 0, 0
+	for i := 0; i < 20; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		if err := pubsub.PublishContext(ctx, "order-status", "order-slow", "UPDATED"); err != nil {
+			refused++
+		} else {
+			queued++
+		}
+		cancel()
+	}
+	fmt.Printf("order-slow: %d queued, %d refused (lane holds %d)\n", queued, refused, laneBuffer)
+	pubsub.PublishWithKey("order-status", "order-bad", "PLACED")
+	// keys hashed onto order-slow's lane wait behind it (that's the
+	// per-key order, their publish waits for room too), every other key
+	// must not
+	notBehindSlow := 0
+	for i := 1; i <= 5; i++ {
+		key := fmt.Sprintf("order-%d", i)
+		if sub.laneFor(key) != sub.laneFor("order-slow") {
+			notBehindSlow++
+		}
+		pubsub.PublishWithKey("order-status", key, "PLACED")
+	}
+	publishTime := time.Since(start)
+
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	fmt.Printf("Published in %v, keys on other lanes done within 100ms: %d of %d\n", publishTime.Round(time.Millisecond), fast, notBehindSlow)
+	mu.Unlock()
+	sub.Wait()
+	sub.Close()
+}
+
+// testRequestReply - synchronous query over the same bus
+func testRequestReply() {
+	fmt.Println("\n--- Request/Reply ---")
+	pubsub := NewPubSub()
+	pubsub.CreateTopic("inventory.check")
+	pubsub.CreateTopic("pricing.quote")
+
+	stock := map[string]int{"laptop": 5, "phone": 0}
+	inventory := NewSubscriber("inventory-service", func(msg Message) {
+		item := msg.Data.(string)
+		if err := msg.Reply(stock[item]); err != nil {
+			fmt.Printf("[Inventory] Reply failed: %v\n", err)
+		}
+	})
+	pubsub.Subscribe("inventory.check", inventory)
+
+	// Slow responder => caller should time out
+	pricing := NewSubscriber("pricing-service", func(msg Message) {
+		time.Sleep(500 * time.Millisecond)
+		if err := msg.Reply(999.0); err != nil {
+			fmt.Printf("[Pricing] Late reply: %v\n", err)
+		}
+	})
+	pubsub.Subscribe("pricing.quote", pricing)
+
+	for _, item := range []string{"laptop", "phone"} {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		reply, err := pubsub.Request(ctx, "inventory.check", item)
+		cancel()
+		fmt.Printf("Stock of %s: %v (err=%v)\n", item, reply, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := pubsub.Request(ctx, "pricing.quote", "laptop")
+	fmt.Printf("Price quote: err=%v\n", err)
+
+	pricing.Wait()
+
+	// Backed-up subscriber: its channel and pool queue are full, so the
+	// request can't even be handed over => still bounded by ctx
+	release := make(chan struct{})
+	audit := NewSubscriber("audit-service", func(msg Message) {
+		<-release
+	})
+	pubsub.CreateTopic("audit.check")
+	pubsub.Subscribe("audit.check", audit)
+	for i := 0; i < 100; i++ {
+		go pubsub.Publish("audit.check", i) // stuck behind the full subscriber
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = pubsub.Request(ctx, "audit.check", "login")
+	fmt.Printf("Request to a backed-up subscriber: err=%v (after %v)\n", err, time.Since(start).Round(50*time.Millisecond))
+
+	// Unsubscribe has to wait for the stuck publishers too => bounded by ctx
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = pubsub.Unsubscribe(ctx, "audit.check", "audit-service")
+	fmt.Printf("Unsubscribe while publishers are stuck: err=%v\n", err)
+
+	close(release)
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = pubsub.Unsubscribe(ctx, "audit.check", "audit-service")
+	fmt.Printf("Unsubscribe once they drained: err=%v\n", err)
+}
+
+// testTracing - one order's trace: the publish span, then a process span
+// per subscriber, whether the message ran on a keyed lane or on the
+// worker pool, and the handler's own span under it
+func testTracing() {
+	fmt.Println("\n--- Tracing ---")
+	recorder := tracing.NewRecorder()
+	tracer := tracing.NewTracer("order-service", recorder)
+
+	pubsub := NewPubSub()
+	pubsub.SetTracer(tracer)
+	pubsub.CreateTopic("orders")
+
+	var subscribers []*Subscriber
+	for _, service := range []string{"email-service", "shipping-service"} {
+		service := service
+		sub := NewSubscriberContext(service, func(ctx context.Context, msg Message) {
+			_, span := tracer.Start(ctx, service+".handle")
+			time.Sleep(5 * time.Millisecond) // send mail / book courier
+			span.End()
+		})
+		pubsub.Subscribe("orders", sub)
+		subscribers = append(subscribers, sub)
+	}
+
+	ctx, span := tracer.Start(context.Background(), "OrderService.PlaceOrder")
+	pubsub.PublishContext(ctx, "orders", "order-2001", "PLACED")  // keyed => lanes
+	pubsub.PublishContext(ctx, "orders", "", "NEWSLETTER_OPT_IN") // no key => worker pool
+	span.End()
+
+	for _, sub := range subscribers {
+		sub.Wait()
+		sub.Close()
+	}
+	time.Sleep(10 * time.Millisecond) // process spans end right after the handler returns
+	tracer.Flush()
+
+	fmt.Printf("trace %s:\n", span.SpanContext().TraceID)
+	fmt.Print(tracing.FormatTree(recorder.Spans(), span.SpanContext().TraceID))
 }
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"yourname/brokers_just_to_understand_high_level/kafka/kafkaclient"
+)
+
+// ============================================
+// KAFKA TRANSPORT
+// Backed by kafkaclient Producer/Consumer (brokers_just_to_understand_high_level/kafka/layer_2.go)
+// talking to kafka.Broker (layer_1.go) over TCP
+//
+// Differences from in-memory worth knowing:
+//   - PULL model: we run a poll loop per partition and push into ch
+//     so Subscriber code sees exactly the same channel as in memory
+//   - Data is JSON encoded on the wire: PubSub already made it JSON-shaped,
+//     so the other side sees the same value the in-memory transport delivers
+//   - Metadata travels as Kafka record headers, next to the value
+//   - Topics are created by the broker (layer_1 creates "orders" on start),
+//     there is no CREATE request => CreateTopic returns ErrTopicOnBroker
+// ============================================
+
+type KafkaTransport struct {
+	brokerAddr string
+	partitions int // partitions per topic on the broker
+	producer   *kafkaclient.Producer
+	pollEvery  time.Duration
+
+	pollers map[string]*kafkaSubscription //[topic/subscriberID -> poll loops]
+	mu      sync.Mutex
+}
+
+// kafkaSubscription - one consumer per partition, all feeding the same ch
+type kafkaSubscription struct {
+	topicName    string
+	subscriberID string
+	consumers    []*kafkaclient.Consumer
+	channel      chan Message
+	stop         chan struct{}
+	wg           sync.WaitGroup
+}
+
+func NewKafkaTransport(brokerAddr string, partitions int) (*KafkaTransport, error) {
+	producer, err := kafkaclient.NewProducer(brokerAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KafkaTransport{
+		brokerAddr: brokerAddr,
+		partitions: partitions,
+		producer:   producer,
+		pollEvery:  200 * time.Millisecond,
+		pollers:    make(map[string]*kafkaSubscription),
+	}, nil
+}
+
+func (t *KafkaTransport) CreateTopic(topicName string) error {
+	// Broker owns topic creation (see layer_1 Start)
+	return fmt.Errorf("%w: '%s'", ErrTopicOnBroker, topicName)
+}
+
+func (t *KafkaTransport) Publish(msg Message) error {
+	value, err := json.Marshal(msg.Data)
+	if err != nil {
+		return fmt.Errorf("encode message for topic '%s': %w", msg.Topic, err)
+	}
+	return t.producer.ProduceWithHeaders(msg.Topic, msg.Key, string(value), msg.Metadata)
+}
+
+// Subscribe - groupID = subscriberID
+// => every subscriber is its own consumer group => every subscriber gets
+// every message (pub/sub fan-out, same as in memory)
+func (t *KafkaTransport) Subscribe(topicName string, subscriberID string, ch chan Message) error {
+	subKey := topicName + "/" + subscriberID
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.pollers[subKey]; ok {
+		return errors.New("Subscriber already subscribed")
+	}
+
+	sub := &kafkaSubscription{
+		topicName:    topicName,
+		subscriberID: subscriberID,
+		channel:      ch,
+		stop:         make(chan struct{}),
+	}
+
+	for p := 0; p < t.partitions; p++ {
+		consumerID := fmt.Sprintf("%s-p%d", subscriberID, p)
+		consumer, err := kafkaclient.NewConsumer(t.brokerAddr, subscriberID, consumerID)
+		if err != nil {
+			sub.closeConsumers()
+			return err
+		}
+		if err := consumer.Subscribe(topicName, p); err != nil {
+			sub.closeConsumers()
+			return err
+		}
+		sub.consumers = append(sub.consumers, consumer)
+	}
+
+	for _, consumer := range sub.consumers {
+		sub.wg.Add(1)
+		go t.poll(topicName, consumer, sub)
+	}
+
+	t.pollers[subKey] = sub
+	return nil
+}
+
+// poll - PULL from broker, PUSH into subscriber channel
+func (t *KafkaTransport) poll(topicName string, consumer *kafkaclient.Consumer, sub *kafkaSubscription) {
+	defer sub.wg.Done()
+
+	for {
+		select {
+		case <-sub.stop:
+			return
+		default:
+		}
+
+		kmsg, err := consumer.Poll(int(t.pollEvery / time.Millisecond))
+		if err != nil {
+			fmt.Printf("[KafkaTransport] Poll error on '%s': %v\n", topicName, err)
+			time.Sleep(t.pollEvery)
+			continue
+		}
+		if kmsg == nil {
+			time.Sleep(t.pollEvery)
+			continue
+		}
+
+		var data interface{}
+		if err := json.Unmarshal([]byte(kmsg.Value), &data); err != nil {
+			// Not produced through PubSub - hand over raw value
+			data = kmsg.Value
+		}
+
+		select {
+		case sub.channel <- Message{Topic: topicName, Key: kmsg.Key, Data: data, Metadata: kmsg.Headers}:
+			consumer.Commit()
+		case <-sub.stop:
+			return
+		}
+	}
+}
+
+func (t *KafkaTransport) Unsubscribe(topicName string, subscriberID string) error {
+	subKey := topicName + "/" + subscriberID
+
+	t.mu.Lock()
+	sub, ok := t.pollers[subKey]
+	delete(t.pollers, subKey)
+	t.mu.Unlock()
+
+	if !ok {
+		return errors.New("Subscriber not found")
+	}
+
+	// Stop poll loops BEFORE closing channel, otherwise a poller
+	// could send on a closed channel
+	close(sub.stop)
+	sub.wg.Wait()
+	sub.closeConsumers()
+	close(sub.channel)
+	return nil
+}
+
+func (s *kafkaSubscription) closeConsumers() {
+	for _, consumer := range s.consumers {
+		consumer.Close()
+	}
+}
+
+func (t *KafkaTransport) Close() error {
+	t.mu.Lock()
+	subs := make([]*kafkaSubscription, 0, len(t.pollers))
+	for _, sub := range t.pollers {
+		subs = append(subs, sub)
+	}
+	t.mu.Unlock()
+
+	for _, sub := range subs {
+		t.Unsubscribe(sub.topicName, sub.subscriberID)
+	}
+	return t.producer.Close()
+}
@@ -0,0 +1,427 @@
+package main
+
+// SCHEMA REGISTRY (like Confluent Schema Registry, much smaller)
+//
+// Problem: Message.Data is interface{}
+//   Publisher sends   {"orderId": "1001", "amount": 999}
+//   Subscriber expects {"order_id": 1001, "total": 999}
+//   => nobody notices until production
+//
+// Fix:
+//   topic -> [v1, v2, v3 ...] JSON Schemas
+//   Publish validates payload against LATEST version of topic's schema
+//   Register(v+1) is rejected if it breaks compatibility with v
+//
+// Compatibility (reader = schema used to read, writer = schema data was written with):
+//   BACKWARD: new schema can read OLD data    (upgrade consumers first)
+//   FORWARD:  old schema can read NEW data    (upgrade producers first)
+//   FULL:     both
+//
+// Supported JSON Schema subset:
+//   type, properties, required, items, enum, additionalProperties
+//
+// Scope: only this PubSub (simple_pubsub) validates. pubsub_with_offset_to_replay_messages
+// and message_broker_with_worker_pool are standalone programs and still pass
+// interface{} through unchecked.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrSchemaNotFound     = errors.New("no schema registered for topic")
+	ErrSchemaValidation   = errors.New("payload does not match schema")
+	ErrIncompatibleSchema = errors.New("schema is incompatible with previous version")
+	ErrTopicTypeMismatch  = errors.New("topic is already bound to another Go type")
+)
+
+type CompatibilityMode string
+
+const (
+	CompatNone     CompatibilityMode = "NONE"
+	CompatBackward CompatibilityMode = "BACKWARD"
+	CompatForward  CompatibilityMode = "FORWARD"
+	CompatFull     CompatibilityMode = "FULL"
+)
+
+// Schema - parsed JSON Schema (subset)
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+}
+
+type SchemaVersion struct {
+	Version int
+	Schema  *Schema
+	Raw     string
+}
+
+// subject - all versions of one topic's schema
+type subject struct {
+	compatibility CompatibilityMode
+	versions      []SchemaVersion
+}
+
+type SchemaRegistry struct {
+	subjects    map[string]*subject //[topic_name -> versions]
+	defaultMode CompatibilityMode
+	mu          sync.RWMutex
+}
+
+func NewSchemaRegistry(defaultMode CompatibilityMode) *SchemaRegistry {
+	return &SchemaRegistry{
+		subjects:    make(map[string]*subject),
+		defaultMode: defaultMode,
+	}
+}
+
+// SetCompatibility - override default mode for one topic
+func (r *SchemaRegistry) SetCompatibility(topicName string, mode CompatibilityMode) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sub, ok := r.subjects[topicName]
+	if !ok {
+		sub = &subject{}
+		r.subjects[topicName] = sub
+	}
+	sub.compatibility = mode
+}
+
+// Register - adds a new version of topic's schema
+// Returns the new version number (1, 2, 3 ...)
+func (r *SchemaRegistry) Register(topicName string, rawSchema string) (int, error) {
+	var schema Schema
+	if err := json.Unmarshal([]byte(rawSchema), &schema); err != nil {
+		return 0, fmt.Errorf("parse schema for topic '%s': %w", topicName, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sub, ok := r.subjects[topicName]
+	if !ok {
+		sub = &subject{}
+		r.subjects[topicName] = sub
+	}
+
+	mode := sub.compatibility
+	if mode == "" {
+		mode = r.defaultMode
+	}
+
+	if len(sub.versions) > 0 {
+		latest := sub.versions[len(sub.versions)-1].Schema
+		if issues := checkCompatibility(mode, latest, &schema); len(issues) > 0 {
+			return 0, fmt.Errorf("%w (topic '%s', mode %s): %s",
+				ErrIncompatibleSchema, topicName, mode, strings.Join(issues, "; "))
+		}
+	}
+
+	version := len(sub.versions) + 1
+	sub.versions = append(sub.versions, SchemaVersion{
+		Version: version,
+		Schema:  &schema,
+		Raw:     rawSchema,
+	})
+	return version, nil
+}
+
+func (r *SchemaRegistry) Latest(topicName string) (SchemaVersion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sub, ok := r.subjects[topicName]
+	if !ok || len(sub.versions) == 0 {
+		return SchemaVersion{}, fmt.Errorf("%w: '%s'", ErrSchemaNotFound, topicName)
+	}
+	return sub.versions[len(sub.versions)-1], nil
+}
+
+// Validate - checks data against latest schema of topic
+// Data is round-tripped through JSON first, so structs are validated
+// exactly as they would look on the wire (json tags respected)
+func (r *SchemaRegistry) Validate(topicName string, data interface{}) error {
+	latest, err := r.Latest(topicName)
+	if err != nil {
+		return err
+	}
+
+	value, err := wireValue(data)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSchemaValidation, err)
+	}
+
+	if issues := validateValue(latest.Schema, value, "$"); len(issues) > 0 {
+		return fmt.Errorf("%w (topic '%s', v%d): %s",
+			ErrSchemaValidation, topicName, latest.Version, strings.Join(issues, "; "))
+	}
+	return nil
+}
+
+// ============================================
+// VALIDATION
+// ============================================
+
+func validateValue(schema *Schema, value interface{}, path string) []string {
+	var issues []string
+
+	if schema.Type != "" && !matchesType(schema.Type, value) {
+		return []string{fmt.Sprintf("%s: expected %s, got %s", path, schema.Type, jsonType(value))}
+	}
+
+	if len(schema.Enum) > 0 && !containsValue(schema.Enum, value) {
+		issues = append(issues, fmt.Sprintf("%s: %v is not one of %v", path, value, schema.Enum))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, field := range schema.Required {
+			if _, ok := v[field]; !ok {
+				issues = append(issues, fmt.Sprintf("%s: missing required field '%s'", path, field))
+			}
+		}
+		for _, field := range sortedKeys(v) {
+			fieldSchema, ok := schema.Properties[field]
+			if !ok {
+				if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+					issues = append(issues, fmt.Sprintf("%s: unknown field '%s'", path, field))
+				}
+				continue
+			}
+			issues = append(issues, validateValue(fieldSchema, v[field], path+"."+field)...)
+		}
+	case []interface{}:
+		if schema.Items != nil {
+			for i, item := range v {
+				issues = append(issues, validateValue(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	return issues
+}
+
+func matchesType(schemaType string, value interface{}) bool {
+	actual := jsonType(value)
+	if schemaType == "number" && actual == "integer" {
+		return true // every integer is a number
+	}
+	return schemaType == actual
+}
+
+// jsonType - JSON Schema type name of a decoded JSON value
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		// no int64 round trip: 1e19 overflows it and would read as "number"
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		// DeepEqual, not ==: enum values may be arrays / objects, and ==
+		// on interfaces holding slices or maps panics
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ============================================
+// COMPATIBILITY
+// ============================================
+
+func checkCompatibility(mode CompatibilityMode, oldSchema, newSchema *Schema) []string {
+	var issues []string
+	switch mode {
+	case CompatBackward:
+		issues = canRead(newSchema, oldSchema, "$")
+	case CompatForward:
+		issues = canRead(oldSchema, newSchema, "$")
+	case CompatFull:
+		issues = append(canRead(newSchema, oldSchema, "$"), canRead(oldSchema, newSchema, "$")...)
+	}
+	return issues
+}
+
+// canRead - can data written with `writer` always be read by `reader`?
+func canRead(reader, writer *Schema, path string) []string {
+	var issues []string
+
+	if reader.Type != "" && writer.Type != "" && reader.Type != writer.Type &&
+		!(reader.Type == "number" && writer.Type == "integer") {
+		return []string{fmt.Sprintf("%s: type changed %s -> %s", path, writer.Type, reader.Type)}
+	}
+
+	// Reader needs a field the writer may not send
+	for _, field := range reader.Required {
+		if !contains(writer.Required, field) {
+			issues = append(issues, fmt.Sprintf("%s: field '%s' is required by reader but not guaranteed by writer", path, field))
+		}
+	}
+
+	for _, field := range sortedKeys(writer.Properties) {
+		readerField, ok := reader.Properties[field]
+		if !ok {
+			if reader.AdditionalProperties != nil && !*reader.AdditionalProperties {
+				issues = append(issues, fmt.Sprintf("%s: field '%s' is sent by writer but rejected by reader", path, field))
+			}
+			continue
+		}
+		issues = append(issues, canRead(readerField, writer.Properties[field], path+"."+field)...)
+	}
+
+	if reader.Items != nil && writer.Items != nil {
+		issues = append(issues, canRead(reader.Items, writer.Items, path+"[]")...)
+	}
+
+	// Reader accepts only some values - writer must not send others
+	if len(reader.Enum) > 0 {
+		if len(writer.Enum) == 0 {
+			issues = append(issues, fmt.Sprintf("%s: reader restricts values to %v but writer does not", path, reader.Enum))
+		}
+		for _, value := range writer.Enum {
+			if !containsValue(reader.Enum, value) {
+				issues = append(issues, fmt.Sprintf("%s: value %v removed", path, value))
+			}
+		}
+	}
+	return issues
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ============================================
+// TYPED TOPICS
+// TypedTopic[T] binds a topic to ONE Go type: its Publish only compiles with
+// a T, its handlers get a T, and a second NewTypedTopic with another type for
+// the same topic name is rejected => publisher and subscriber can't drift
+// apart in Go; the registry still checks the JSON shape at runtime
+// ============================================
+
+type TypedTopic[T any] struct {
+	ps   *PubSub
+	name string
+}
+
+// NewTypedTopic - creates the topic (if needed) and binds it to T
+// Binding a topic that already exists is fine, binding it to a second type
+// is not; a failed create leaves no binding behind
+func NewTypedTopic[T any](ps *PubSub, topicName string) (*TypedTopic[T], error) {
+	goType := reflect.TypeOf((*T)(nil)).Elem()
+
+	ps.mu.Lock()
+	if ps.topicTypes == nil {
+		ps.topicTypes = make(map[string]reflect.Type)
+	}
+	bound, wasBound := ps.topicTypes[topicName]
+	if wasBound && bound != goType {
+		ps.mu.Unlock()
+		return nil, fmt.Errorf("%w: '%s' carries %v, not %v", ErrTopicTypeMismatch, topicName, bound, goType)
+	}
+	ps.topicTypes[topicName] = goType
+	ps.mu.Unlock()
+
+	// Kafka: the topic lives on the broker, binding it is all we can do
+	err := ps.CreateTopic(topicName)
+	if err != nil && !errors.Is(err, ErrTopicOnBroker) && !errors.Is(err, ErrTopicExists) {
+		if !wasBound {
+			ps.mu.Lock()
+			delete(ps.topicTypes, topicName)
+			ps.mu.Unlock()
+		}
+		return nil, err
+	}
+	return &TypedTopic[T]{ps: ps, name: topicName}, nil
+}
+
+func (t *TypedTopic[T]) Name() string {
+	return t.name
+}
+
+func (t *TypedTopic[T]) Publish(data T) error {
+	return t.ps.Publish(t.name, data)
+}
+
+func (t *TypedTopic[T]) PublishWithKey(key string, data T) error {
+	return t.ps.PublishWithKey(t.name, key, data)
+}
+
+// Subscribe - handler receives T, decoding is done here
+// Data arrives as generic JSON (map[string]interface{}) on every transport
+// and is decoded into T
+func (t *TypedTopic[T]) Subscribe(subscriberID string, handler func(T)) (*Subscriber, error) {
+	topicName := t.name
+	subscriber := NewSubscriber(subscriberID, func(msg Message) {
+		data, err := decodeAs[T](msg.Data)
+		if err != nil {
+			fmt.Printf("[Subscriber %s] Skipping message on '%s': %v\n", subscriberID, topicName, err)
+			return
+		}
+		handler(data)
+	}, t.ps)
+
+	if err := subscriber.SubscribeTo(topicName); err != nil {
+		return nil, err
+	}
+	return subscriber, nil
+}
+
+func decodeAs[T any](data interface{}) (T, error) {
+	if typed, ok := data.(T); ok {
+		return typed, nil
+	}
+
+	var out T
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return out, err
+	}
+	if err := json.Unmarshal(encoded, &out); err != nil {
+		return out, fmt.Errorf("decode %T: %w", out, err)
+	}
+	return out, nil
+}
@@ -37,11 +37,49 @@ package main
 // - Neither "owns" it exclusively
 // - It's the communication medium
 
+// PLUGGABLE TRANSPORT: the channel is one Transport, the kafka broker is another
+//
+// SINGLE PROCESS (unit tests):
+//
+// Publisher ──► PubSub ──► InMemoryTransport (channels) ──► Subscriber
+//
+// ACROSS PROCESSES (production, kafka_transport.go):
+//
+// Publisher ──► PubSub ──► KafkaTransport ──TCP──► kafka.Broker (layer_1)
+//                                                       │
+// Subscriber ◄── PubSub ◄── KafkaTransport ◄──TCP───────┘
+//                           (kafkaclient Producer/Consumer from layer_2)
+//
+// Application only talks to PubSub.Publish and Subscriber.SubscribeTo
+// Transport decides HOW the message travels (memory vs network)
+// Same idea as PaymentGateway interface in payment_system: swap implementation,
+// caller doesn't change
+//
+// Data is JSON on EVERY transport: PubSub round-trips it before handing it
+// over, so a struct arrives as map[string]interface{} in memory exactly as
+// it does over Kafka (TypedTopic[T] in schema_registry.go gives you T back)
+// => a handler that works in unit tests works in production
+//
+// TRACING: PublishContext puts the caller's trace into Message.Metadata
+// (W3C traceparent), every transport carries Metadata (Kafka as record
+// headers) and Subscriber starts its span from it => one trace from the
+// HTTP request, through the broker, into every consumer (see testTracing)
+//
+// Run: go run .          (in memory)
+//      go run . kafka    (needs kafka layer_1 broker on :9092)
+
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"reflect"
+	"strings"
 	"sync"
 	"time"
+
+	"yourname/observability/tracing"
 )
 
 // ============================================
@@ -50,12 +88,58 @@ import (
 // ============================================
 
 type Message struct {
-	Topic string
-	Data  interface{}
+	Topic    string
+	Key      string // used by Kafka for partitioning, ignored in memory
+	Data     interface{}
+	Metadata map[string]string // traceparent etc., never part of Data / schema
+}
+
+// ============================================
+// TRANSPORT - the pluggable part
+// ============================================
+
+var (
+	ErrSubscriberFull = errors.New("subscriber channel full, message not delivered")
+	ErrTopicOnBroker  = errors.New("topics are created on the broker, not by clients")
+	ErrTopicExists    = errors.New("Topic already exists")
+)
+
+// DeliveryError - Publish reached some subscribers but not all
+// Retry only Missed (PublishTo), re-publishing to the whole topic would
+// hand the message to Delivered a second time
+type DeliveryError struct {
+	Topic     string
+	Delivered []string // subscriber IDs that got the message
+	Missed    []string // subscriber IDs whose channel was full
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("%v: topic '%s' missed [%s], delivered [%s]",
+		ErrSubscriberFull, e.Topic, strings.Join(e.Missed, ", "), strings.Join(e.Delivered, ", "))
+}
+
+func (e *DeliveryError) Unwrap() error {
+	return ErrSubscriberFull
+}
+
+// Transport - how messages move between publishers and subscribers
+// PubSub only depends on this interface, never on channels or TCP directly
+type Transport interface {
+	// CreateTopic - ErrTopicOnBroker if the transport can't create topics,
+	// the topic then has to exist on the broker already
+	CreateTopic(topicName string) error
+	// Publish - nil only if the message was handed to every subscriber
+	// (or stored by the broker), never dropped silently
+	Publish(msg Message) error
+	// Subscribe - transport must deliver every message of topic to ch
+	Subscribe(topicName string, subscriberID string, ch chan Message) error
+	// Unsubscribe - transport must stop delivering and close ch
+	Unsubscribe(topicName string, subscriberID string) error
+	Close() error
 }
 
 // ============================================
-// BROKER SIDE STRUCTURES
+// BROKER SIDE STRUCTURES (IN-MEMORY TRANSPORT)
 // These represent the broker's view of the world
 // ============================================
 
@@ -75,9 +159,10 @@ type Subscription struct {
 	// In actual distributed implementation (RabbitMQ/Kafka):
 	// This channel would be replaced with a TCP connection
 	// Broker would write messages over network to subscriber
+	// (KafkaTransport does exactly that)
 }
 
-// PubSub - THE MESSAGE BROKER
+// InMemoryTransport - THE MESSAGE BROKER (single process)
 // This is the centralized message routing system
 // Responsibilities:
 //   - Manage topics
@@ -87,13 +172,13 @@ type Subscription struct {
 //   - Starting subscriber goroutines
 //   - Executing subscriber handlers
 //   - Managing subscriber lifecycle
-type PubSub struct {
+type InMemoryTransport struct {
 	topics map[string]*Topic // All topics managed by this broker
 	mu     sync.RWMutex
 }
 
-func NewPubSub() *PubSub {
-	return &PubSub{
+func NewInMemoryTransport() *InMemoryTransport {
+	return &InMemoryTransport{
 		topics: make(map[string]*Topic),
 	}
 }
@@ -105,23 +190,34 @@ func NewPubSub() *PubSub {
 
 // CreateTopic - BROKER method
 // Creates a new topic for message publishing
-func (ps *PubSub) CreateTopic(topicName string) error {
-	ps.mu.Lock()
-	defer ps.mu.Unlock()
+func (t *InMemoryTransport) CreateTopic(topicName string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-	_, ok := ps.topics[topicName]
+	_, ok := t.topics[topicName]
 	if ok {
-		return errors.New("Topic already exists")
+		return ErrTopicExists
 	}
 
 	topic := &Topic{
 		name:          topicName,
 		subscriptions: []*Subscription{},
 	}
-	ps.topics[topicName] = topic
+	t.topics[topicName] = topic
 	return nil
 }
 
+func (t *InMemoryTransport) getTopic(topicName string) (*Topic, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	topic, ok := t.topics[topicName]
+	if !ok {
+		return nil, errors.New("Topic does not exist")
+	}
+	return topic, nil
+}
+
 // Subscribe - BROKER method
 // Registers a subscriber to receive messages from a topic
 // Parameters:
@@ -138,13 +234,10 @@ func (ps *PubSub) CreateTopic(topicName string) error {
 // In distributed systems (RabbitMQ/Kafka):
 //   - Instead of channel, this would be a TCP connection
 //   - Broker would PUSH messages over network
-func (ps *PubSub) Subscribe(topicName string, subscriberID string, ch chan Message) error {
-	ps.mu.RLock()
-	topic, ok := ps.topics[topicName]
-	ps.mu.RUnlock()
-
-	if !ok {
-		return errors.New("Topic does not exist")
+func (t *InMemoryTransport) Subscribe(topicName string, subscriberID string, ch chan Message) error {
+	topic, err := t.getTopic(topicName)
+	if err != nil {
+		return err
 	}
 
 	// Create broker's representation of subscriber
@@ -165,13 +258,10 @@ func (ps *PubSub) Subscribe(topicName string, subscriberID string, ch chan Messa
 
 // Unsubscribe - BROKER method
 // Removes a subscriber from a topic
-func (ps *PubSub) Unsubscribe(topicName string, subscriberID string) error {
-	ps.mu.RLock()
-	topic, ok := ps.topics[topicName]
-	ps.mu.RUnlock()
-
-	if !ok {
-		return errors.New("Topic does not exist")
+func (t *InMemoryTransport) Unsubscribe(topicName string, subscriberID string) error {
+	topic, err := t.getTopic(topicName)
+	if err != nil {
+		return err
 	}
 
 	topic.mu.Lock()
@@ -215,42 +305,192 @@ func (ps *PubSub) Unsubscribe(topicName string, subscriberID string) error {
 //   - Broker actively SENDS messages to subscribers
 //   - Subscribers passively RECEIVE from their channels
 //   - Compare to PULL (Kafka): subscribers actively request messages
-func (ps *PubSub) Publish(topicName string, data interface{}) error {
-	ps.mu.RLock()
-	topic, ok := ps.topics[topicName]
-	ps.mu.RUnlock()
+func (t *InMemoryTransport) Publish(msg Message) error {
+	return t.publish(msg, nil)
+}
 
-	if !ok {
-		return errors.New("Topic does not exist")
+// PublishTo - Publish to some subscribers of the topic only
+// (the Missed of a DeliveryError => a retry never duplicates)
+func (t *InMemoryTransport) PublishTo(msg Message, subscriberIDs []string) error {
+	only := make(map[string]bool, len(subscriberIDs))
+	for _, id := range subscriberIDs {
+		only[id] = true
 	}
+	return t.publish(msg, only)
+}
 
-	// Create message
-	msg := Message{
-		Topic: topicName,
-		Data:  data,
+// publish - only == nil => every subscriber
+func (t *InMemoryTransport) publish(msg Message, only map[string]bool) error {
+	topic, err := t.getTopic(msg.Topic)
+	if err != nil {
+		return err
 	}
 
 	topic.mu.RLock()
-	subscriptions := topic.subscriptions
-	topic.mu.RUnlock()
+	defer topic.mu.RUnlock()
 
-	fmt.Printf("[Broker] Publishing to topic '%s'\n", topicName)
+	fmt.Printf("[Broker] Publishing to topic '%s'\n", msg.Topic)
 
 	// PUSH to all subscriber channels
 	// This is the PUSH mechanism!
-	for _, sub := range subscriptions {
+	// No blocking here: a slow subscriber must not stall the others (or
+	// deadlock against Unsubscribe), so a full channel is the caller's error
+	var delivered, missed []string
+	for _, sub := range topic.subscriptions {
+		if only != nil && !only[sub.subscriberID] {
+			continue
+		}
 		select {
 		case sub.channel <- msg: // ← BROKER PUSHES message
-			// Sent successfully
+			delivered = append(delivered, sub.subscriberID)
 		default:
 			// Channel full - subscriber is slow or blocked
-			fmt.Printf("[Broker Warning] Subscriber '%s' channel full, dropping message\n", sub.subscriberID)
+			missed = append(missed, sub.subscriberID)
 		}
 	}
+	if len(missed) > 0 {
+		return &DeliveryError{Topic: msg.Topic, Delivered: delivered, Missed: missed}
+	}
+	return nil
+}
 
+func (t *InMemoryTransport) Close() error {
 	return nil
 }
 
+// ============================================
+// PUBSUB - what the application talks to
+// Knows nothing about channels-vs-TCP, just delegates to Transport
+// ============================================
+
+type PubSub struct {
+	transport Transport
+	schemas   *SchemaRegistry // optional, nil = no payload validation
+	tracer    *tracing.Tracer // optional, nil = no publish / process spans
+
+	topicTypes map[string]reflect.Type // topic -> T of its TypedTopic[T] (schema_registry.go)
+	mu         sync.Mutex
+}
+
+// NewPubSub - in-memory by default (single process, unit tests)
+func NewPubSub() *PubSub {
+	return NewPubSubWithTransport(NewInMemoryTransport())
+}
+
+func NewPubSubWithTransport(transport Transport) *PubSub {
+	return &PubSub{
+		transport: transport,
+	}
+}
+
+// SetSchemaRegistry - validate every Publish against topic's latest schema
+// Topics without a registered schema are not validated
+func (ps *PubSub) SetSchemaRegistry(registry *SchemaRegistry) {
+	ps.schemas = registry
+}
+
+// SetTracer - "<topic> publish" span per Publish, "<topic> process" span
+// per delivered message; call before subscribing
+func (ps *PubSub) SetTracer(tracer *tracing.Tracer) {
+	ps.tracer = tracer
+}
+
+func (ps *PubSub) CreateTopic(topicName string) error {
+	return ps.transport.CreateTopic(topicName)
+}
+
+func (ps *PubSub) Publish(topicName string, data interface{}) error {
+	return ps.PublishWithKey(topicName, "", data)
+}
+
+func (ps *PubSub) PublishWithKey(topicName string, key string, data interface{}) error {
+	return ps.PublishContext(context.Background(), topicName, key, data)
+}
+
+// PublishContext - like PublishWithKey, the trace in ctx goes along in
+// Message.Metadata => consumers continue it (even without our tracer set,
+// the caller's span is propagated)
+func (ps *PubSub) PublishContext(ctx context.Context, topicName string, key string, data interface{}) error {
+	ctx, span := ps.tracer.StartSpan(ctx, topicName+" publish", tracing.SpanOptions{
+		Kind: tracing.KindProducer,
+		Attributes: []tracing.Attribute{
+			{Key: "messaging.destination.name", Value: topicName},
+			{Key: "messaging.message.key", Value: key},
+		},
+	})
+	defer span.End()
+
+	msg, err := ps.newMessage(ctx, topicName, key, data)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	err = ps.transport.Publish(msg)
+	span.RecordError(err)
+	return err
+}
+
+// PublishTo - retry a DeliveryError: only the Missed subscribers get it
+// Only the in-memory transport pushes to subscribers; a broker stores the
+// message once and consumers pull it, so there is nothing to retry per
+// subscriber there
+func (ps *PubSub) PublishTo(topicName string, key string, data interface{}, subscriberIDs []string) error {
+	memory, ok := ps.transport.(*InMemoryTransport)
+	if !ok {
+		return fmt.Errorf("PublishTo needs the in-memory transport, not %T", ps.transport)
+	}
+	msg, err := ps.newMessage(context.Background(), topicName, key, data)
+	if err != nil {
+		return err
+	}
+	return memory.PublishTo(msg, subscriberIDs)
+}
+
+// newMessage - validated, JSON-shaped Data + trace metadata
+func (ps *PubSub) newMessage(ctx context.Context, topicName string, key string, data interface{}) (Message, error) {
+	value, err := wireValue(data)
+	if err != nil {
+		return Message{}, fmt.Errorf("encode message for topic '%s': %w", topicName, err)
+	}
+
+	if ps.schemas != nil {
+		err := ps.schemas.Validate(topicName, value)
+		if err != nil && !errors.Is(err, ErrSchemaNotFound) {
+			return Message{}, err
+		}
+	}
+
+	metadata := make(map[string]string)
+	tracing.Inject(ctx, metadata)
+	return Message{Topic: topicName, Key: key, Data: value, Metadata: metadata}, nil
+}
+
+// wireValue - data as it looks after a trip over the network: structs
+// become map[string]interface{} (json tags respected), numbers float64
+func wireValue(data interface{}) (interface{}, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(encoded, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (ps *PubSub) Subscribe(topicName string, subscriberID string, ch chan Message) error {
+	return ps.transport.Subscribe(topicName, subscriberID, ch)
+}
+
+func (ps *PubSub) Unsubscribe(topicName string, subscriberID string) error {
+	return ps.transport.Unsubscribe(topicName, subscriberID)
+}
+
+func (ps *PubSub) Close() error {
+	return ps.transport.Close()
+}
+
 // ============================================
 // CLIENT/SUBSCRIBER SIDE STRUCTURES
 // These are completely separate from broker
@@ -274,6 +514,9 @@ func (ps *PubSub) Publish(topicName string, data interface{}) error {
 //   - Broker would PUSH messages over network (TCP write)
 //   - Subscriber would READ from network (TCP read)
 //   - Example: broker.conn.Write(message) → subscriber.conn.Read()
+//
+// With KafkaTransport the channel stays: the transport's poll loops read
+// from TCP and push into it => Subscriber code is the same on both
 type Subscriber struct {
 	id      string
 	channel chan Message // ← Each subscriber has its own queue
@@ -283,8 +526,8 @@ type Subscriber struct {
 	// - Broker PUSHes by writing to connection
 	// - Subscriber RECEIVEs by reading from connection
 	// - Push-based: Broker initiates sending
-	handler func(Message) // ← Subscriber's business logic
-	broker  *PubSub       // ← Reference to broker (to call broker methods)
+	handler func(context.Context, Message) // ← Subscriber's business logic
+	broker  *PubSub                        // ← Reference to broker (to call broker methods)
 }
 
 // NewSubscriber - CLIENT constructor
@@ -295,6 +538,15 @@ type Subscriber struct {
 // - Broker does NOT start this goroutine
 // - Subscriber is ready to receive messages before subscribing
 func NewSubscriber(id string, handler func(Message), broker *PubSub) *Subscriber {
+	return NewSubscriberContext(id, func(_ context.Context, msg Message) {
+		handler(msg)
+	}, broker)
+}
+
+// NewSubscriberContext - handler's ctx carries the publisher's trace
+// (and our "<topic> process" span if the broker has a tracer)
+// => work the handler starts shows up in the same trace
+func NewSubscriberContext(id string, handler func(context.Context, Message), broker *PubSub) *Subscriber {
 	s := &Subscriber{
 		id:      id,
 		channel: make(chan Message, 10), // Buffered channel (queue)
@@ -412,11 +664,37 @@ func (s *Subscriber) listen() {
 		// Execute handler - this is subscriber's business logic
 		// Broker doesn't know about this function
 		// Broker doesn't execute this function
-		s.handler(msg)
+		s.process(msg)
 	}
 	fmt.Printf("[Subscriber %s] Stopped listening (channel closed)\n", s.id)
 }
 
+// process - one message, one "<topic> process" span
+// A panicking handler loses that message only: without the recover the
+// listen goroutine dies, nobody drains the channel any more and every
+// later Publish to this subscriber fails with ErrSubscriberFull
+func (s *Subscriber) process(msg Message) {
+	ctx := tracing.Extract(context.Background(), msg.Metadata)
+	ctx, span := s.broker.tracer.StartSpan(ctx, msg.Topic+" process", tracing.SpanOptions{
+		Kind: tracing.KindConsumer,
+		Attributes: []tracing.Attribute{
+			{Key: "messaging.destination.name", Value: msg.Topic},
+			{Key: "messaging.message.key", Value: msg.Key},
+			{Key: "messaging.consumer.id", Value: s.id},
+		},
+	})
+	defer span.End()
+	defer func() {
+		if r := recover(); r != nil {
+			err := fmt.Errorf("handler panicked: %v", r)
+			span.RecordError(err)
+			fmt.Printf("[Subscriber %s] Message on '%s' dropped: %v\n", s.id, msg.Topic, err)
+		}
+	}()
+
+	s.handler(ctx, msg)
+}
+
 // ============================================
 // MAIN - USER APPLICATION
 // This shows how a developer would use the system
@@ -516,6 +794,32 @@ func main() {
 
 	fmt.Println("\n✅ Only analytics and email received (notification unsubscribed)\n")
 
+	// ============================================
+	// STEP 7: Same application code on another Transport
+	// go run . kafka -> needs kafka layer_1 broker on :9092
+	// ============================================
+
+	appBroker := broker
+	if len(os.Args) > 1 && os.Args[1] == "kafka" {
+		fmt.Println("=== PUBSUB OVER KAFKA TRANSPORT ===")
+		transport, err := NewKafkaTransport("localhost:9092", 3) // layer_1 creates "orders" with 3 partitions
+		if err != nil {
+			fmt.Printf("Cannot connect to kafka broker: %v\n", err)
+			return
+		}
+		appBroker = NewPubSubWithTransport(transport)
+	} else {
+		fmt.Println("=== PUBSUB OVER IN-MEMORY TRANSPORT ===")
+	}
+	defer appBroker.Close()
+
+	runOrderApp(appBroker)
+	time.Sleep(100 * time.Millisecond)
+
+	testDeliveryFailures()
+	testSchemaRegistry()
+	testTracing(appBroker)
+
 	fmt.Println("--- Done ---")
 }
 
@@ -524,9 +828,10 @@ func main() {
 KEY CONCEPTS SUMMARY
 ============================================
 
-1. BROKER (PubSub):
-   - Manages topics and message routing
-   - PUSHes messages to subscriber channels
+1. BROKER (PubSub + Transport):
+   - PubSub is what the application calls
+   - Transport manages topics and message routing
+   - InMemoryTransport PUSHes messages to subscriber channels
    - Does NOT execute subscriber handlers
    - Does NOT manage subscriber lifecycle
 
@@ -547,8 +852,8 @@ KEY CONCEPTS SUMMARY
    - Broker initiates message delivery
 
 5. In-Process vs Distributed:
-   - In-Process (this): Channel-based, same process
-   - Distributed (RabbitMQ): Network-based, TCP connections
+   - In-Process (InMemoryTransport): Channel-based, same process
+   - Distributed (KafkaTransport): Network-based, TCP connections
 
 6. Responsibilities:
    - Broker: Route messages
@@ -561,6 +866,10 @@ KEY CONCEPTS SUMMARY
    - Both use it (broker writes, subscriber reads)
    - It's the communication bridge
 
+8. Full channel is an ERROR, not a drop:
+   - DeliveryError says who got the message and who didn't
+   - PublishTo(Missed) retries without duplicates
+
 DIFFERENCE FROM OFFSET VERSION:
    - NO offset tracking (messages not numbered)
    - NO message storage (no replay capability)
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"yourname/observability/tracing"
+	"yourname/worker_pool/workerpool"
+)
+
+// ============================================
+// APPLICATION CODE
+// Written ONCE against PubSub/Subscriber, runs on any Transport
+// ============================================
+
+func runOrderApp(broker *PubSub) {
+	broker.CreateTopic("orders")
+
+	received := make(chan Message, 10)
+	orderService := NewSubscriber("order-service", func(msg Message) {
+		fmt.Printf("  [Order Service] %s: %v\n", msg.Key, msg.Data)
+		received <- msg
+	}, broker)
+
+	if err := orderService.SubscribeTo("orders"); err != nil {
+		fmt.Printf("Subscribe failed: %v\n", err)
+		return
+	}
+
+	orders := map[string]string{
+		"order_1001": "iPhone 15 - $999",
+		"order_1002": "MacBook Pro - $2499",
+		"order_1003": "AirPods - $249",
+	}
+	for orderID, item := range orders {
+		if err := broker.PublishWithKey("orders", orderID, item); err != nil {
+			fmt.Printf("Publish failed: %v\n", err)
+		}
+	}
+
+	for i := 0; i < len(orders); i++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			fmt.Println("Timed out waiting for orders")
+			i = len(orders)
+		}
+	}
+
+	orderService.UnsubscribeFrom("orders")
+}
+
+// testDeliveryFailures - a full subscriber channel and a panicking handler
+// fail loudly and locally: the publisher learns who missed the message,
+// the other subscribers (and later messages) are unaffected
+func testDeliveryFailures() {
+	fmt.Println("\n=== DELIVERY FAILURES ===")
+	broker := NewPubSub()
+	broker.CreateTopic("alerts")
+
+	stuck := make(chan struct{})
+	slow := NewSubscriber("slow-service", func(msg Message) {
+		<-stuck // handler hangs => channel fills up
+	}, broker)
+	received := make(chan Message, 20)
+	fast := NewSubscriber("fast-service", func(msg Message) {
+		received <- msg
+	}, broker)
+	slow.SubscribeTo("alerts")
+	fast.SubscribeTo("alerts")
+
+	var delivery *DeliveryError
+	var alert string
+	for i := 1; i <= 20 && delivery == nil; i++ {
+		alert = fmt.Sprintf("alert %d", i)
+		err := broker.Publish("alerts", alert)
+		errors.As(err, &delivery)
+		<-received // fast-service keeps up
+	}
+	if delivery == nil {
+		fmt.Println("❗ expected a DeliveryError")
+		return
+	}
+	fmt.Printf("%s: %v\n", alert, delivery)
+
+	// retry the missed ones only => fast-service doesn't get it twice
+	close(stuck)
+	time.Sleep(50 * time.Millisecond)
+	err := broker.PublishTo("alerts", "", alert, delivery.Missed)
+	fmt.Printf("PublishTo %v: err=%v, fast-service got %d extra\n", delivery.Missed, err, len(received))
+
+	// a panicking handler loses its message, not its subscription
+	broker.CreateTopic("payments")
+	handled := make(chan string, 2)
+	flaky := NewSubscriber("flaky-service", func(msg Message) {
+		if msg.Data == "boom" {
+			panic("nil pointer in handler")
+		}
+		handled <- fmt.Sprint(msg.Data)
+	}, broker)
+	flaky.SubscribeTo("payments")
+	broker.Publish("payments", "boom")
+	broker.Publish("payments", "payment 42")
+	select {
+	case data := <-handled:
+		fmt.Printf("flaky-service still listening, handled %q\n", data)
+	case <-time.After(time.Second):
+		fmt.Println("❗ flaky-service stopped after the panic")
+	}
+
+	slow.UnsubscribeFrom("alerts")
+	fast.UnsubscribeFrom("alerts")
+	flaky.UnsubscribeFrom("payments")
+}
+
+type PaymentEvent struct {
+	OrderID string  `json:"orderId"`
+	Amount  float64 `json:"amount"`
+	Status  string  `json:"status"`
+}
+
+// testSchemaRegistry - validation at Publish, compatibility on Register,
+// typed TypedTopic[T]
+func testSchemaRegistry() {
+	fmt.Println("\n=== SCHEMA REGISTRY ===")
+	registry := NewSchemaRegistry(CompatBackward)
+	broker := NewPubSub()
+	broker.SetSchemaRegistry(registry)
+	payments, err := NewTypedTopic[PaymentEvent](broker, "payments")
+	if err != nil {
+		fmt.Printf("NewTypedTopic failed: %v\n", err)
+		return
+	}
+
+	v1, err := registry.Register("payments", `{
+		"type": "object",
+		"required": ["orderId", "amount"],
+		"properties": {
+			"orderId": {"type": "string"},
+			"amount":  {"type": "number"},
+			"status":  {"type": "string", "enum": ["PENDING", "SUCCESS", "FAILED"]}
+		}
+	}`)
+	fmt.Printf("Registered v%d (err=%v)\n", v1, err)
+
+	// BACKWARD: new reader requires "currency", old events don't have it => rejected
+	_, err = registry.Register("payments", `{
+		"type": "object",
+		"required": ["orderId", "amount", "currency"],
+		"properties": {
+			"orderId":  {"type": "string"},
+			"amount":   {"type": "number"},
+			"currency": {"type": "string"}
+		}
+	}`)
+	fmt.Printf("Register v2 with new required field: %v\n", err)
+
+	// Optional new field => old events still readable => accepted
+	v2, err := registry.Register("payments", `{
+		"type": "object",
+		"required": ["orderId", "amount"],
+		"properties": {
+			"orderId":  {"type": "string"},
+			"amount":   {"type": "number"},
+			"status":   {"type": "string", "enum": ["PENDING", "SUCCESS", "FAILED", "REFUNDED"]},
+			"currency": {"type": "string"}
+		}
+	}`)
+	fmt.Printf("Registered v%d with optional field (err=%v)\n", v2, err)
+
+	received := make(chan PaymentEvent, 1)
+	subscriber, err := payments.Subscribe("ledger-service", func(event PaymentEvent) {
+		fmt.Printf("  [Ledger] %s paid %.2f (%s)\n", event.OrderID, event.Amount, event.Status)
+		received <- event
+	})
+	if err != nil {
+		fmt.Printf("Subscribe failed: %v\n", err)
+		return
+	}
+
+	err = payments.Publish(PaymentEvent{OrderID: "order_1001", Amount: 999, Status: "SUCCESS"})
+	fmt.Printf("Publish valid event: err=%v\n", err)
+	<-received
+
+	// payments.Publish(map[string]interface{}{...}) does not compile;
+	// rebinding the topic to another type is caught at runtime
+	_, err = NewTypedTopic[map[string]interface{}](broker, "payments")
+	fmt.Printf("Bind 'payments' to another type: %v\n", err)
+	_, err = NewTypedTopic[PaymentEvent](broker, "payments") // topic exists already, same type => fine
+	fmt.Printf("Bind 'payments' to PaymentEvent again: err=%v\n", err)
+
+	// enum values can be arrays / objects too
+	registry.Register("routes", `{"type": "array", "enum": [["air"], ["sea", "rail"]]}`)
+	fmt.Printf("Validate [sea rail] against an array enum: err=%v\n", registry.Validate("routes", []string{"sea", "rail"}))
+
+	// 1e19 is a whole number: an int64 round trip called it "number"
+	fmt.Printf("JSON type of 1e19: %s\n", jsonType(1e19))
+
+	err = broker.Publish("payments", map[string]interface{}{"orderId": 1001, "status": "PAID"})
+	fmt.Printf("Publish invalid event: %v\n", err)
+
+	subscriber.UnsubscribeFrom(payments.Name())
+}
+
+// testTracing - follow ONE order end to end:
+// PlaceOrder -> stock reservations on the worker pool -> "orders" event
+// -> email + shipping consumers, all spans in one trace
+// Runs on main's transport => `go run . kafka` carries the traceparent
+// through the kafka broker as a record header
+func testTracing(broker *PubSub) {
+	fmt.Println("\n=== TRACING ===")
+	recorder := tracing.NewRecorder()
+	tracer := tracing.NewTracer("order-service", recorder)
+	if path := os.Getenv("TRACE_FILE"); path != "" {
+		exporter, err := tracing.NewOTLPFileExporter(path)
+		if err != nil {
+			fmt.Printf("Cannot open trace file: %v\n", err)
+			return
+		}
+		defer exporter.Close()
+		tracer = tracing.NewTracer("order-service", recorder, exporter)
+	}
+
+	traced := NewPubSubWithTransport(broker.transport) // same transport, traced publish / process
+	traced.SetTracer(tracer)
+	traced.CreateTopic("orders")
+
+	pool := workerpool.NewPool[int](2, 10)
+	pool.SetTracer(tracer)
+	pool.Start()
+	defer pool.Shutdown(context.Background())
+
+	const orderID = "order_2001"
+	handled := make(chan string, 2)
+	for _, service := range []string{"email-service", "shipping-service"} {
+		service := service
+		subscriber := NewSubscriberContext(service, func(ctx context.Context, msg Message) {
+			if msg.Key != orderID {
+				return // kafka: older events on the same topic
+			}
+			_, span := tracer.Start(ctx, service+".handle")
+			time.Sleep(5 * time.Millisecond) // send mail / book courier
+			span.End()
+			handled <- service
+		}, traced)
+		if err := subscriber.SubscribeTo("orders"); err != nil {
+			fmt.Printf("Subscribe failed: %v\n", err)
+			return
+		}
+		defer subscriber.UnsubscribeFrom("orders")
+	}
+
+	// OrderService.PlaceOrder
+	ctx, span := tracer.Start(context.Background(), "OrderService.PlaceOrder")
+	span.SetAttribute("order.id", orderID)
+
+	var mu sync.Mutex
+	stock := map[string]int{"iphone-15": 5, "airpods": 0}
+	_, err := workerpool.Map(ctx, pool.WorkerPool, []string{"iphone-15", "airpods"}, func(ctx context.Context, sku string) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if stock[sku] == 0 {
+			return 0, fmt.Errorf("%s out of stock", sku)
+		}
+		stock[sku]--
+		return stock[sku], nil
+	})
+	if err != nil {
+		span.AddEvent("backorder", tracing.Attribute{Key: "reason", Value: err.Error()})
+	}
+	err = traced.PublishContext(ctx, "orders", orderID, map[string]interface{}{"orderId": orderID, "status": "PLACED"})
+	span.RecordError(err)
+	span.End()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-handled:
+		case <-time.After(5 * time.Second):
+			fmt.Println("Timed out waiting for consumers")
+			i = 2
+		}
+	}
+	time.Sleep(10 * time.Millisecond) // process spans end right after the handler returns
+	tracer.Flush()
+
+	fmt.Printf("trace %s:\n", span.SpanContext().TraceID)
+	fmt.Print(tracing.FormatTree(recorder.Spans(), span.SpanContext().TraceID))
+}
@@ -0,0 +1,285 @@
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exporter - where finished spans go
+// Export is called from whichever goroutine filled the batch / called
+// Flush => implementations must be safe for concurrent use
+type Exporter interface {
+	Export(service string, spans []SpanData) error
+}
+
+// ============================================
+// OTLP JSON EXPORTER
+// One line per batch, each line a complete ExportTraceServiceRequest:
+//
+//	{"resourceSpans":[{"resource":{"attributes":[{"key":"service.name",...}]},
+//	  "scopeSpans":[{"scope":{"name":"yourname/observability/tracing"},"spans":[{
+//	    "traceId":"4bf9...","spanId":"00f0...","parentSpanId":"...",
+//	    "name":"workerpool.execute","kind":1,
+//	    "startTimeUnixNano":"1700000000000000000","endTimeUnixNano":"...",
+//	    "attributes":[{"key":"job.id","value":{"intValue":"17"}}],
+//	    "status":{"code":2,"message":"insufficient stock"}}]}]}]}
+//
+// Same encoding as OTLP/HTTP JSON (hex ids, int64 as strings), so the file
+// can be replayed into a collector or read by jq
+// ============================================
+
+type OTLPJSONExporter struct {
+	w      io.Writer
+	closer io.Closer // set when we opened the file ourselves
+	mu     sync.Mutex
+}
+
+// NewOTLPJSONExporter - os.Stdout while developing, any io.Writer in tests
+func NewOTLPJSONExporter(w io.Writer) *OTLPJSONExporter {
+	return &OTLPJSONExporter{w: w}
+}
+
+// NewOTLPFileExporter - appends to path (created if missing)
+func NewOTLPFileExporter(path string) (*OTLPJSONExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open trace file: %w", err)
+	}
+	return &OTLPJSONExporter{w: file, closer: file}, nil
+}
+
+func (e *OTLPJSONExporter) Export(service string, spans []SpanData) error {
+	line, err := json.Marshal(toOTLP(service, spans))
+	if err != nil {
+		return fmt.Errorf("encode spans: %w", err)
+	}
+	line = append(line, '\n')
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err = e.w.Write(line)
+	return err
+}
+
+func (e *OTLPJSONExporter) Close() error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    StatusCode `json:"code,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+// otlpValue - exactly one field set (OTLP AnyValue)
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"` // int64 => string in OTLP JSON
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func toOTLP(service string, spans []SpanData) otlpRequest {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		s := otlpSpan{
+			TraceID:           span.SpanContext.TraceID.String(),
+			SpanID:            span.SpanContext.SpanID.String(),
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: unixNano(span.Start),
+			EndTimeUnixNano:   unixNano(span.End),
+			Attributes:        toKeyValues(span.Attributes),
+			Status:            otlpStatus{Code: span.Status, Message: span.StatusMessage},
+		}
+		if span.Parent.IsValid() {
+			s.ParentSpanID = span.Parent.SpanID.String()
+		}
+		for _, event := range span.Events {
+			s.Events = append(s.Events, otlpEvent{
+				TimeUnixNano: unixNano(event.Time),
+				Name:         event.Name,
+				Attributes:   toKeyValues(event.Attributes),
+			})
+		}
+		out = append(out, s)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: toKeyValues([]Attribute{{Key: "service.name", Value: service}})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "yourname/observability/tracing"}, Spans: out}},
+	}}}
+}
+
+func toKeyValues(attrs []Attribute) []otlpKeyValue {
+	out := make([]otlpKeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		out = append(out, otlpKeyValue{Key: attr.Key, Value: toValue(attr.Value)})
+	}
+	return out
+}
+
+func toValue(v interface{}) otlpValue {
+	switch v := v.(type) {
+	case string:
+		return otlpValue{StringValue: &v}
+	case bool:
+		return otlpValue{BoolValue: &v}
+	case int:
+		s := strconv.Itoa(v)
+		return otlpValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		return otlpValue{IntValue: &s}
+	case float64:
+		return otlpValue{DoubleValue: &v}
+	case time.Duration:
+		s := v.String()
+		return otlpValue{StringValue: &s}
+	default:
+		s := fmt.Sprint(v)
+		return otlpValue{StringValue: &s}
+	}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// ============================================
+// IN-MEMORY RECORDER
+// Keeps spans around => demos print the tree, tests assert on it
+// ============================================
+
+type Recorder struct {
+	spans []SpanData
+	mu    sync.Mutex
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) Export(service string, spans []SpanData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+func (r *Recorder) Spans() []SpanData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]SpanData(nil), r.spans...)
+}
+
+// FormatTree - one trace as an indented tree, children by start time
+//
+//	OrderService.PlaceOrder 12.4ms
+//	  workerpool.job 10.1ms job.id=17
+//	    workerpool.execute 8.0ms ERROR insufficient stock
+func FormatTree(spans []SpanData, traceID TraceID) string {
+	children := make(map[SpanID][]SpanData)
+	known := make(map[SpanID]bool)
+	for _, span := range spans {
+		if span.SpanContext.TraceID == traceID {
+			known[span.SpanContext.SpanID] = true
+		}
+	}
+
+	var roots []SpanData
+	for _, span := range spans {
+		if span.SpanContext.TraceID != traceID {
+			continue
+		}
+		if known[span.Parent.SpanID] {
+			children[span.Parent.SpanID] = append(children[span.Parent.SpanID], span)
+		} else {
+			roots = append(roots, span) // parent in another process / not exported yet
+		}
+	}
+
+	byStart := func(list []SpanData) {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Start.Before(list[j].Start) })
+	}
+
+	var b strings.Builder
+	var walk func(span SpanData, depth int)
+	walk = func(span SpanData, depth int) {
+		fmt.Fprintf(&b, "%s%s %v", strings.Repeat("  ", depth), span.Name, span.End.Sub(span.Start).Round(10*time.Microsecond))
+		for _, attr := range span.Attributes {
+			fmt.Fprintf(&b, " %s=%v", attr.Key, attr.Value)
+		}
+		if span.Status == StatusError {
+			fmt.Fprintf(&b, " ERROR %s", span.StatusMessage)
+		}
+		b.WriteString("\n")
+
+		kids := children[span.SpanContext.SpanID]
+		byStart(kids)
+		for _, child := range kids {
+			walk(child, depth+1)
+		}
+	}
+
+	byStart(roots)
+	for _, root := range roots {
+		walk(root, 0)
+	}
+	return b.String()
+}
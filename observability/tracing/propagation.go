@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ============================================
+// PROPAGATION - W3C Trace Context
+// The span context travels with the message as ONE header:
+//
+//	traceparent: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+//	             ver-trace id (16 bytes)              -parent span     -flags
+//
+// Producer: Inject(ctx, msg.Metadata)
+// Consumer: ctx := Extract(ctx, msg.Metadata) => spans started from ctx
+// continue the producer's trace, even in another process
+// ============================================
+
+const TraceparentHeader = "traceparent"
+
+// Inject - writes ctx's span context into carrier (no-op without one)
+func Inject(ctx context.Context, carrier map[string]string) {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() || carrier == nil {
+		return
+	}
+	carrier[TraceparentHeader] = FormatTraceparent(sc)
+}
+
+// Extract - ctx carrying the remote parent found in carrier
+// Missing or malformed header => ctx unchanged (new trace downstream)
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	sc, err := ParseTraceparent(carrier[TraceparentHeader])
+	if err != nil {
+		return ctx
+	}
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
+
+func FormatTraceparent(sc SpanContext) string {
+	return fmt.Sprintf("00-%s-%s-01", sc.TraceID, sc.SpanID)
+}
+
+func ParseTraceparent(header string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, fmt.Errorf("malformed traceparent %q", header)
+	}
+	if parts[0] == "ff" {
+		return SpanContext{}, fmt.Errorf("traceparent version ff is invalid")
+	}
+
+	var sc SpanContext
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, fmt.Errorf("traceparent trace id: %w", err)
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, fmt.Errorf("traceparent span id: %w", err)
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("traceparent with all-zero id %q", header)
+	}
+	sc.Remote = true
+	return sc, nil
+}
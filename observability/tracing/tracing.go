@@ -0,0 +1,361 @@
+// Package tracing - OpenTelemetry-style spans without the SDK
+//
+// Before: a failed job printed "Worker 3: Job 17 failed: ..." and that was
+// it - no idea which order it belonged to, how long it sat in the queue,
+// or which consumer picked up the event it produced.
+// Now every step is a Span in one Trace:
+//
+//	OrderService.PlaceOrder                      (trace 4bf9...)
+//	├── workerpool.job            job.id=17
+//	│   ├── workerpool.submit
+//	│   ├── workerpool.queue_wait worker.id=3
+//	│   ├── workerpool.execute    error: insufficient stock
+//	│   └── workerpool.result
+//	└── order.placed publish      (traceparent in Message metadata)
+//	    └── order.placed process  subscriber=email-service
+//
+// Spans are exported as OTLP JSON (see exporter.go) => a file any OTLP
+// tool can read, or stdout while developing.
+//
+// Usage:
+//
+//	tracer := tracing.NewTracer("order-service", tracing.NewOTLPJSONExporter(os.Stdout))
+//	ctx, span := tracer.Start(ctx, "OrderService.PlaceOrder")
+//	defer span.End()
+//
+// A nil *Tracer and a nil *Span are valid and do nothing, so code can be
+// instrumented unconditionally and tracing switched on per deployment.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ============================================
+// IDS & SPAN CONTEXT
+// ============================================
+
+type TraceID [16]byte
+
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (t TraceID) IsValid() bool  { return t != TraceID{} }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+func (s SpanID) IsValid() bool   { return s != SpanID{} }
+
+// SpanContext - the part of a span that crosses process boundaries
+// (what traceparent carries)
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Remote  bool // came in through Extract, not started here
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	rand.Read(id[:])
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	rand.Read(id[:])
+	return id
+}
+
+// ============================================
+// SPAN
+// ============================================
+
+// SpanKind - values match OTLP so the exporter writes them as is
+type SpanKind int
+
+const (
+	KindInternal SpanKind = iota + 1
+	KindServer
+	KindClient
+	KindProducer
+	KindConsumer
+)
+
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = iota
+	StatusOK
+	StatusError
+)
+
+type Attribute struct {
+	Key   string
+	Value interface{} // string, bool, int, int64, float64 (anything else => fmt.Sprint)
+}
+
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes []Attribute
+}
+
+// SpanData - finished span as handed to the Exporter
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	Parent        SpanContext // zero => root span
+	Start         time.Time
+	End           time.Time
+	Attributes    []Attribute
+	Events        []Event
+	Status        StatusCode
+	StatusMessage string
+}
+
+type Span struct {
+	tracer *Tracer
+	data   SpanData
+	ended  bool
+	mu     sync.Mutex
+}
+
+// SpanContext - zero for a nil span
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.SpanContext
+}
+
+// SetAttribute - same key again overwrites
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.data.Attributes {
+		if s.data.Attributes[i].Key == key {
+			s.data.Attributes[i].Value = value
+			return
+		}
+	}
+	s.data.Attributes = append(s.data.Attributes, Attribute{Key: key, Value: value})
+}
+
+func (s *Span) AddEvent(name string, attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Events = append(s.data.Events, Event{Name: name, Time: time.Now(), Attributes: attrs})
+}
+
+// RecordError - "exception" event + Error status (nil err is ignored)
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.AddEvent("exception",
+		Attribute{Key: "exception.type", Value: fmt.Sprintf("%T", err)},
+		Attribute{Key: "exception.message", Value: err.Error()},
+	)
+	s.SetStatus(StatusError, err.Error())
+}
+
+// SetStatus - Error is sticky: a later OK doesn't hide a recorded failure
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.Status == StatusError && code != StatusError {
+		return
+	}
+	s.data.Status = code
+	s.data.StatusMessage = message
+}
+
+func (s *Span) End() {
+	s.EndAt(time.Now())
+}
+
+// EndAt - for spans measured after the fact (queue wait)
+// Only the first End counts
+func (s *Span) EndAt(t time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = t
+	data := s.data
+	data.Attributes = append([]Attribute(nil), s.data.Attributes...)
+	data.Events = append([]Event(nil), s.data.Events...)
+	s.mu.Unlock()
+
+	s.tracer.export(data)
+}
+
+// ============================================
+// TRACER
+// ============================================
+
+type SpanOptions struct {
+	Kind       SpanKind  // default KindInternal
+	StartTime  time.Time // default now
+	Attributes []Attribute
+}
+
+// Tracer - creates spans for one service, buffers finished ones and
+// hands them to the exporters in batches
+type Tracer struct {
+	service   string
+	exporters []Exporter
+	batchSize int
+
+	buffer []SpanData
+	mu     sync.Mutex
+}
+
+// NewTracer - finished spans are exported every 64 spans and on Flush
+func NewTracer(service string, exporters ...Exporter) *Tracer {
+	return &Tracer{
+		service:   service,
+		exporters: exporters,
+		batchSize: 64,
+	}
+}
+
+func (t *Tracer) Service() string {
+	if t == nil {
+		return ""
+	}
+	return t.service
+}
+
+// Start - internal span, child of whatever span ctx carries
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	return t.StartSpan(ctx, name, SpanOptions{})
+}
+
+// StartSpan - parent is the span in ctx, else a remote SpanContext put
+// there by Extract, else the span starts a new trace
+func (t *Tracer) StartSpan(ctx context.Context, name string, opts SpanOptions) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	if opts.Kind == 0 {
+		opts.Kind = KindInternal
+	}
+	if opts.StartTime.IsZero() {
+		opts.StartTime = time.Now()
+	}
+
+	parent := SpanContextFromContext(ctx)
+	traceID := parent.TraceID
+	if !parent.IsValid() {
+		traceID = newTraceID()
+	}
+
+	span := &Span{
+		tracer: t,
+		data: SpanData{
+			Name:        name,
+			Kind:        opts.Kind,
+			SpanContext: SpanContext{TraceID: traceID, SpanID: newSpanID()},
+			Parent:      parent,
+			Start:       opts.StartTime,
+			Attributes:  append([]Attribute(nil), opts.Attributes...),
+		},
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+func (t *Tracer) export(span SpanData) {
+	t.mu.Lock()
+	t.buffer = append(t.buffer, span)
+	full := len(t.buffer) >= t.batchSize
+	t.mu.Unlock()
+
+	if full {
+		t.Flush()
+	}
+}
+
+// Flush - export everything finished so far (call before exit)
+func (t *Tracer) Flush() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	batch := t.buffer
+	t.buffer = nil
+	t.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+	var firstErr error
+	for _, exporter := range t.exporters {
+		if err := exporter.Export(t.service, batch); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// ============================================
+// CONTEXT
+// ============================================
+
+type spanKey struct{}
+type remoteKey struct{}
+
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	if span == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext - nil if ctx carries no local span
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemoteSpanContext - parent for spans started from ctx
+// (used by Extract for the consumer side of a message)
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	sc.Remote = true
+	ctx = context.WithValue(ctx, spanKey{}, (*Span)(nil)) // remote parent wins over an older local span
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SpanContextFromContext - local span first, then remote parent
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext()
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
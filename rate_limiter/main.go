@@ -0,0 +1,185 @@
+// main.go
+// Rate limiters from rate_limiter/ratelimit in front of service entry points:
+// OrderService.PlaceOrder, BookingHandler (HTTP) and a broker's handleClient
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"yourname/rate_limiter/ratelimit"
+)
+
+// fakeClock - lets the demo "wait" without sleeping
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func main() {
+	testAlgorithms()
+	testPerTenantKeys()
+	testOrderService()
+	testHTTPMiddleware()
+	testConfigAndIdleKeys()
+}
+
+// testAlgorithms - same traffic (8 requests at once, then 1s later 3 more)
+// through all three algorithms
+func testAlgorithms() {
+	fmt.Println("=== Test 1: Same burst, three algorithms ===")
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+
+	tokenBucket, _ := ratelimit.NewTokenBucket(2, 5) // 2/sec, burst 5
+	tokenBucket.Now = clock.Now
+	leakyBucket, _ := ratelimit.NewLeakyBucket(2, 5) // leaks 2/sec, holds 5
+	leakyBucket.Now = clock.Now
+	slidingLog, _ := ratelimit.NewSlidingWindowLog(5, time.Second) // 5 per 1s
+	slidingLog.Now = clock.Now
+
+	limiters := []struct {
+		name    string
+		limiter ratelimit.Limiter
+	}{
+		{"token bucket", tokenBucket},
+		{"leaky bucket", leakyBucket},
+		{"sliding log ", slidingLog},
+	}
+
+	for _, l := range limiters {
+		start := clock.now
+		allowed, last := 0, ratelimit.Decision{}
+		for i := 0; i < 8; i++ {
+			if last = l.limiter.Allow("user_1"); last.Allowed {
+				allowed++
+			}
+		}
+		fmt.Printf("  %s: burst of 8 => %d allowed, retry after %v\n", l.name, allowed, last.RetryAfter)
+
+		clock.Advance(time.Second)
+		allowed = 0
+		for i := 0; i < 3; i++ {
+			if l.limiter.Allow("user_1").Allowed {
+				allowed++
+			}
+		}
+		fmt.Printf("  %s: 1s later, 3 more => %d allowed\n", l.name, allowed)
+		clock.now = start.Add(time.Hour) // fresh start for the next algorithm
+	}
+}
+
+// testPerTenantKeys - one noisy tenant doesn't eat the others' quota
+func testPerTenantKeys() {
+	fmt.Println("\n=== Test 2: Keys are independent ===")
+
+	limiter, _ := ratelimit.NewTokenBucket(1, 3)
+	for i := 0; i < 10; i++ {
+		limiter.Allow("tenant_noisy")
+	}
+	noisy := limiter.Allow("tenant_noisy")
+	quiet := limiter.Allow("tenant_quiet")
+	fmt.Printf("  tenant_noisy allowed=%v, tenant_quiet allowed=%v (remaining %d)\n",
+		noisy.Allowed, quiet.Allowed, quiet.Remaining)
+}
+
+// ============================================
+// SERVICE LEVEL - no HTTP, just check before doing work
+// ============================================
+
+var ErrRateLimited = errors.New("rate limited")
+
+type OrderService struct {
+	limiter ratelimit.Limiter
+}
+
+func (s *OrderService) PlaceOrder(userID, orderID string) error {
+	if decision := s.limiter.Allow(userID); !decision.Allowed {
+		return fmt.Errorf("%w: retry after %v", ErrRateLimited, decision.RetryAfter)
+	}
+	fmt.Printf("  ✅ Order %s placed for %s\n", orderID, userID)
+	return nil
+}
+
+// Broker's handleClient loop: same idea, key = client connection
+func handleClient(clientID string, commands []string, limiter ratelimit.Limiter) {
+	for _, cmd := range commands {
+		if decision := limiter.Allow(clientID); !decision.Allowed {
+			fmt.Printf("  ⛔ %s: %q rejected, retry after %v\n", clientID, cmd, decision.RetryAfter)
+			continue
+		}
+		fmt.Printf("  📨 %s: %q processed\n", clientID, cmd)
+	}
+}
+
+func testOrderService() {
+	fmt.Println("\n=== Test 3: OrderService.PlaceOrder and broker handleClient ===")
+
+	perMinute, _ := ratelimit.NewSlidingWindowLog(2, time.Minute)
+	orders := &OrderService{limiter: perMinute}
+	for i := 1; i <= 3; i++ {
+		if err := orders.PlaceOrder("user_1", fmt.Sprintf("ORD-%d", i)); err != nil {
+			fmt.Printf("  ❌ ORD-%d: %v (is ErrRateLimited: %v)\n", i, err, errors.Is(err, ErrRateLimited))
+		}
+	}
+
+	perClient, _ := ratelimit.NewLeakyBucket(10, 2)
+	handleClient("producer-1", []string{"PRODUCE a", "PRODUCE b", "PRODUCE c"}, perClient)
+}
+
+// ============================================
+// HTTP - same wiring as BookingHandler in where_to_put_factory
+// ============================================
+
+func testHTTPMiddleware() {
+	fmt.Println("\n=== Test 4: HTTP middleware => 429 + Retry-After ===")
+
+	createBooking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	byUser := func(r *http.Request) string { return r.Header.Get("X-User-ID") }
+
+	perUser, _ := ratelimit.NewTokenBucket(0.5, 2)
+	handler := ratelimit.Middleware(perUser, byUser)(createBooking)
+
+	for i := 1; i <= 3; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/bookings", nil)
+		req.Header.Set("X-User-ID", "user_123")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		fmt.Printf("  POST /bookings #%d => %d Retry-After=%q\n", i, rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
+
+// testConfigAndIdleKeys - a zero rate is refused up front (RetryAfter
+// would be Inf), and a scan from 10,000 IPs doesn't stay in memory
+func testConfigAndIdleKeys() {
+	fmt.Println("\n=== Test 5: Bad config, idle keys swept ===")
+
+	for _, build := range []func() error{
+		func() error { _, err := ratelimit.NewTokenBucket(0, 5); return err },
+		func() error { _, err := ratelimit.NewLeakyBucket(-1, 5); return err },
+		func() error { _, err := ratelimit.NewSlidingWindowLog(5, 0); return err },
+	} {
+		err := build()
+		fmt.Printf("  ❌ %v (is ErrBadConfig: %v)\n", err, errors.Is(err, ratelimit.ErrBadConfig))
+	}
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	limiter, _ := ratelimit.NewTokenBucket(1, 5)
+	limiter.Now = clock.Now
+	for i := 0; i < 10000; i++ {
+		limiter.Allow(fmt.Sprintf("ip:10.0.%d.%d", i/256, i%256))
+	}
+	fmt.Printf("  after a scan from 10,000 IPs: %d keys\n", limiter.Keys())
+
+	clock.Advance(2 * time.Minute) // every bucket refilled long ago
+	limiter.Allow("ip:192.168.1.7")
+	fmt.Printf("  2 minutes later, next request sweeps: %d key(s)\n", limiter.Keys())
+}
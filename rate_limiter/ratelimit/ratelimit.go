@@ -0,0 +1,295 @@
+// Package ratelimit - in-process rate limiters keyed per user / tenant
+//
+// Three algorithms, same Limiter interface:
+//
+//	TOKEN BUCKET        bucket of Burst tokens, refilled at Rate/sec
+//	                    each request takes 1 token
+//	                    => allows short bursts, average capped at Rate
+//
+//	LEAKY BUCKET        bucket of Capacity, leaks at Rate/sec
+//	                    each request adds 1 drop, rejected if it would overflow
+//	                    => smooth output, bursts only up to Capacity
+//
+//	SLIDING WINDOW LOG  remember timestamp of every accepted request
+//	                    allow if < Limit requests in last Window
+//	                    => exact, but memory grows with Limit
+//
+// Every key (userID, tenantID, IP ...) gets its own independent bucket/log.
+// Keys whose state is back to "never seen" (bucket refilled / drained, log
+// empty) are swept every SweepEvery => memory follows the keys active right
+// now, not every IP that ever connected; dropping them changes no decision.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var ErrBadConfig = errors.New("invalid rate limiter config")
+
+// Decision - result of one Allow call
+type Decision struct {
+	Allowed    bool
+	Remaining  int           // requests left right now (approx for buckets)
+	RetryAfter time.Duration // when rejected: earliest time a retry can succeed
+}
+
+type Limiter interface {
+	Allow(key string) Decision
+}
+
+// keyedStore - per-key state + injectable clock, shared by all algorithms
+type keyedStore[S any] struct {
+	state map[string]*S
+	Now   func() time.Time
+	// SweepEvery - how often Allow drops idle keys (1 minute by default)
+	SweepEvery time.Duration
+	lastSweep  time.Time
+	mu         sync.Mutex
+}
+
+func newKeyedStore[S any]() keyedStore[S] {
+	return keyedStore[S]{
+		state:      make(map[string]*S),
+		Now:        time.Now,
+		SweepEvery: time.Minute,
+	}
+}
+
+// Keys - how many keys have state right now
+func (s *keyedStore[S]) Keys() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.state)
+}
+
+// get - caller holds mu; idle reports whether a key's state is the same
+// as a fresh key's at now (safe to forget)
+func (s *keyedStore[S]) get(key string, now time.Time, idle func(st *S, now time.Time) bool, init func() *S) *S {
+	if now.Sub(s.lastSweep) >= s.SweepEvery {
+		for k, st := range s.state {
+			if idle(st, now) {
+				delete(s.state, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	st, ok := s.state[key]
+	if !ok {
+		st = init()
+		s.state[key] = st
+	}
+	return st
+}
+
+// ============================================
+// TOKEN BUCKET
+// ============================================
+
+type tokenState struct {
+	tokens float64
+	last   time.Time
+}
+
+type TokenBucket struct {
+	Rate  float64 // tokens added per second
+	Burst int     // bucket size
+	keyedStore[tokenState]
+}
+
+// NewTokenBucket - ratePerSecond > 0 (0 would make RetryAfter infinite),
+// burst >= 1
+func NewTokenBucket(ratePerSecond float64, burst int) (*TokenBucket, error) {
+	if err := checkRate(ratePerSecond); err != nil {
+		return nil, err
+	}
+	if burst < 1 {
+		return nil, fmt.Errorf("%w: burst %d, need at least 1", ErrBadConfig, burst)
+	}
+	return &TokenBucket{
+		Rate:       ratePerSecond,
+		Burst:      burst,
+		keyedStore: newKeyedStore[tokenState](),
+	}, nil
+}
+
+// idle - refilled to Burst by now => same as a new key
+func (tb *TokenBucket) idle(st *tokenState, now time.Time) bool {
+	return st.tokens+now.Sub(st.last).Seconds()*tb.Rate >= float64(tb.Burst)
+}
+
+func (tb *TokenBucket) Allow(key string) Decision {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	now := tb.Now()
+	st := tb.get(key, now, tb.idle, func() *tokenState {
+		return &tokenState{tokens: float64(tb.Burst), last: now} // new key starts full
+	})
+
+	// refill for the time since last request
+	st.tokens = math.Min(float64(tb.Burst), st.tokens+now.Sub(st.last).Seconds()*tb.Rate)
+	st.last = now
+
+	if st.tokens >= 1 {
+		st.tokens--
+		return Decision{Allowed: true, Remaining: int(st.tokens)}
+	}
+	missing := 1 - st.tokens
+	return Decision{RetryAfter: secondsToDuration(missing / tb.Rate)}
+}
+
+// ============================================
+// LEAKY BUCKET (as a meter)
+// ============================================
+
+type leakyState struct {
+	level float64
+	last  time.Time
+}
+
+type LeakyBucket struct {
+	Rate     float64 // drops leaked per second
+	Capacity int
+	keyedStore[leakyState]
+}
+
+// NewLeakyBucket - ratePerSecond > 0, capacity >= 1
+func NewLeakyBucket(ratePerSecond float64, capacity int) (*LeakyBucket, error) {
+	if err := checkRate(ratePerSecond); err != nil {
+		return nil, err
+	}
+	if capacity < 1 {
+		return nil, fmt.Errorf("%w: capacity %d, need at least 1", ErrBadConfig, capacity)
+	}
+	return &LeakyBucket{
+		Rate:       ratePerSecond,
+		Capacity:   capacity,
+		keyedStore: newKeyedStore[leakyState](),
+	}, nil
+}
+
+// idle - drained by now => same as a new key
+func (lb *LeakyBucket) idle(st *leakyState, now time.Time) bool {
+	return st.level-now.Sub(st.last).Seconds()*lb.Rate <= 0
+}
+
+func (lb *LeakyBucket) Allow(key string) Decision {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	now := lb.Now()
+	st := lb.get(key, now, lb.idle, func() *leakyState {
+		return &leakyState{last: now} // new key starts empty
+	})
+
+	st.level = math.Max(0, st.level-now.Sub(st.last).Seconds()*lb.Rate)
+	st.last = now
+
+	if st.level+1 <= float64(lb.Capacity) {
+		st.level++
+		return Decision{Allowed: true, Remaining: int(float64(lb.Capacity) - st.level)}
+	}
+	overflow := st.level + 1 - float64(lb.Capacity)
+	return Decision{RetryAfter: secondsToDuration(overflow / lb.Rate)}
+}
+
+// ============================================
+// SLIDING WINDOW LOG
+// ============================================
+
+type windowState struct {
+	accepted []time.Time // oldest first
+}
+
+type SlidingWindowLog struct {
+	Limit  int
+	Window time.Duration
+	keyedStore[windowState]
+}
+
+// NewSlidingWindowLog - limit >= 1, window > 0
+func NewSlidingWindowLog(limit int, window time.Duration) (*SlidingWindowLog, error) {
+	if limit < 1 || window <= 0 {
+		return nil, fmt.Errorf("%w: limit %d per %v", ErrBadConfig, limit, window)
+	}
+	return &SlidingWindowLog{
+		Limit:      limit,
+		Window:     window,
+		keyedStore: newKeyedStore[windowState](),
+	}, nil
+}
+
+// idle - every accepted request slid out of the window
+func (sw *SlidingWindowLog) idle(st *windowState, now time.Time) bool {
+	return len(st.accepted) == 0 || !st.accepted[len(st.accepted)-1].After(now.Add(-sw.Window))
+}
+
+func (sw *SlidingWindowLog) Allow(key string) Decision {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	now := sw.Now()
+	st := sw.get(key, now, sw.idle, func() *windowState { return &windowState{} })
+
+	// drop requests that slid out of the window
+	cutoff := now.Add(-sw.Window)
+	i := 0
+	for i < len(st.accepted) && !st.accepted[i].After(cutoff) {
+		i++
+	}
+	st.accepted = st.accepted[i:]
+
+	if len(st.accepted) < sw.Limit {
+		st.accepted = append(st.accepted, now)
+		return Decision{Allowed: true, Remaining: sw.Limit - len(st.accepted)}
+	}
+	// oldest request leaving the window frees one slot
+	return Decision{RetryAfter: st.accepted[0].Add(sw.Window).Sub(now)}
+}
+
+func checkRate(ratePerSecond float64) error {
+	if !(ratePerSecond > 0) || math.IsInf(ratePerSecond, 1) { // !(x > 0) also catches NaN
+		return fmt.Errorf("%w: rate %v/sec, need a positive finite rate", ErrBadConfig, ratePerSecond)
+	}
+	return nil
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
+
+// ============================================
+// HTTP MIDDLEWARE
+// ============================================
+
+// KeyFunc - which bucket a request belongs to (user ID, tenant, IP ...)
+type KeyFunc func(r *http.Request) string
+
+// Middleware - 429 Too Many Requests + Retry-After when key is over limit
+//
+//	http.Handle("/bookings", ratelimit.Middleware(limiter, byUser)(handler))
+func Middleware(limiter Limiter, keyFunc KeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			decision := limiter.Allow(keyFunc(r))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+
+			if !decision.Allowed {
+				// Retry-After is in whole seconds, round UP so the client
+				// never retries too early
+				retryAfter := int(math.Ceil(decision.RetryAfter.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"net"
 	"net/http"
+
+	"yourname/rate_limiter/ratelimit"
 )
 
 // ============================================
@@ -387,8 +390,28 @@ func main() {
 		ServiceFactory: serviceFactory,
 	}
 
+	// ✅ Rate limit per user: bursts of 5, then 1 booking/sec
+	//    Over the limit => 429 + Retry-After, handler never runs
+	bookingLimiter, err := ratelimit.NewTokenBucket(1, 5)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// NOT GetUserFromContext: without an auth middleware it falls back to
+	// the demo user_123 for everyone => one bucket for the whole world.
+	// Real user when auth put one in the context, else the client's IP
+	byUser := func(r *http.Request) string {
+		if user, ok := r.Context().Value(userContextKey).(*User); ok {
+			return "user:" + user.ID
+		}
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		return "ip:" + host
+	}
+
 	// Register routes
-	http.HandleFunc("/bookings", bookingHandler.CreateBooking)
+	http.Handle("/bookings", ratelimit.Middleware(bookingLimiter, byUser)(http.HandlerFunc(bookingHandler.CreateBooking)))
 
 	log.Println("✅ Server running on :8080")
 	log.Fatal(http.ListenAndServe(":8080", nil))
@@ -0,0 +1,142 @@
+package cron
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock - the scheduler never calls time.Now / time.NewTimer directly
+// => tests swap in FakeClock and move time by hand, no real sleeping
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// RealClock - wall clock
+type RealClock struct{}
+
+func (RealClock) Now() time.Time { return time.Now() }
+
+func (RealClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time { return t.timer.C }
+func (t realTimer) Stop() bool          { return t.timer.Stop() }
+
+// ============================================
+// FAKE CLOCK
+// Time only moves on Advance / Set. Timers whose deadline is reached
+// fire during that call.
+//
+//	clock := cron.NewFakeClock(start)
+//	clock.Advance(time.Hour)  // fires everything due in that hour
+//	clock.BlockUntil(1)       // wait until the scheduler sleeps again
+// ============================================
+
+type FakeClock struct {
+	now     time.Time
+	timers  []*fakeTimer
+	changed chan struct{} // closed + replaced whenever timers change
+	mu      sync.Mutex
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	deadline time.Time
+	ch       chan time.Time
+}
+
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start, changed: make(chan struct{})}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{clock: c, deadline: c.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.ch <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	c.notify()
+	return t
+}
+
+// Advance - moves time forward, fires due timers in deadline order
+func (c *FakeClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = now
+	sort.Slice(c.timers, func(i, j int) bool { return c.timers[i].deadline.Before(c.timers[j].deadline) })
+	waiting := c.timers[:0]
+	for _, t := range c.timers {
+		if t.deadline.After(now) {
+			waiting = append(waiting, t)
+			continue
+		}
+		t.ch <- now
+	}
+	c.timers = waiting
+	c.notify()
+}
+
+// BlockUntil - waits until exactly n timers are pending
+// (i.e. the code under test went back to sleep)
+func (c *FakeClock) BlockUntil(n int) {
+	for {
+		c.mu.Lock()
+		pending, changed := len(c.timers), c.changed
+		c.mu.Unlock()
+		if pending == n {
+			return
+		}
+		<-changed
+	}
+}
+
+// notify - caller holds mu
+func (c *FakeClock) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			c.notify()
+			return true
+		}
+	}
+	return false
+}
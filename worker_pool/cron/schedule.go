@@ -0,0 +1,236 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule - when a job runs
+// Next returns the first run time strictly after `after`,
+// zero time = no more runs
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// ============================================
+// ONE-SHOT & FIXED INTERVAL
+// ============================================
+
+type runAt struct {
+	at time.Time
+}
+
+// At - runs once at t (immediately if t already passed when added)
+func At(t time.Time) Schedule {
+	return runAt{at: t}
+}
+
+func (r runAt) Next(after time.Time) time.Time {
+	if r.at.After(after) {
+		return r.at
+	}
+	return time.Time{}
+}
+
+type every struct {
+	interval time.Duration
+}
+
+// Every - fixed interval, aligned to the interval (Every(time.Hour) => :00)
+func Every(interval time.Duration) Schedule {
+	if interval < time.Second {
+		interval = time.Second
+	}
+	return every{interval: interval}
+}
+
+func (e every) Next(after time.Time) time.Time {
+	return after.Truncate(e.interval).Add(e.interval)
+}
+
+// ============================================
+// CRON EXPRESSION
+//
+//	┌───────── minute       0-59
+//	│ ┌─────── hour         0-23
+//	│ │ ┌───── day of month 1-31
+//	│ │ │ ┌─── month        1-12
+//	│ │ │ │ ┌─ day of week  0-7 (0 and 7 = Sunday)
+//	* * * * *
+//
+// Each field: *  5  1-5  */15  0-30/10  1,15,30
+// Shortcuts:  @hourly @daily @midnight @weekly @monthly @yearly @every <duration>
+// If day of month AND day of week are both restricted, either matching
+// is enough (classic cron rule). As in Vixie cron a field starting with
+// "*" ("*/2" too) counts as unrestricted => "0 0 * * */2" runs on even
+// weekdays only, it is not OR-ed with every day of the month.
+// ============================================
+
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64 // bit i set => value i allowed
+	domStar, dowStar              bool
+}
+
+type fieldRange struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []fieldRange{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+var shortcuts = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron - "*/15 * * * *", "0 2 * * *", "@daily", "@every 10m" ...
+func ParseCron(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if rest, ok := strings.CutPrefix(expr, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("cron %q: %w", expr, err)
+		}
+		return Every(interval), nil
+	}
+	if full, ok := shortcuts[expr]; ok {
+		expr = full
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("cron %q: expected %d fields, got %d", expr, len(cronFields), len(parts))
+	}
+
+	bits := make([]uint64, len(cronFields))
+	for i, part := range parts {
+		b, err := parseField(part, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron %q: %w", expr, err)
+		}
+		bits[i] = b
+	}
+	return &cronSchedule{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     foldSunday(bits[4]),
+		domStar: strings.HasPrefix(parts[2], "*"),
+		dowStar: strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+// MustParseCron - for package-level schedules known to be valid
+func MustParseCron(expr string) Schedule {
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		panic(err)
+	}
+	return schedule
+}
+
+func parseField(field string, r fieldRange) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, step := item, 1
+		if before, after, ok := strings.Cut(item, "/"); ok {
+			rangePart = before
+			n, err := strconv.Atoi(after)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("%s: bad step %q", r.name, after)
+			}
+			step = n
+		}
+
+		lo, hi := r.min, r.max
+		if rangePart != "*" {
+			var err error
+			if before, after, ok := strings.Cut(rangePart, "-"); ok {
+				lo, err = strconv.Atoi(before)
+				if err == nil {
+					hi, err = strconv.Atoi(after)
+				}
+			} else {
+				lo, err = strconv.Atoi(rangePart)
+				hi = lo
+				if strings.Contains(item, "/") {
+					hi = r.max // "5/15" = from 5 every 15
+				}
+			}
+			if err != nil {
+				return 0, fmt.Errorf("%s: bad value %q", r.name, rangePart)
+			}
+		}
+		if lo < r.min || hi > r.max || lo > hi {
+			return 0, fmt.Errorf("%s: %q out of range %d-%d", r.name, item, r.min, r.max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// foldSunday - 7 is Sunday too (crontab allows both), time.Weekday only knows 0
+func foldSunday(dow uint64) uint64 {
+	if has(dow, 7) {
+		dow = dow&^(1<<7) | 1
+	}
+	return dow
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
+
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	domOK := has(c.dom, t.Day())
+	dowOK := has(c.dow, int(t.Weekday()))
+	if c.domStar || c.dowStar {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
+
+// Next - jumps field by field (month, day, hour, minute) instead of
+// trying every minute
+func (c *cronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0) // e.g. "0 0 30 2 *" never matches
+
+	for t.Before(limit) {
+		if !has(c.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !has(c.hour, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !has(c.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
@@ -0,0 +1,398 @@
+// Package cron - recurring and one-shot jobs dispatched into workerpool
+//
+// Replaces "go func() { for { time.Sleep(time.Hour); expireBookings() } }()":
+//   - cron expressions ("0 2 * * *") and one-shot At(t)
+//   - runs go through the shared WorkerPool (panics recovered, bounded)
+//   - a job never overlaps itself: next run waits for the previous one
+//   - Jitter spreads jobs that share a schedule (all "@hourly" at :00)
+//   - CatchUp decides what happens to runs missed while the process was
+//     paused / the previous run took too long
+//   - nothing is persisted: runs missed while the process was DOWN are
+//     only caught up if the caller stores each run's ScheduledTime and
+//     hands it back as JobOptions.LastRun on restart
+//   - Clock is injectable => FakeClock makes schedules testable instantly
+//
+// Usage:
+//
+//	s := cron.New(pool, cron.RealClock{})
+//	s.Add("expire-unpaid-bookings", cron.MustParseCron("*/5 * * * *"), task, cron.JobOptions{})
+//	s.Start(ctx)
+package cron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"yourname/worker_pool/workerpool"
+)
+
+var (
+	ErrDuplicateJob = errors.New("job with this name already scheduled")
+	ErrNoNextRun    = errors.New("schedule has no future run")
+)
+
+// CatchUp - what to do when several runs are due at once
+type CatchUp int
+
+const (
+	// CatchUpLatest - missed runs collapse into ONE run (default)
+	// nightly settlement missed 3 nights => settle once
+	CatchUpLatest CatchUp = iota
+	// CatchUpAll - every missed run executes, one after another
+	// each run sees its own time via ScheduledTime(ctx)
+	CatchUpAll
+	// CatchUpSkip - missed runs are dropped, a run only starts if it
+	// is at most Grace late
+	CatchUpSkip
+)
+
+type JobOptions struct {
+	Jitter  time.Duration // random delay in [0, Jitter) before each run
+	CatchUp CatchUp
+	Grace   time.Duration // CatchUpSkip: max lateness (default 1 minute)
+
+	// LastRun - scheduled time of the last run before a restart (the task
+	// saved ScheduledTime(ctx) somewhere durable). Occurrences after it
+	// that passed while the process was down go through CatchUp like any
+	// other missed run. Zero = start from now. Ignored for At
+	LastRun time.Time
+}
+
+// Entry - read-only view of a scheduled job
+type Entry struct {
+	Name       string
+	Next       time.Time // next scheduled run, zero = finished
+	LastRun    time.Time // scheduled time of the last started run
+	LastErr    error
+	Running    bool
+	Runs       int
+	Missed     int // runs dropped by the catch-up policy
+	Overlapped int // runs that had to wait for the previous one
+}
+
+type entry struct {
+	name     string
+	schedule Schedule
+	task     workerpool.Task[any]
+	opts     JobOptions
+
+	next    time.Time   // next occurrence (before jitter)
+	fireAt  time.Time   // next + jitter
+	backlog []time.Time // due occurrences waiting for the running one
+	running bool
+
+	lastRun    time.Time
+	lastErr    error
+	runs       int
+	missed     int
+	overlapped int
+}
+
+type Scheduler struct {
+	pool  *workerpool.WorkerPool
+	clock Clock
+	rand  *rand.Rand
+
+	entries  map[string]*entry
+	retiring map[string]*entry // removed while running; a re-Added job of the same name waits for it
+	wake     chan struct{}     // Add / Remove => recompute sleep time
+	runs     sync.WaitGroup
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	mu sync.Mutex
+}
+
+func New(pool *workerpool.WorkerPool, clock Clock) *Scheduler {
+	return &Scheduler{
+		pool:     pool,
+		clock:    clock,
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		entries:  make(map[string]*entry),
+		retiring: make(map[string]*entry),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Add - schedule can be added before or after Start
+func (s *Scheduler) Add(name string, schedule Schedule, task workerpool.Task[any], opts JobOptions) error {
+	if opts.Grace <= 0 {
+		opts.Grace = time.Minute
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.entries[name]; exists {
+		return fmt.Errorf("%w: %s", ErrDuplicateJob, name)
+	}
+
+	now := s.clock.Now()
+	e := &entry{name: name, schedule: schedule, task: task, opts: opts}
+	if at, ok := schedule.(runAt); ok {
+		e.next = at.at // may be in the past => runs right away
+	} else if !opts.LastRun.IsZero() && opts.LastRun.Before(now) {
+		e.next = schedule.Next(opts.LastRun) // may be in the past => catch-up
+		e.lastRun = opts.LastRun
+	} else {
+		e.next = schedule.Next(now)
+	}
+	if e.next.IsZero() {
+		return fmt.Errorf("%w: %s", ErrNoNextRun, name)
+	}
+	e.fireAt = e.next.Add(s.jitter(opts.Jitter))
+	s.entries[name] = e
+	s.notify()
+	return nil
+}
+
+// Remove - stops future runs, a running one finishes but nothing queued
+// behind it (catch-up / overlap backlog) starts afterwards
+// Add with the same name right after still never overlaps that run: the
+// new job's first run waits for it
+func (s *Scheduler) Remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[name]; ok {
+		e.backlog = nil // the running goroutine still holds e and dispatches from it
+		e.next = time.Time{}
+		delete(s.entries, name)
+		if e.running {
+			s.retiring[name] = e
+		}
+	}
+	s.notify()
+}
+
+func (s *Scheduler) Entries() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, Entry{
+			Name:       e.name,
+			Next:       e.next,
+			LastRun:    e.lastRun,
+			LastErr:    e.lastErr,
+			Running:    e.running,
+			Runs:       e.runs,
+			Missed:     e.missed,
+			Overlapped: e.overlapped,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done != nil {
+		return
+	}
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+	go s.loop(ctx)
+}
+
+// Stop - no new runs; returns after the running ones finished
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+	s.runs.Wait()
+}
+
+// Wait - blocks until no run is in flight (handy with FakeClock)
+func (s *Scheduler) Wait() {
+	s.runs.Wait()
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context) {
+	defer close(s.done)
+
+	for {
+		s.runDue(ctx, s.clock.Now())
+
+		// nothing scheduled => fire stays nil, only wake / ctx can end the wait
+		var timer Timer
+		var fire <-chan time.Time
+		if next, ok := s.nextFireAt(); ok {
+			timer = s.clock.NewTimer(next.Sub(s.clock.Now()))
+			fire = timer.C()
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-s.wake:
+		case <-fire:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+func (s *Scheduler) nextFireAt() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var earliest time.Time
+	for _, e := range s.entries {
+		if e.next.IsZero() {
+			continue
+		}
+		if earliest.IsZero() || e.fireAt.Before(earliest) {
+			earliest = e.fireAt
+		}
+	}
+	return earliest, !earliest.IsZero()
+}
+
+// runDue - apply catch-up policy to every entry whose time has come
+func (s *Scheduler) runDue(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.entries {
+		if e.next.IsZero() || e.fireAt.After(now) {
+			continue
+		}
+
+		// every occurrence <= now is due (more than one => we're late)
+		var due []time.Time
+		for occ := e.next; !occ.IsZero() && !occ.After(now); occ = e.schedule.Next(occ) {
+			due = append(due, occ)
+			e.next = e.schedule.Next(occ)
+			if len(due) == 1000 { // Every(1s) after a week offline...
+				break
+			}
+		}
+		if !e.next.IsZero() {
+			e.fireAt = e.next.Add(s.jitter(e.opts.Jitter))
+		}
+
+		s.enqueueRuns(e, due, now)
+		s.dispatch(ctx, e)
+	}
+}
+
+// enqueueRuns - caller holds mu
+func (s *Scheduler) enqueueRuns(e *entry, due []time.Time, now time.Time) {
+	if len(due) == 0 {
+		return
+	}
+	latest := due[len(due)-1]
+	running := s.busy(e)
+	if running {
+		e.overlapped++
+	}
+
+	switch e.opts.CatchUp {
+	case CatchUpAll:
+		e.backlog = append(e.backlog, due...)
+
+	case CatchUpSkip:
+		e.missed += len(due) - 1
+		if now.Sub(latest) > e.opts.Grace || len(e.backlog) > 0 || running {
+			e.missed++
+			return
+		}
+		e.backlog = append(e.backlog, latest)
+
+	default: // CatchUpLatest
+		e.missed += len(due) - 1
+		if len(e.backlog) > 0 {
+			e.missed++
+			e.backlog[0] = latest // still one pending run, just newer
+			return
+		}
+		e.backlog = append(e.backlog, latest)
+	}
+}
+
+// busy - a run of this job is in flight: e's own, or one of the removed
+// job e replaced (same name); caller holds mu
+func (s *Scheduler) busy(e *entry) bool {
+	old, ok := s.retiring[e.name]
+	return e.running || (ok && old != e)
+}
+
+// dispatch - caller holds mu, starts the next backlog run unless one
+// is already running (=> no overlap)
+func (s *Scheduler) dispatch(ctx context.Context, e *entry) {
+	if s.busy(e) || len(e.backlog) == 0 || ctx.Err() != nil {
+		return
+	}
+	scheduled := e.backlog[0]
+	e.backlog = e.backlog[1:]
+	e.running = true
+	e.lastRun = scheduled
+	e.runs++
+	s.runs.Add(1)
+
+	task := workerpool.TaskFunc[any](func(ctx context.Context) (interface{}, error) {
+		return e.task.Execute(context.WithValue(ctx, scheduledTimeKey{}, scheduled))
+	})
+
+	// Submit may block on a full pool, never inside the scheduler lock
+	go func() {
+		defer s.runs.Done()
+
+		future, err := s.pool.SubmitJob(ctx, workerpool.Job[any]{Task: task, Tenant: "cron:" + e.name})
+		if err == nil {
+			err = future.Result().Err
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		e.running = false
+		e.lastErr = err
+		if s.retiring[e.name] == e {
+			// removed meanwhile: a job re-Added under its name may be waiting
+			delete(s.retiring, e.name)
+			if next, ok := s.entries[e.name]; ok {
+				s.dispatch(ctx, next)
+			}
+			return
+		}
+		s.dispatch(ctx, e) // backlog from catch-up / overlap
+	}()
+}
+
+func (s *Scheduler) jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(s.rand.Int63n(int64(max)))
+}
+
+type scheduledTimeKey struct{}
+
+// ScheduledTime - the occurrence a run belongs to (not when it started)
+// e.g. settlement caught up with CatchUpAll settles the right night
+func ScheduledTime(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(scheduledTimeKey{}).(time.Time)
+	return t, ok
+}
@@ -0,0 +1,320 @@
+package main
+
+// Demo of worker_pool/cron - scheduled jobs instead of
+// `for { time.Sleep(...); doWork() }` goroutines
+// Everything runs on a FakeClock: days of schedule in milliseconds
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"yourname/worker_pool/cron"
+	"yourname/worker_pool/workerpool"
+)
+
+var start = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC) // a Friday
+
+// advance - move fake time, then wait until the scheduler has dispatched
+// everything that became due and those runs finished
+func advance(clock *cron.FakeClock, scheduler *cron.Scheduler, d time.Duration) {
+	clock.Advance(d)
+	clock.BlockUntil(1)
+	scheduler.Wait()
+}
+
+func printEntry(scheduler *cron.Scheduler, name string) {
+	for _, e := range scheduler.Entries() {
+		if e.Name != name {
+			continue
+		}
+		next := "none"
+		if !e.Next.IsZero() {
+			next = e.Next.Format("Mon 15:04")
+		}
+		fmt.Printf("  %s: runs=%d missed=%d overlapped=%d next=%s\n",
+			e.Name, e.Runs, e.Missed, e.Overlapped, next)
+	}
+}
+
+func main() {
+	fmt.Println("=== Cron Scheduler Demo ===")
+
+	testParse()
+
+	pool := workerpool.NewWorkerPool(4, 20)
+	pool.Start()
+	defer pool.Shutdown(context.Background())
+
+	testCatchUpLatest(pool)
+	testCatchUpAll(pool)
+	testNoOverlap(pool)
+	testRunAtAndJitter(pool)
+	testRestart(pool)
+	testRemoveWhileRunning(pool)
+	testRemoveAndReAdd(pool)
+
+	fmt.Println("\n=== Demo Complete ===")
+}
+
+func testParse() {
+	fmt.Println("\n--- Parsing ---")
+	for _, expr := range []string{"*/15 9-17 * * 1-5", "0 2 * * *", "@weekly", "0 9 * * 5-7", "@every 90m", "0 0 30 2 *", "0 0 1 * */2", "61 * * * *"} {
+		schedule, err := cron.ParseCron(expr)
+		if err != nil {
+			fmt.Printf("  %-20q error: %v\n", expr, err)
+			continue
+		}
+		next := schedule.Next(start.Add(17*time.Hour + 50*time.Minute))
+		if next.IsZero() {
+			fmt.Printf("  %-20q never runs\n", expr)
+			continue
+		}
+		fmt.Printf("  %-20q next after Fri 17:50 => %s\n", expr, next.Format("Mon Jan 2 15:04"))
+	}
+}
+
+// testCatchUpLatest - expire unpaid bookings every 5 minutes
+// Process paused for 20 minutes => one run, not four
+func testCatchUpLatest(pool *workerpool.WorkerPool) {
+	fmt.Println("\n--- Expire unpaid bookings (*/5, CatchUpLatest) ---")
+	clock := cron.NewFakeClock(start)
+	scheduler := cron.New(pool, clock)
+
+	expire := workerpool.TaskFunc[any](func(ctx context.Context) (interface{}, error) {
+		at, _ := cron.ScheduledTime(ctx)
+		fmt.Printf("  ⏰ expiring bookings unpaid since %s\n", at.Add(-15*time.Minute).Format("15:04"))
+		return nil, nil
+	})
+	scheduler.Add("expire-unpaid-bookings", cron.MustParseCron("*/5 * * * *"), expire, cron.JobOptions{})
+	scheduler.Start(context.Background())
+	defer scheduler.Stop()
+
+	clock.BlockUntil(1)
+	advance(clock, scheduler, 5*time.Minute)
+	advance(clock, scheduler, 20*time.Minute) // GC pause / laptop lid closed
+	printEntry(scheduler, "expire-unpaid-bookings")
+}
+
+// testCatchUpAll - nightly settlement must settle EVERY night
+func testCatchUpAll(pool *workerpool.WorkerPool) {
+	fmt.Println("\n--- Nightly settlement (0 2 * * *, CatchUpAll) ---")
+	clock := cron.NewFakeClock(start)
+	scheduler := cron.New(pool, clock)
+
+	settle := workerpool.TaskFunc[any](func(ctx context.Context) (interface{}, error) {
+		night, _ := cron.ScheduledTime(ctx)
+		fmt.Printf("  💰 settling payments for %s (run at %s)\n", night.Format("Mon Jan 2"), clock.Now().Format("Mon 15:04"))
+		return nil, nil
+	})
+	scheduler.Add("nightly-settlement", cron.MustParseCron("0 2 * * *"), settle, cron.JobOptions{CatchUp: cron.CatchUpAll})
+	scheduler.Start(context.Background())
+	defer scheduler.Stop()
+
+	clock.BlockUntil(1)
+	advance(clock, scheduler, 3*24*time.Hour) // down over the weekend
+	printEntry(scheduler, "nightly-settlement")
+}
+
+// testNoOverlap - release expired locker allocations every minute,
+// one run takes longer than a minute
+func testNoOverlap(pool *workerpool.WorkerPool) {
+	fmt.Println("\n--- Locker release (every minute, slow run) ---")
+	clock := cron.NewFakeClock(start)
+	scheduler := cron.New(pool, clock)
+
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	slow := make(chan struct{})
+	firstRun := true
+
+	release := workerpool.TaskFunc[any](func(ctx context.Context) (interface{}, error) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		wait := firstRun
+		firstRun = false
+		mu.Unlock()
+
+		if wait {
+			<-slow // first run stuck on a slow locker API
+		}
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil, nil
+	})
+	scheduler.Add("release-locker-allocations", cron.Every(time.Minute), release, cron.JobOptions{})
+	scheduler.Start(context.Background())
+	defer scheduler.Stop()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute) // run 1 starts and hangs
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute) // run 2 due while run 1 still running
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute) // run 3 due too => collapsed with run 2
+	clock.BlockUntil(1)
+	close(slow)
+	scheduler.Wait()
+
+	printEntry(scheduler, "release-locker-allocations")
+	fmt.Printf("  max concurrent runs: %d\n", maxRunning)
+}
+
+// testRunAtAndJitter - one-shot + three hourly jobs spread by jitter
+func testRunAtAndJitter(pool *workerpool.WorkerPool) {
+	fmt.Println("\n--- One-shot At + jitter ---")
+	clock := cron.NewFakeClock(start)
+	scheduler := cron.New(pool, clock)
+
+	var mu sync.Mutex
+	record := func(name string) workerpool.Task[any] {
+		return workerpool.TaskFunc[any](func(ctx context.Context) (interface{}, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			fmt.Printf("  ▶ %s at %s\n", name, clock.Now().Format("15:04"))
+			return nil, nil
+		})
+	}
+
+	scheduler.Add("cancel-booking-B42", cron.At(start.Add(30*time.Minute)), record("cancel-booking-B42"), cron.JobOptions{})
+	for _, name := range []string{"sync-inventory", "refresh-prices", "rotate-keys"} {
+		scheduler.Add(name, cron.MustParseCron("@hourly"), record(name), cron.JobOptions{Jitter: 10 * time.Minute})
+	}
+	scheduler.Start(context.Background())
+	defer scheduler.Stop()
+
+	clock.BlockUntil(1)
+	for i := 0; i < 75; i++ {
+		advance(clock, scheduler, time.Minute)
+	}
+	printEntry(scheduler, "cancel-booking-B42")
+}
+
+// testRestart - the scheduler keeps nothing on disk: settlement saves the
+// night it settled, a restarted process hands it back as LastRun and the
+// nights it was down for are caught up (CatchUpAll)
+func testRestart(pool *workerpool.WorkerPool) {
+	fmt.Println("\n--- Restart with LastRun (0 2 * * *, CatchUpAll) ---")
+	var mu sync.Mutex
+	var settled time.Time // stands in for a row in the DB
+
+	run := func(clock *cron.FakeClock, lastRun time.Time, d time.Duration) {
+		scheduler := cron.New(pool, clock)
+		settle := workerpool.TaskFunc[any](func(ctx context.Context) (interface{}, error) {
+			night, _ := cron.ScheduledTime(ctx)
+			fmt.Printf("  💰 settling %s (run at %s)\n", night.Format("Mon Jan 2"), clock.Now().Format("Mon 15:04"))
+			mu.Lock()
+			settled = night
+			mu.Unlock()
+			return nil, nil
+		})
+		scheduler.Add("nightly-settlement", cron.MustParseCron("0 2 * * *"), settle,
+			cron.JobOptions{CatchUp: cron.CatchUpAll, LastRun: lastRun})
+		scheduler.Start(context.Background())
+		defer scheduler.Stop()
+
+		clock.BlockUntil(1)
+		advance(clock, scheduler, d)
+	}
+
+	run(cron.NewFakeClock(start), time.Time{}, 24*time.Hour)
+	fmt.Println("  🔌 process down Sat + Sun, back Mon 09:00")
+	mu.Lock()
+	lastRun := settled
+	mu.Unlock()
+	run(cron.NewFakeClock(start.Add(3*24*time.Hour+9*time.Hour)), lastRun, time.Minute)
+}
+
+// testRemoveWhileRunning - runs queued behind a running one (overlap)
+// are dropped with the job, only the running one finishes
+func testRemoveWhileRunning(pool *workerpool.WorkerPool) {
+	fmt.Println("\n--- Remove while running ---")
+	clock := cron.NewFakeClock(start)
+	scheduler := cron.New(pool, clock)
+
+	var mu sync.Mutex
+	runs := 0
+	slow := make(chan struct{})
+	task := workerpool.TaskFunc[any](func(ctx context.Context) (interface{}, error) {
+		mu.Lock()
+		runs++
+		first := runs == 1
+		mu.Unlock()
+		if first {
+			<-slow
+		}
+		return nil, nil
+	})
+	scheduler.Add("reindex-search", cron.Every(time.Minute), task, cron.JobOptions{})
+	scheduler.Start(context.Background())
+	defer scheduler.Stop()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute) // run 1 starts and hangs
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute) // run 2 queued behind it
+	clock.BlockUntil(1)
+	scheduler.Remove("reindex-search")
+	close(slow)
+	scheduler.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	fmt.Printf("  runs after Remove: %d (want 1), entries left: %d\n", runs, len(scheduler.Entries()))
+}
+
+// testRemoveAndReAdd - a job re-Added under the same name while the
+// removed one is still running waits for it instead of running alongside
+func testRemoveAndReAdd(pool *workerpool.WorkerPool) {
+	fmt.Println("\n--- Remove + Add while running ---")
+	clock := cron.NewFakeClock(start)
+	scheduler := cron.New(pool, clock)
+
+	var mu sync.Mutex
+	runs, active, maxActive := 0, 0, 0
+	slow := make(chan struct{})
+	task := workerpool.TaskFunc[any](func(ctx context.Context) (interface{}, error) {
+		mu.Lock()
+		runs++
+		first := runs == 1
+		active++
+		maxActive = max(maxActive, active)
+		mu.Unlock()
+		if first {
+			<-slow
+		}
+		mu.Lock()
+		active--
+		mu.Unlock()
+		return nil, nil
+	})
+	scheduler.Add("rebuild-sitemap", cron.Every(time.Minute), task, cron.JobOptions{})
+	scheduler.Start(context.Background())
+	defer scheduler.Stop()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute) // old job's run starts and hangs
+	clock.BlockUntil(1)
+	scheduler.Remove("rebuild-sitemap")
+	scheduler.Add("rebuild-sitemap", cron.Every(time.Minute), task, cron.JobOptions{})
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute) // new job due, old run still going
+	clock.BlockUntil(1)
+
+	mu.Lock()
+	waiting := runs
+	mu.Unlock()
+	close(slow)
+	scheduler.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	fmt.Printf("  runs while old one hangs: %d (want 1), runs after: %d (want 2), max concurrent: %d (want 1)\n",
+		waiting, runs, maxActive)
+}
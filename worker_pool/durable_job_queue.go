@@ -0,0 +1,202 @@
+package main
+
+// Demo of worker_pool/jobqueue - jobs survive a "crash" of the process
+// AddStockTask / RemoveStockTask like in ecommerce_with_queue, but
+// serializable: only ProductID + Quantity go to disk, Inventory is
+// injected again when the task is decoded
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"yourname/worker_pool/jobqueue"
+	"yourname/worker_pool/workerpool"
+)
+
+// Inventory - stands in for the database, outlives the "crash"
+type Inventory struct {
+	stock map[string]int
+	mu    sync.Mutex
+}
+
+type AddStockTask struct {
+	ProductID string     `json:"product_id"`
+	Quantity  int        `json:"quantity"`
+	Inventory *Inventory `json:"-"`
+}
+
+func (t *AddStockTask) Execute(ctx context.Context) (interface{}, error) {
+	select {
+	case <-time.After(20 * time.Millisecond):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	t.Inventory.mu.Lock()
+	defer t.Inventory.mu.Unlock()
+	t.Inventory.stock[t.ProductID] += t.Quantity
+	return t.Inventory.stock[t.ProductID], nil
+}
+
+type RemoveStockTask struct {
+	ProductID string     `json:"product_id"`
+	Quantity  int        `json:"quantity"`
+	Inventory *Inventory `json:"-"`
+}
+
+func (t *RemoveStockTask) Execute(ctx context.Context) (interface{}, error) {
+	select {
+	case <-time.After(20 * time.Millisecond):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	t.Inventory.mu.Lock()
+	defer t.Inventory.mu.Unlock()
+	if t.Inventory.stock[t.ProductID] < t.Quantity {
+		return nil, fmt.Errorf("insufficient stock for %s", t.ProductID)
+	}
+	t.Inventory.stock[t.ProductID] -= t.Quantity
+	return t.Inventory.stock[t.ProductID], nil
+}
+
+// ReindexTask - hangs on its first run, fine on the retry
+type ReindexTask struct {
+	Shard int `json:"shard"`
+}
+
+var reindexRuns int32
+
+func (t *ReindexTask) Execute(ctx context.Context) (interface{}, error) {
+	if atomic.AddInt32(&reindexRuns, 1) == 1 {
+		<-ctx.Done() // stuck until the lease expires
+		return nil, ctx.Err()
+	}
+	return fmt.Sprintf("shard %d reindexed", t.Shard), nil
+}
+
+func registerTasks(queue *jobqueue.Queue, inventory *Inventory) {
+	queue.Register("add_stock", func(payload json.RawMessage) (workerpool.Task[any], error) {
+		task := &AddStockTask{Inventory: inventory}
+		return task, json.Unmarshal(payload, task)
+	})
+	queue.Register("remove_stock", func(payload json.RawMessage) (workerpool.Task[any], error) {
+		task := &RemoveStockTask{Inventory: inventory}
+		return task, json.Unmarshal(payload, task)
+	})
+	queue.Register("reindex", func(payload json.RawMessage) (workerpool.Task[any], error) {
+		task := &ReindexTask{}
+		return task, json.Unmarshal(payload, task)
+	})
+}
+
+// waitUntilDone - polls until nothing is queued or running
+func waitUntilDone(queue *jobqueue.Queue, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		stats := queue.Stats()
+		if stats[jobqueue.StateQueued]+stats[jobqueue.StateRunning] == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func main() {
+	fmt.Println("=== Durable Job Queue Demo ===")
+
+	dir, err := os.MkdirTemp("", "jobqueue")
+	if err != nil {
+		fmt.Printf("temp dir: %v\n", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	logPath := filepath.Join(dir, "jobs.log")
+
+	inventory := &Inventory{stock: make(map[string]int)}
+	opts := jobqueue.Options{
+		VisibilityTimeout: 150 * time.Millisecond,
+		MaxAttempts:       3,
+		RetryBackoff:      20 * time.Millisecond,
+		PollInterval:      10 * time.Millisecond,
+	}
+
+	// 1. First process: enqueue, start working, crash half way
+	fmt.Println("\n--- Process 1: enqueue 5 jobs, crash after 45ms ---")
+	queue, err := jobqueue.Open(logPath, opts)
+	if err != nil {
+		fmt.Printf("open: %v\n", err)
+		return
+	}
+	registerTasks(queue, inventory)
+
+	for i := 1; i <= 4; i++ {
+		queue.Enqueue("add_stock", AddStockTask{ProductID: "laptop", Quantity: 10})
+	}
+	queue.Enqueue("remove_stock", RemoveStockTask{ProductID: "laptop", Quantity: 15})
+	_, err = queue.Enqueue("send_email", map[string]string{"to": "a@b.c"})
+	fmt.Printf("Unregistered type: %v\n", err)
+
+	pool := workerpool.NewWorkerPool(2, 10)
+	pool.Start()
+	ctx, crash := context.WithCancel(context.Background())
+	go queue.Run(ctx, pool)
+
+	// jobs take 20ms and are leased only when a worker is idle
+	// => at 45ms jobs 1-2 are done, 3-4 running, 5 still queued
+	time.Sleep(45 * time.Millisecond)
+	crash()
+	queue.Close() // nothing else reaches the log, like kill -9
+	killed, kill := context.WithCancel(context.Background())
+	kill()
+	pool.Shutdown(killed) // running tasks die with the process
+	pool.Wait()
+	inventory.mu.Lock()
+	fmt.Printf("Log at crash: %v, laptop stock=%d\n", queue.Stats(), inventory.stock["laptop"])
+	inventory.mu.Unlock()
+
+	// kill -9 in the middle of a write: half a record at the end of the log
+	if file, err := os.OpenFile(logPath, os.O_WRONLY|os.O_APPEND, 0o644); err == nil {
+		file.WriteString(`{"id":6,"type":"add_st`)
+		file.Close()
+	}
+
+	// 2. Second process: replay log, running jobs re-queued
+	fmt.Println("\n--- Process 2: recover from log ---")
+	queue, err = jobqueue.Open(logPath, opts)
+	if err != nil {
+		fmt.Printf("reopen: %v\n", err)
+		return
+	}
+	registerTasks(queue, inventory)
+	fmt.Printf("Recovered %d running jobs, stats=%v (torn last line cut off)\n", queue.Recovered(), queue.Stats())
+	queue.Enqueue("reindex", ReindexTask{Shard: 7}) // hangs => visibility timeout
+
+	pool = workerpool.NewWorkerPool(2, 10)
+	pool.Start()
+	ctx, stop := context.WithCancel(context.Background())
+	go queue.Run(ctx, pool)
+
+	waitUntilDone(queue, 3*time.Second)
+	stop()
+	pool.Shutdown(context.Background())
+
+	// 3. Every job ran (at least once), the hung one via visibility timeout
+	fmt.Printf("Final stats=%v, laptop stock=%d\n", queue.Stats(), inventory.stock["laptop"])
+	for id := int64(1); id <= 6; id++ {
+		rec, _ := queue.Get(id)
+		fmt.Printf("  job %d %-12s %-9s attempts=%d %s\n", rec.ID, rec.Type, rec.State, rec.Attempts, rec.LastError)
+	}
+
+	if err := queue.Compact(); err != nil {
+		fmt.Printf("compact: %v\n", err)
+	}
+	fmt.Printf("After compaction: %v\n", queue.Stats())
+	queue.Close()
+
+	fmt.Println("\n=== Demo Complete ===")
+}
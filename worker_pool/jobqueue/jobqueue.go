@@ -0,0 +1,554 @@
+// Package jobqueue - durable job queue in front of workerpool
+//
+// workerpool keeps queued jobs in memory => process restart loses them.
+// Here every job is first written to an append-only file (one JSON line
+// per state change), THEN handed to the pool:
+//
+//	Enqueue ──> queued ──lease──> running ──> succeeded
+//	              ^                  │
+//	              └── retry/timeout ─┴──────> failed (MaxAttempts reached)
+//
+// On top of that:
+//   - VISIBILITY TIMEOUT: a running job holds a lease. If it doesn't finish
+//     before the lease expires (hung task, lost worker) it becomes
+//     visible again and is retried. Its ctx is cancelled at the same time.
+//   - CRASH RECOVERY: Open replays the log, jobs still "running" belonged
+//     to the dead process => re-queued immediately.
+//   - Tasks are stored as type name + JSON payload, Register tells the
+//     queue how to turn them back into a workerpool.Task[any].
+//
+// Delivery is AT LEAST ONCE: a job that finished right before a crash
+// (but whose "succeeded" line wasn't written) runs again => tasks
+// should be idempotent.
+//
+// Usage:
+//
+//	queue, err := jobqueue.Open("jobs.log", jobqueue.Options{})
+//	queue.Register("add_stock", decodeAddStock)
+//	queue.Enqueue("add_stock", AddStockTask{ProductID: "laptop", Quantity: 5})
+//	go queue.Run(ctx, pool)
+package jobqueue
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"yourname/worker_pool/workerpool"
+)
+
+var (
+	ErrUnknownTaskType = errors.New("task type not registered")
+	ErrQueueClosed     = errors.New("job queue is closed")
+)
+
+type State string
+
+const (
+	StateQueued    State = "queued"
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+)
+
+// JobRecord - one line in the log, last line per ID wins on replay
+type JobRecord struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	State     State           `json:"state"`
+	Attempts  int             `json:"attempts"`
+	VisibleAt time.Time       `json:"visible_at"` // queued: not before; running: lease expiry
+	LastError string          `json:"last_error,omitempty"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// Decoder - payload back into a runnable task
+// (also the place to inject dependencies like *Inventory)
+type Decoder func(payload json.RawMessage) (workerpool.Task[any], error)
+
+type Options struct {
+	VisibilityTimeout time.Duration // default 30s
+	MaxAttempts       int           // default 3
+	RetryBackoff      time.Duration // attempt n waits n*RetryBackoff (default 1s)
+	PollInterval      time.Duration // default 50ms
+}
+
+type Queue struct {
+	opts Options
+	path string
+	file *os.File
+
+	jobs      map[int64]*JobRecord
+	order     []int64 // IDs in enqueue order => FIFO leasing
+	decoders  map[string]Decoder
+	nextID    int64
+	recovered int
+	closed    bool
+	writeErr  error // first failed log write of lease / complete / expiry, Run returns it
+
+	mu sync.Mutex
+}
+
+// Open - creates the log or replays an existing one
+func Open(path string, opts Options) (*Queue, error) {
+	if opts.VisibilityTimeout <= 0 {
+		opts.VisibilityTimeout = 30 * time.Second
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = time.Second
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 50 * time.Millisecond
+	}
+
+	q := &Queue{
+		opts:     opts,
+		path:     path,
+		jobs:     make(map[int64]*JobRecord),
+		decoders: make(map[string]Decoder),
+	}
+	if err := q.replay(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open job log: %w", err)
+	}
+	q.file = file
+
+	// Whoever was running these is gone
+	now := time.Now()
+	for _, id := range q.order {
+		rec := q.jobs[id]
+		if rec.State != StateRunning {
+			continue
+		}
+		rec.State = StateQueued
+		rec.VisibleAt = now
+		rec.LastError = "re-queued after crash"
+		if err := q.persist(rec); err != nil {
+			file.Close()
+			return nil, err
+		}
+		q.recovered++
+	}
+	return q, nil
+}
+
+// replay - loads the log; a torn last line (crash mid-write) is cut off
+// the file, otherwise the next append would glue onto it and turn it
+// into corruption in the middle of the log
+func (q *Queue) replay() error {
+	file, err := os.Open(q.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open job log: %w", err)
+	}
+
+	reader := bufio.NewReader(file)
+	var good int64 // offset right after the last complete record
+	var torn error
+	for line := 1; ; line++ {
+		data, readErr := reader.ReadBytes('\n')
+		if len(data) > 0 {
+			if torn != nil {
+				// bad line in the MIDDLE of the log => real corruption
+				file.Close()
+				return torn
+			}
+			var rec JobRecord
+			err := json.Unmarshal(data, &rec)
+			if err == nil && data[len(data)-1] != '\n' {
+				err = io.ErrUnexpectedEOF // persist writes record + newline at once
+			}
+			if err != nil {
+				// only OK if it's the last line (write cut short by the crash)
+				torn = fmt.Errorf("job log line %d: %w", line, err)
+				continue
+			}
+			good += int64(len(data))
+			if _, seen := q.jobs[rec.ID]; !seen {
+				q.order = append(q.order, rec.ID)
+			}
+			q.jobs[rec.ID] = &rec
+			if rec.ID > q.nextID {
+				q.nextID = rec.ID
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			file.Close()
+			return fmt.Errorf("read job log: %w", readErr)
+		}
+	}
+	file.Close()
+
+	if torn != nil {
+		if err := os.Truncate(q.path, good); err != nil {
+			return fmt.Errorf("truncate torn job log: %w", err)
+		}
+	}
+	return nil
+}
+
+// persist - caller holds mu
+// fsync per line: a job the caller was told "enqueued" survives a crash
+func (q *Queue) persist(rec *JobRecord) error {
+	rec.UpdatedAt = time.Now()
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := q.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write job log: %w", err)
+	}
+	return q.file.Sync()
+}
+
+// update - change rec only if the change reached the log: memory never
+// runs ahead of disk, or a replay after a crash would disagree with what
+// this process did. On failure rec is untouched and the error is kept
+// for Run; caller holds mu
+func (q *Queue) update(rec *JobRecord, change func(rec *JobRecord)) error {
+	next := *rec
+	change(&next)
+	if err := q.persist(&next); err != nil {
+		if q.writeErr == nil {
+			q.writeErr = err
+		}
+		return err
+	}
+	*rec = next
+	return nil
+}
+
+// Register - must be called for every type before Enqueue / Run
+func (q *Queue) Register(typeName string, decode Decoder) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.decoders[typeName] = decode
+}
+
+// Enqueue - task is stored as JSON, returns once it's on disk
+func (q *Queue) Enqueue(typeName string, task any) (int64, error) {
+	payload, err := json.Marshal(task)
+	if err != nil {
+		return 0, fmt.Errorf("encode %s task: %w", typeName, err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return 0, ErrQueueClosed
+	}
+	// refuse what we couldn't decode after a restart
+	if _, ok := q.decoders[typeName]; !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownTaskType, typeName)
+	}
+
+	q.nextID++
+	rec := &JobRecord{
+		ID:        q.nextID,
+		Type:      typeName,
+		Payload:   payload,
+		State:     StateQueued,
+		VisibleAt: time.Now(),
+	}
+	if err := q.persist(rec); err != nil {
+		q.nextID--
+		return 0, err
+	}
+	q.jobs[rec.ID] = rec
+	q.order = append(q.order, rec.ID)
+	return rec.ID, nil
+}
+
+// Run - leases visible jobs into pool until ctx is done
+// Only as many jobs as the pool has idle workers are leased per tick: a
+// lease handed out while the job still waits for a worker would expire
+// (and burn an attempt) before the job ever ran.
+// Jobs still running when Run returns finish normally; if the process
+// dies first, the next Open re-queues them
+// Returns ctx.Err(), or the first state change that couldn't be logged
+func (q *Queue) Run(ctx context.Context, pool *workerpool.WorkerPool) error {
+	ticker := time.NewTicker(q.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		q.expireLeases(time.Now())
+		for _, lease := range q.leaseVisible(time.Now(), idleWorkers(pool)) {
+			q.dispatch(pool, lease)
+		}
+		// the log can't be written (disk full, file gone): stop handing
+		// out work, every job is still in the state the log says
+		if err := q.writeError(); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+type lease struct {
+	id       int64
+	attempt  int
+	task     workerpool.Task[any]
+	deadline time.Time
+}
+
+// idleWorkers - workers with nothing to do, other users' queued jobs
+// (shared pool) included
+func idleWorkers(pool *workerpool.WorkerPool) int {
+	m := pool.Metrics()
+	return max(m.Workers-m.Busy-m.Queued, 0)
+}
+
+// leaseVisible - marks up to limit visible queued jobs as running
+func (q *Queue) leaseVisible(now time.Time, limit int) []lease {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil
+	}
+	var leases []lease
+	for _, id := range q.order {
+		if len(leases) >= limit {
+			break
+		}
+		rec := q.jobs[id]
+		if rec.State != StateQueued || rec.VisibleAt.After(now) {
+			continue
+		}
+
+		decode, ok := q.decoders[rec.Type]
+		var task workerpool.Task[any]
+		var err error
+		if !ok {
+			err = fmt.Errorf("%w: %s", ErrUnknownTaskType, rec.Type)
+		} else {
+			task, err = decode(rec.Payload)
+		}
+		if err != nil {
+			// retrying won't make the payload decodable
+			// (not logged => still queued, Run stops on the write error)
+			q.update(rec, func(rec *JobRecord) {
+				rec.State = StateFailed
+				rec.LastError = err.Error()
+			})
+			continue
+		}
+
+		err = q.update(rec, func(rec *JobRecord) {
+			rec.State = StateRunning
+			rec.Attempts++
+			rec.VisibleAt = now.Add(q.opts.VisibilityTimeout)
+		})
+		if err != nil {
+			continue
+		}
+		leases = append(leases, lease{id: rec.ID, attempt: rec.Attempts, task: task, deadline: rec.VisibleAt})
+	}
+	return leases
+}
+
+func (q *Queue) dispatch(pool *workerpool.WorkerPool, l lease) {
+	// Job ctx ends with the lease: once someone else may retry the job,
+	// this attempt should stop. Submit waits for pool space at most
+	// that long too.
+	leaseCtx, cancel := context.WithDeadline(context.Background(), l.deadline)
+
+	future, err := pool.Submit(leaseCtx, l.task)
+	if err != nil {
+		// stays "running" until the lease expires, then retried
+		cancel()
+		return
+	}
+
+	go func() {
+		defer cancel()
+		result := future.Result()
+		q.complete(l.id, l.attempt, result.Err)
+	}()
+}
+
+// complete - ignores results of attempts whose lease was already taken over
+func (q *Queue) complete(id int64, attempt int, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	rec := q.jobs[id]
+	if q.closed || rec.State != StateRunning || rec.Attempts != attempt {
+		return
+	}
+	// not logged => stays running, the lease expires and the job runs
+	// again (at least once), Run stops on the write error
+	q.update(rec, func(rec *JobRecord) {
+		if err == nil {
+			rec.State = StateSucceeded
+			rec.LastError = ""
+		} else {
+			q.retryOrFail(rec, err.Error(), time.Now())
+		}
+	})
+}
+
+// expireLeases - visibility timeout: running too long => visible again
+func (q *Queue) expireLeases(now time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return
+	}
+	for _, id := range q.order {
+		rec := q.jobs[id]
+		if rec.State != StateRunning || rec.VisibleAt.After(now) {
+			continue
+		}
+		// not logged => still running, next tick expires it again
+		q.update(rec, func(rec *JobRecord) {
+			q.retryOrFail(rec, "visibility timeout expired", now)
+		})
+	}
+}
+
+func (q *Queue) writeError() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.writeErr
+}
+
+// retryOrFail - caller holds mu
+func (q *Queue) retryOrFail(rec *JobRecord, reason string, now time.Time) {
+	rec.LastError = reason
+	if rec.Attempts >= q.opts.MaxAttempts {
+		rec.State = StateFailed
+		return
+	}
+	rec.State = StateQueued
+	rec.VisibleAt = now.Add(time.Duration(rec.Attempts) * q.opts.RetryBackoff)
+}
+
+// Get - current record of one job
+func (q *Queue) Get(id int64) (JobRecord, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	rec, ok := q.jobs[id]
+	if !ok {
+		return JobRecord{}, false
+	}
+	return *rec, true
+}
+
+// Stats - number of jobs per state
+func (q *Queue) Stats() map[State]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stats := make(map[State]int)
+	for _, rec := range q.jobs {
+		stats[rec.State]++
+	}
+	return stats
+}
+
+// Recovered - jobs found "running" by Open (left over from a crash)
+func (q *Queue) Recovered() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.recovered
+}
+
+// Compact - rewrites the log with one line per job and drops
+// succeeded jobs, the log otherwise grows with every state change
+func (q *Queue) Compact() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	tmpPath := q.path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("compact job log: %w", err)
+	}
+	writer := bufio.NewWriter(tmp)
+	var kept []int64
+	for _, id := range q.order {
+		rec := q.jobs[id]
+		// newest job always kept => replay restores nextID, IDs never reused
+		if rec.State == StateSucceeded && id != q.nextID {
+			continue
+		}
+		line, err := json.Marshal(rec)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		writer.Write(append(line, '\n'))
+		kept = append(kept, id)
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("compact job log: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("compact job log: %w", err)
+	}
+	tmp.Close()
+
+	// rename is atomic: a crash leaves either the old or the new log
+	if err := os.Rename(tmpPath, q.path); err != nil {
+		return fmt.Errorf("compact job log: %w", err)
+	}
+	file, err := os.OpenFile(q.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("compact job log: %w", err)
+	}
+	q.file.Close()
+	q.file = file
+
+	for _, id := range q.order {
+		if q.jobs[id].State == StateSucceeded && id != q.nextID {
+			delete(q.jobs, id)
+		}
+	}
+	q.order = kept
+	return nil
+}
+
+// Close - stops writing; results arriving later are dropped and
+// their jobs are re-queued by the next Open
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	q.closed = true
+	return q.file.Close()
+}
@@ -1,25 +1,28 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"sync"
 	"time"
+
+	"yourname/worker_pool/workerpool"
 )
 
-type Task interface {
-	Execute() (interface{}, error)
-}
+// Task / Job / Result / WorkerPool used to be defined right here
+// (Execute() (interface{}, error) + a shared ResultsChannel).
+// Same demo now runs on the shared generic pool: SquareTask is a
+// workerpool.Task[int], so results come back as int, no .(int) needed
 
 type SquareTask struct {
 	Number int
 }
 
-func (t SquareTask) Execute() (interface{}, error) {
+func (t SquareTask) Execute(ctx context.Context) (int, error) {
 	// Simulate some work
 	time.Sleep(100 * time.Millisecond)
 	// Simulate random failures
 	if t.Number%7 == 0 {
-		return nil, fmt.Errorf("unlucky number %d", t.Number)
+		return 0, fmt.Errorf("unlucky number %d", t.Number)
 	}
 
 	// Calculate square
@@ -27,129 +30,51 @@ func (t SquareTask) Execute() (interface{}, error) {
 	return result, nil
 }
 
-type Job struct {
-	ID       int
-	Task     Task
-	Priority int // optional (for later advanced features)
-	Attempts int // how many times job was tried
-}
-
-type Result struct {
-	JobID  int
-	Err    error
-	Output interface{}
-}
-
-type WorkerPool struct {
-	NoOfWorkers    int
-	JobsChannel    chan Job
-	ResultsChannel chan Result
-	wg             sync.WaitGroup
-}
-
-func NewWorkerPool(numWorkers int, jobQueuesize int) *WorkerPool {
-	return &WorkerPool{
-		NoOfWorkers:    numWorkers,
-		JobsChannel:    make(chan Job, jobQueuesize),
-		ResultsChannel: make(chan Result, jobQueuesize),
-	}
-}
-
-func (w *WorkerPool) Start() {
-	for i := 1; i <= w.NoOfWorkers; i++ {
-		go w.worker(i) // Each worker runs in its own goroutine
-	}
-}
-func (w *WorkerPool) worker(workerID int) {
-	// Loop forever, processing jobs
-	for job := range w.JobsChannel { // Blocks until job arrives
-		//Execute the task
-		output, err := job.Task.Execute()
-
-		//Always send result(even if error occured, so
-		//that it does not block other workers)
-		w.ResultsChannel <- Result{
-			JobID:  job.ID,
-			Err:    err,
-			Output: output,
-		}
-		w.wg.Done()
-
-		// Optional: log
-		if err != nil {
-			fmt.Printf("Worker %d: Job %d failed: %v\n", workerID, job.ID, err)
-		} else {
-			fmt.Printf("Worker %d: Job %d completed\n", workerID, job.ID)
-		}
-	}
-}
-
-// Submit job to queue
-func (w *WorkerPool) SubmitJob(job Job) error {
-	//just send job  to channel
-	select {
-	case w.JobsChannel <- job:
-		w.wg.Add(1)
-		return nil
-	default:
-		return fmt.Errorf("job queue is full or pool is shut down")
-	}
-}
-
-func (w *WorkerPool) Wait() {
-	w.wg.Wait()
-}
-
-// Shutdown gracefully
-func (wp *WorkerPool) Shutdown() {
-	close(wp.JobsChannel)
-}
-
 func main() {
-	//this will actually send all jobs to queue right? or maybe some diff process
-	fmt.Println("=== Worker Pool Demo ===\n")
+	fmt.Println("=== Worker Pool Demo ===")
 
-	// 1. Create pool with 3 workers and queue size 10
-	pool := NewWorkerPool(10, 100)
+	// 1. Create typed pool with 10 workers and queue size 100
+	pool := workerpool.NewPool[int](10, 100)
 
-	// 2. Start a goroutine to collect results
-	go func() {
-		for result := range pool.ResultsChannel {
-			if result.Err != nil {
-				fmt.Printf("❌ Job %d failed: %v\n", result.JobID, result.Err)
-			} else {
-				fmt.Printf("✅ Job %d result: %v\n", result.JobID, result.Output)
-			}
-		}
-	}()
-	// 3. Start the worker pool
+	// 2. Start the worker pool
 	pool.Start()
-	fmt.Println("Worker pool started with 3 workers\n")
+	fmt.Println("Worker pool started with 10 workers")
 
-	// 4. Submit 10 jobs
-	fmt.Println("Submitting 10 jobs...")
+	// 3. Submit 100 jobs, each gets its own future (no shared ResultsChannel)
+	fmt.Println("Submitting 100 jobs...")
+	var futures []*workerpool.Future[int]
 	for i := 1; i <= 100; i++ {
-		job := Job{
-			ID:   i,
-			Task: SquareTask{Number: i},
+		future, err := pool.Submit(context.Background(), SquareTask{Number: i})
+		if err != nil {
+			fmt.Printf("Failed to submit job %d: %v\n", i, err)
+			continue
 		}
+		futures = append(futures, future)
+	}
+	fmt.Println("All jobs submitted!")
 
-		err := pool.SubmitJob(job)
+	// 4. Collect results (typed: square is an int)
+	sum := 0
+	for _, future := range futures {
+		square, err := future.Get()
 		if err != nil {
-			fmt.Printf("Failed to submit job %d: %v\n", i, err)
+			fmt.Printf("❌ Job %d failed: %v\n", future.JobID(), err)
+			continue
 		}
+		sum += square
 	}
-	fmt.Println("All jobs submitted!\n")
+	fmt.Printf("✅ Sum of successful squares: %d\n", sum)
 
-	pool.Wait()
+	// 5. Same thing in one call: Map keeps input order, joins all errors
+	numbers := []int{1, 2, 3, 7, 14}
+	squares, err := workerpool.Map(context.Background(), pool.WorkerPool, numbers, func(ctx context.Context, n int) (int, error) {
+		return SquareTask{Number: n}.Execute(ctx)
+	})
+	fmt.Printf("Map(%v) = %v\nerrors:\n%v\n", numbers, squares, err)
 
-	// 6. Shutdown the pool
+	// 6. Shutdown the pool (drains everything still queued)
 	fmt.Println("\nShutting down pool...")
-	pool.Shutdown()
-
-	// 7. Give time for final results to print
-	time.Sleep(500 * time.Millisecond)
+	pool.Shutdown(context.Background())
 
 	fmt.Println("\n=== Demo Complete ===")
-
 }
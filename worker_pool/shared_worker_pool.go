@@ -0,0 +1,329 @@
+package main
+
+// Demo of the shared worker pool package (worker_pool/workerpool)
+// Tour of the features: futures, panics, cancellation, resize, shutdown,
+// typed Map / ForEach, priorities, autoscaling, tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"yourname/observability/tracing"
+	"yourname/worker_pool/workerpool"
+)
+
+type SquareTask struct {
+	Number int
+}
+
+// Execute - SquareTask is a Task[int], callers get an int back
+func (t SquareTask) Execute(ctx context.Context) (int, error) {
+	select {
+	case <-time.After(100 * time.Millisecond): // Simulate some work
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+
+	if t.Number%7 == 0 {
+		panic(fmt.Sprintf("unlucky number %d", t.Number)) // used to kill the process
+	}
+	return t.Number * t.Number, nil
+}
+
+func main() {
+	fmt.Println("=== Shared Worker Pool Demo ===")
+
+	pool := workerpool.NewPool[int](3, 5)
+	pool.SubmitTimeout = 2 * time.Second
+	pool.Start()
+
+	// 1. Futures + panic recovery
+	fmt.Println("\n--- Futures & panic recovery ---")
+	var futures []*workerpool.Future[int]
+	for i := 1; i <= 10; i++ {
+		// Queue holds only 5: Submit blocks instead of failing
+		future, err := pool.Submit(context.Background(), SquareTask{Number: i})
+		if err != nil {
+			fmt.Printf("Submit %d failed: %v\n", i, err)
+			continue
+		}
+		futures = append(futures, future)
+	}
+	for _, future := range futures {
+		result := future.Result()
+		if errors.Is(result.Err, workerpool.ErrTaskPanicked) {
+			fmt.Printf("⚠️  Job %d panicked, pool still alive: %v\n", result.JobID, result.Err)
+		} else {
+			fmt.Printf("✅ Job %d result: %v\n", result.JobID, result.Output)
+		}
+	}
+
+	// 2. Per-job cancellation
+	fmt.Println("\n--- Per-job cancellation ---")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	future, _ := pool.Submit(ctx, SquareTask{Number: 4})
+	fmt.Printf("Job %d: %v\n", future.JobID(), future.Result().Err)
+	cancel()
+
+	// 3. Resize at runtime
+	fmt.Println("\n--- Resize ---")
+	pool.Resize(6)
+	start := time.Now()
+	for i := 1; i <= 6; i++ {
+		pool.Submit(context.Background(), SquareTask{Number: i})
+	}
+	pool.Wait()
+	fmt.Printf("6 jobs on %d workers took %v (~100ms)\n", pool.Size(), time.Since(start).Round(10*time.Millisecond))
+	pool.Resize(2)
+	fmt.Printf("Shrunk to %d workers\n", pool.Size())
+
+	// 4. Graceful shutdown drains queue
+	fmt.Println("\n--- Shutdown ---")
+	for i := 1; i <= 4; i++ {
+		futures[i-1], _ = pool.Submit(context.Background(), SquareTask{Number: i + 14})
+	}
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelShutdown()
+	fmt.Printf("Shutdown: err=%v\n", pool.Shutdown(shutdownCtx))
+	for _, future := range futures[:4] {
+		fmt.Printf("Drained job %d: %v\n", future.JobID(), future.Result().Output)
+	}
+
+	_, err := pool.Submit(context.Background(), SquareTask{Number: 1})
+	fmt.Printf("Submit after shutdown: %v\n", err)
+
+	// 5. Optional results stream - nobody has to drain it for workers to progress
+	fmt.Println("\n--- Results stream ---")
+	streamPool := workerpool.NewPool[int](2, 10)
+	results := streamPool.EnableResultStream()
+	streamPool.Start()
+	for i := 1; i <= 3; i++ {
+		streamPool.Submit(context.Background(), SquareTask{Number: i})
+	}
+	streamPool.Shutdown(context.Background())
+	for result := range results {
+		fmt.Printf("Streamed job %d: %v\n", result.JobID, result.Output)
+	}
+
+	testMapAndForEach()
+	testPriorityAndFairness()
+	testAutoscaling()
+	benchmarkFixedVsAutoscaling()
+	testTracing()
+
+	fmt.Println("\n=== Demo Complete ===")
+}
+
+// testMapAndForEach - bulk submit, typed results in input order,
+// every failure reported at once via errors.Join
+func testMapAndForEach() {
+	fmt.Println("\n--- Map & ForEach ---")
+	pool := workerpool.NewWorkerPool(4, 10)
+	pool.Start()
+	defer pool.Shutdown(context.Background())
+
+	squares, err := workerpool.Map(context.Background(), pool, []int{1, 2, 3, 4, 5}, func(ctx context.Context, n int) (int, error) {
+		return SquareTask{Number: n}.Execute(ctx)
+	})
+	fmt.Printf("Map squares: %v err=%v\n", squares, err)
+
+	lengths, err := workerpool.Map(context.Background(), pool, []string{"laptop", "", "phone", ""}, func(ctx context.Context, sku string) (int, error) {
+		if sku == "" {
+			return 0, errors.New("empty SKU")
+		}
+		return len(sku), nil
+	})
+	fmt.Printf("Map lengths: %v\nerr:\n%v\n", lengths, err)
+
+	var mu sync.Mutex
+	restocked := 0
+	err = workerpool.ForEach(context.Background(), pool, []string{"laptop", "phone", "mouse"}, func(ctx context.Context, sku string) error {
+		mu.Lock()
+		restocked++
+		mu.Unlock()
+		return nil
+	})
+	fmt.Printf("ForEach restocked %d products, err=%v\n", restocked, err)
+}
+
+// testPriorityAndFairness - 1 worker so the pick order is visible
+// noisy tenant floods low priority jobs, quiet tenant sends a few,
+// a latency-sensitive check arrives last but runs first
+func testPriorityAndFairness() {
+	fmt.Println("\n--- Priority, deadline & fairness ---")
+	pool := workerpool.NewWorkerPool(1, 50)
+	pool.Start()
+
+	var order []string
+	var mu sync.Mutex
+	record := func(name string) workerpool.Task[any] {
+		return workerpool.TaskFunc[any](func(ctx context.Context) (interface{}, error) {
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return nil, nil
+		})
+	}
+
+	// Keep the single worker busy while the queue fills up
+	pool.Submit(context.Background(), workerpool.TaskFunc[any](func(ctx context.Context) (interface{}, error) {
+		time.Sleep(50 * time.Millisecond)
+		return nil, nil
+	}))
+
+	for i := 1; i <= 4; i++ {
+		pool.SubmitJob(context.Background(), workerpool.Job[any]{Task: record(fmt.Sprintf("noisy-add-%d", i)), Tenant: "noisy"})
+	}
+	for i := 1; i <= 2; i++ {
+		pool.SubmitJob(context.Background(), workerpool.Job[any]{Task: record(fmt.Sprintf("quiet-add-%d", i)), Tenant: "quiet"})
+	}
+	expiring, _ := pool.SubmitJob(context.Background(), workerpool.Job[any]{
+		Task:     record("expired"),
+		Deadline: time.Now().Add(20 * time.Millisecond), // can't start in time
+	})
+	pool.SubmitJob(context.Background(), workerpool.Job[any]{Task: record("check-stock"), Priority: 10, Tenant: "quiet"})
+
+	pool.Wait()
+	fmt.Printf("Run order: %v\n", order)
+	fmt.Printf("Expired job %d: %v\n", expiring.JobID(), expiring.Result().Err)
+	pool.Shutdown(context.Background())
+}
+
+// testAutoscaling - burst of work grows the pool, idle period shrinks it
+func testAutoscaling() {
+	fmt.Println("\n--- Autoscaling ---")
+	pool := workerpool.NewAutoscalingWorkerPool(workerpool.AutoscaleConfig{
+		MinWorkers:          1,
+		MaxWorkers:          8,
+		QueueDepthPerWorker: 2,
+		P95Latency:          200 * time.Millisecond,
+		ScaleUpStep:         2,
+		IdleTimeout:         100 * time.Millisecond,
+		Interval:            20 * time.Millisecond,
+		OnScale: func(from, to int, reason string) {
+			fmt.Printf("  ⚖️  %d -> %d workers (%s)\n", from, to, reason)
+		},
+	}, 100)
+	pool.Start()
+
+	for i := 1; i <= 40; i++ {
+		pool.Submit(context.Background(), workerpool.TaskFunc[any](func(ctx context.Context) (interface{}, error) {
+			time.Sleep(50 * time.Millisecond)
+			return nil, nil
+		}))
+	}
+	pool.Wait()
+	m := pool.Metrics()
+	fmt.Printf("After burst: workers=%d completed=%d p95=%v scaleUps=%d\n",
+		m.Workers, m.Completed, m.P95Latency.Round(time.Millisecond), m.ScaleUps)
+
+	time.Sleep(time.Second) // idle => shrink back towards MinWorkers
+	m = pool.Metrics()
+	fmt.Printf("After idle:  workers=%d scaleDowns=%d\n", m.Workers, m.ScaleDowns)
+	pool.Shutdown(context.Background())
+}
+
+// benchmarkFixedVsAutoscaling - same bursty load (like the ecommerce
+// testHighLoadWithWorkerPool runs) through a small fixed pool, a big fixed
+// pool and an autoscaling pool
+func benchmarkFixedVsAutoscaling() {
+	fmt.Println("\n--- Benchmark: fixed vs autoscaling ---")
+
+	newFixed := func(n int) func() *workerpool.WorkerPool {
+		return func() *workerpool.WorkerPool { return workerpool.NewWorkerPool(n, 1000) }
+	}
+	pools := []struct {
+		name string
+		new  func() *workerpool.WorkerPool
+	}{
+		{"fixed 4    ", newFixed(4)},
+		{"fixed 64   ", newFixed(64)},
+		{"auto 4..64 ", func() *workerpool.WorkerPool {
+			return workerpool.NewAutoscalingWorkerPool(workerpool.AutoscaleConfig{
+				MinWorkers:          4,
+				MaxWorkers:          64,
+				QueueDepthPerWorker: 4,
+				P95Latency:          100 * time.Millisecond,
+				ScaleUpStep:         8,
+				IdleTimeout:         200 * time.Millisecond,
+				Interval:            10 * time.Millisecond,
+			}, 1000)
+		}},
+	}
+
+	for _, p := range pools {
+		pool := p.new()
+		pool.Start()
+
+		var peakWorkers int
+		job := workerpool.TaskFunc[any](func(ctx context.Context) (interface{}, error) {
+			time.Sleep(10 * time.Millisecond) // I/O bound: DB / HTTP call
+			return nil, nil
+		})
+
+		start := time.Now()
+		for burst := 0; burst < 3; burst++ {
+			for i := 0; i < 300; i++ {
+				pool.Submit(context.Background(), job)
+			}
+			for pool.QueueLen() > 0 {
+				if size := pool.Size(); size > peakWorkers {
+					peakWorkers = size
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+			pool.Wait()
+			time.Sleep(100 * time.Millisecond) // quiet period between bursts
+		}
+		elapsed := time.Since(start)
+
+		m := pool.Metrics()
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		fmt.Printf("%s: %v, p95=%v, peak workers=%d, end workers=%d, goroutines=%d, Alloc=%v MB\n",
+			p.name, elapsed.Round(time.Millisecond), m.P95Latency.Round(time.Millisecond),
+			peakWorkers, m.Workers, runtime.NumGoroutine(), mem.Alloc/1024/1024)
+		pool.Shutdown(context.Background())
+	}
+}
+
+// testTracing - one request span, three jobs under it: the failing one
+// shows its error on the execute span instead of a bare printf line
+// `go run shared_worker_pool.go > /dev/null` and look at the file to see
+// the raw OTLP JSON
+func testTracing() {
+	fmt.Println("\n--- Tracing ---")
+	traceFile := filepath.Join(os.TempDir(), "workerpool-traces.jsonl")
+	exporter, err := tracing.NewOTLPFileExporter(traceFile)
+	if err != nil {
+		fmt.Printf("Cannot open trace file: %v\n", err)
+		return
+	}
+	defer exporter.Close()
+	recorder := tracing.NewRecorder()
+	tracer := tracing.NewTracer("shared-worker-pool-demo", exporter, recorder)
+
+	pool := workerpool.NewPool[int](2, 10)
+	pool.SetTracer(tracer)
+	pool.Start()
+
+	ctx, request := tracer.Start(context.Background(), "RestockRequest")
+	squares, err := workerpool.Map(ctx, pool.WorkerPool, []int{3, 7, 5}, func(ctx context.Context, n int) (int, error) {
+		return SquareTask{Number: n}.Execute(ctx)
+	})
+	request.RecordError(err)
+	request.End()
+	pool.Shutdown(context.Background())
+	tracer.Flush()
+
+	fmt.Printf("Map squares: %v\n", squares)
+	fmt.Print(tracing.FormatTree(recorder.Spans(), request.SpanContext().TraceID))
+	fmt.Printf("OTLP JSON appended to %s\n", traceFile)
+}
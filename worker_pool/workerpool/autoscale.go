@@ -0,0 +1,139 @@
+package workerpool
+
+import (
+	"fmt"
+	"time"
+)
+
+// ============================================
+// AUTOSCALING
+// NewWorkerPool(n, q) => n is a guess. Too few: queue grows, latency
+// explodes. Too many: idle goroutines, contention on shared resources.
+//
+// Autoscaling mode starts at MinWorkers and every Interval looks at:
+//
+//	queued jobs >= QueueDepthPerWorker * workers  => grow by ScaleUpStep
+//	p95 latency >= P95Latency (and jobs queued)   => grow by ScaleUpStep
+//	idle workers + empty queue for IdleTimeout    => shrink by 1
+//
+// never below MinWorkers, never above MaxWorkers.
+// Latency alone doesn't grow the pool: if nothing is queued, jobs are
+// just slow and more workers wouldn't make them faster.
+// ============================================
+
+type AutoscaleConfig struct {
+	MinWorkers int
+	MaxWorkers int
+
+	QueueDepthPerWorker int           // 0 = don't scale on queue depth
+	P95Latency          time.Duration // 0 = don't scale on latency
+	ScaleUpStep         int           // workers added per scale up (default 1)
+
+	IdleTimeout time.Duration // default 5s
+	Interval    time.Duration // evaluation period (default 100ms)
+
+	LatencyWindow int // jobs used for p95 (default 200)
+
+	// OnScale - called after every resize (logging / alerts)
+	OnScale func(from, to int, reason string)
+}
+
+type autoscaler struct {
+	cfg       AutoscaleConfig
+	idleSince time.Time // zero = pool currently not idle
+}
+
+// NewAutoscalingWorkerPool - like NewWorkerPool, but the number of
+// workers follows the load between cfg.MinWorkers and cfg.MaxWorkers
+func NewAutoscalingWorkerPool(cfg AutoscaleConfig, jobQueueSize int) *WorkerPool {
+	if cfg.MinWorkers < 1 {
+		cfg.MinWorkers = 1
+	}
+	if cfg.MaxWorkers < cfg.MinWorkers {
+		cfg.MaxWorkers = cfg.MinWorkers
+	}
+	if cfg.ScaleUpStep < 1 {
+		cfg.ScaleUpStep = 1
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 5 * time.Second
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 100 * time.Millisecond
+	}
+
+	w := NewWorkerPool(cfg.MinWorkers, jobQueueSize)
+	w.stats = newPoolStats(cfg.LatencyWindow)
+	w.autoscale = &autoscaler{cfg: cfg}
+	return w
+}
+
+func (a *autoscaler) run(w *WorkerPool) {
+	ticker := time.NewTicker(a.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.closing:
+			return
+		case now := <-ticker.C:
+			a.evaluate(w, now)
+		}
+	}
+}
+
+func (a *autoscaler) evaluate(w *WorkerPool, now time.Time) {
+	m := w.Metrics()
+	target, reason := a.scaleUpTarget(m)
+
+	if target == m.Workers {
+		if m.Queued > 0 || m.Busy >= m.Workers {
+			a.idleSince = time.Time{}
+			return
+		}
+		if a.idleSince.IsZero() {
+			a.idleSince = now
+			return
+		}
+		if now.Sub(a.idleSince) < a.cfg.IdleTimeout || m.Workers <= a.cfg.MinWorkers {
+			return
+		}
+		target = m.Workers - 1
+		reason = fmt.Sprintf("idle for %v", now.Sub(a.idleSince).Round(time.Millisecond))
+		a.idleSince = now // next shrink needs another full IdleTimeout
+	} else {
+		a.idleSince = time.Time{}
+	}
+
+	if err := w.Resize(target); err != nil {
+		return // pool shutting down
+	}
+	w.stats.scaled(target > m.Workers)
+	w.stats.resetLatencies()
+	if a.cfg.OnScale != nil {
+		a.cfg.OnScale(m.Workers, target, reason)
+	}
+}
+
+// scaleUpTarget - returns m.Workers if no need to grow
+func (a *autoscaler) scaleUpTarget(m Metrics) (int, string) {
+	if m.Queued == 0 || m.Workers >= a.cfg.MaxWorkers {
+		return m.Workers, ""
+	}
+
+	var reason string
+	switch {
+	case a.cfg.QueueDepthPerWorker > 0 && m.Queued >= a.cfg.QueueDepthPerWorker*m.Workers:
+		reason = fmt.Sprintf("queue depth %d", m.Queued)
+	case a.cfg.P95Latency > 0 && m.P95Latency >= a.cfg.P95Latency:
+		reason = fmt.Sprintf("p95 latency %v", m.P95Latency.Round(time.Millisecond))
+	default:
+		return m.Workers, ""
+	}
+
+	target := m.Workers + a.cfg.ScaleUpStep
+	if target > a.cfg.MaxWorkers {
+		target = a.cfg.MaxWorkers
+	}
+	return target, reason
+}
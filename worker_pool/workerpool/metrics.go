@@ -0,0 +1,138 @@
+package workerpool
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Metrics - point-in-time snapshot of the pool
+// Latency = submit -> finished (queue wait + execution), over the last
+// latencyWindow jobs
+type Metrics struct {
+	Workers int // current pool size
+	Busy    int // workers executing a job right now
+	Queued  int // jobs waiting for a worker
+
+	Submitted uint64
+	Completed uint64 // finished, with or without error
+	Failed    uint64 // finished with error (incl. panics, deadlines)
+
+	P50Latency time.Duration
+	P95Latency time.Duration
+
+	ScaleUps   int // only changes in autoscaling mode
+	ScaleDowns int
+}
+
+const defaultLatencyWindow = 200
+
+// poolStats - counters updated by submit / workers, read by Metrics
+// and by the autoscaler
+type poolStats struct {
+	busy      int
+	submitted uint64
+	completed uint64
+	failed    uint64
+
+	latencies []time.Duration // ring buffer of recent job latencies
+	next      int
+	filled    bool
+
+	scaleUps   int
+	scaleDowns int
+
+	mu sync.Mutex
+}
+
+func newPoolStats(window int) *poolStats {
+	if window <= 0 {
+		window = defaultLatencyWindow
+	}
+	return &poolStats{latencies: make([]time.Duration, window)}
+}
+
+func (s *poolStats) jobSubmitted() {
+	s.mu.Lock()
+	s.submitted++
+	s.mu.Unlock()
+}
+
+func (s *poolStats) jobStarted() {
+	s.mu.Lock()
+	s.busy++
+	s.mu.Unlock()
+}
+
+func (s *poolStats) jobFinished(latency time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.busy--
+	s.completed++
+	if failed {
+		s.failed++
+	}
+	s.latencies[s.next] = latency
+	s.next = (s.next + 1) % len(s.latencies)
+	if s.next == 0 {
+		s.filled = true
+	}
+}
+
+// resetLatencies - after a resize, old samples describe the OLD pool size
+// => forget them so the next decision only sees the effect of the change
+func (s *poolStats) resetLatencies() {
+	s.mu.Lock()
+	s.next = 0
+	s.filled = false
+	s.mu.Unlock()
+}
+
+func (s *poolStats) scaled(up bool) {
+	s.mu.Lock()
+	if up {
+		s.scaleUps++
+	} else {
+		s.scaleDowns++
+	}
+	s.mu.Unlock()
+}
+
+// percentile - caller holds mu, p in [0, 1], 0 if no samples yet
+func (s *poolStats) percentile(p float64) time.Duration {
+	n := s.next
+	if s.filled {
+		n = len(s.latencies)
+	}
+	if n == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, n)
+	copy(sorted, s.latencies[:n])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[int(p*float64(n-1))]
+}
+
+func (s *poolStats) snapshot() Metrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Metrics{
+		Busy:       s.busy,
+		Submitted:  s.submitted,
+		Completed:  s.completed,
+		Failed:     s.failed,
+		P50Latency: s.percentile(0.50),
+		P95Latency: s.percentile(0.95),
+		ScaleUps:   s.scaleUps,
+		ScaleDowns: s.scaleDowns,
+	}
+}
+
+// Metrics - works for fixed and autoscaling pools
+func (w *WorkerPool) Metrics() Metrics {
+	m := w.stats.snapshot()
+	m.Workers = w.Size()
+	m.Queued = w.QueueLen()
+	return m
+}
@@ -0,0 +1,179 @@
+package workerpool
+
+// SCHEDULER - replaces the plain FIFO jobs channel
+//
+// Problem with FIFO (ecommerce_with_queue):
+//
+//	[Add][Add][Add]...[Add][Check]   <- Check waits behind 1000 AddStockTasks
+//
+// Order in which jobs are picked here:
+//  1. Priority      - higher Job.Priority always goes first
+//  2. Fairness      - among tenants with jobs at that priority, weighted fair
+//                     queuing: tenant served least (relative to its weight) goes next
+//                     => noisy tenant can't starve others
+//  3. Deadline      - within a tenant, earliest deadline first, then FIFO
+//
+// Jobs whose Deadline passed while waiting are failed with
+// ErrDeadlineExceeded instead of being run.
+
+import (
+	"container/heap"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrDeadlineExceeded = errors.New("job deadline passed before it could run")
+
+const defaultTenant = ""
+
+// ============================================
+// PER-TENANT QUEUE (heap: deadline, then FIFO)
+// ============================================
+
+type queuedJob struct {
+	job submission
+	seq int64 // submission order, FIFO tie-breaker
+}
+
+type jobHeap []queuedJob
+
+func (h jobHeap) Len() int { return len(h) }
+
+func (h jobHeap) Less(i, j int) bool {
+	a, b := h[i].job, h[j].job
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	// jobs with a deadline are more urgent than jobs without
+	if !a.Deadline.Equal(b.Deadline) {
+		if a.Deadline.IsZero() {
+			return false
+		}
+		if b.Deadline.IsZero() {
+			return true
+		}
+		return a.Deadline.Before(b.Deadline)
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h jobHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *jobHeap) Push(x interface{}) { *h = append(*h, x.(queuedJob)) }
+
+func (h *jobHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+type tenantQueue struct {
+	jobs        jobHeap
+	weight      float64
+	virtualTime float64 // service received / weight (WFQ)
+}
+
+// ============================================
+// SCHEDULER
+// ============================================
+
+type scheduler struct {
+	tenants     map[string]*tenantQueue
+	weights     map[string]float64 // configured weights (default 1)
+	virtualTime float64            // virtual time of last served job
+	seq         int64
+	size        int
+	mu          sync.Mutex
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{
+		tenants: make(map[string]*tenantQueue),
+		weights: make(map[string]float64),
+	}
+}
+
+func (s *scheduler) setWeight(tenant string, weight float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.weights[tenant] = weight
+	if tq, ok := s.tenants[tenant]; ok {
+		tq.weight = weight
+	}
+}
+
+func (s *scheduler) push(job submission) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tq, ok := s.tenants[job.Tenant]
+	if !ok {
+		weight, ok := s.weights[job.Tenant]
+		if !ok {
+			weight = 1
+		}
+		tq = &tenantQueue{weight: weight}
+		s.tenants[job.Tenant] = tq
+	}
+
+	// Tenant was idle: it doesn't get to "bank" credit for the time it
+	// wasn't submitting, otherwise it could starve everyone after a pause
+	if tq.jobs.Len() == 0 && tq.virtualTime < s.virtualTime {
+		tq.virtualTime = s.virtualTime
+	}
+
+	s.seq++
+	heap.Push(&tq.jobs, queuedJob{job: job, seq: s.seq})
+	s.size++
+}
+
+// pop - next job to run, ok=false if empty
+func (s *scheduler) pop() (submission, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var best *tenantQueue
+	for _, tq := range s.tenants {
+		if tq.jobs.Len() == 0 {
+			continue
+		}
+		if best == nil {
+			best = tq
+			continue
+		}
+		head, bestHead := tq.jobs[0].job, best.jobs[0].job
+		switch {
+		case head.Priority > bestHead.Priority:
+			best = tq
+		case head.Priority == bestHead.Priority && tq.virtualTime < best.virtualTime:
+			best = tq
+		case head.Priority == bestHead.Priority && tq.virtualTime == best.virtualTime &&
+			tq.jobs[0].seq < best.jobs[0].seq:
+			best = tq
+		}
+	}
+
+	if best == nil {
+		return submission{}, false
+	}
+
+	item := heap.Pop(&best.jobs).(queuedJob)
+	best.virtualTime += 1 / best.weight
+	s.virtualTime = best.virtualTime
+	s.size--
+	return item.job, true
+}
+
+func (s *scheduler) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+func expired(job submission, now time.Time) bool {
+	return !job.Deadline.IsZero() && now.After(job.Deadline)
+}
@@ -0,0 +1,50 @@
+package workerpool
+
+import (
+	"time"
+
+	"yourname/observability/tracing"
+)
+
+// ============================================
+// TRACING
+// With a tracer every job becomes a small span tree under the caller's
+// span (whatever Submit's ctx carries):
+//
+//	workerpool.job          job.id, job.tenant, job.priority
+//	├── workerpool.submit      waiting for queue space (ErrQueueFull here)
+//	├── workerpool.queue_wait  enqueued -> picked by worker.id
+//	├── workerpool.execute     Task.Execute, error / panic recorded
+//	└── workerpool.result      future + Results() stream delivery
+//
+// The task's ctx carries the execute span => spans the task starts
+// itself (DB call, Publish ...) nest below it.
+// ============================================
+
+// SetTracer - must be called before Start; nil switches tracing off
+func (w *WorkerPool) SetTracer(tracer *tracing.Tracer) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.tracer = tracer
+}
+
+func jobAttributes(job submission) []tracing.Attribute {
+	attrs := []tracing.Attribute{{Key: "job.id", Value: job.ID}}
+	if job.Tenant != "" {
+		attrs = append(attrs, tracing.Attribute{Key: "job.tenant", Value: job.Tenant})
+	}
+	if job.Priority != 0 {
+		attrs = append(attrs, tracing.Attribute{Key: "job.priority", Value: job.Priority})
+	}
+	return attrs
+}
+
+// traceQueueWait - span measured after the fact: it starts when the job
+// entered the queue, we only learn its end when a worker pops it
+func (w *WorkerPool) traceQueueWait(job submission, workerID int) {
+	_, span := w.tracer.StartSpan(job.ctx, "workerpool.queue_wait", tracing.SpanOptions{
+		StartTime:  job.enqueuedAt,
+		Attributes: []tracing.Attribute{{Key: "worker.id", Value: workerID}},
+	})
+	span.EndAt(time.Now())
+}
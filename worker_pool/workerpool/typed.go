@@ -0,0 +1,123 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ============================================
+// TYPED POOL
+// WorkerPool runs Task[any] => caller gets Output interface{} and has
+// to write result.Output.(int). Pool[T] is a typed view on the same
+// workers: Task[T] in, Future[T] out, no assertions.
+//
+//	squares := workerpool.NewPool[int](5, 100)
+//	future, _ := squares.Submit(ctx, SquareTask{Number: 3})
+//	n, err := future.Get() // n is an int
+//
+// Several Pool[T] can share one WorkerPool (TypedPool[T](shared))
+// => one set of workers and one queue for every result type.
+// ============================================
+
+type Pool[T any] struct {
+	*WorkerPool // Start, Wait, Resize, Shutdown, Metrics ...
+}
+
+// NewPool - typed pool with its own workers
+func NewPool[T any](numWorkers int, jobQueueSize int) *Pool[T] {
+	return &Pool[T]{WorkerPool: NewWorkerPool(numWorkers, jobQueueSize)}
+}
+
+// TypedPool - typed view on an existing (shared) WorkerPool
+func TypedPool[T any](w *WorkerPool) *Pool[T] {
+	return &Pool[T]{WorkerPool: w}
+}
+
+func (p *Pool[T]) Submit(ctx context.Context, task Task[T]) (*Future[T], error) {
+	return p.submit(ctx, Job[T]{Task: task}, true)
+}
+
+// SubmitJob - with Priority / Deadline / Tenant
+func (p *Pool[T]) SubmitJob(ctx context.Context, job Job[T]) (*Future[T], error) {
+	return p.submit(ctx, job, true)
+}
+
+// TrySubmit - fail with ErrQueueFull instead of waiting for space
+func (p *Pool[T]) TrySubmit(ctx context.Context, task Task[T]) (*Future[T], error) {
+	return p.submit(ctx, Job[T]{Task: task}, false)
+}
+
+func (p *Pool[T]) submit(ctx context.Context, job Job[T], block bool) (*Future[T], error) {
+	typed := newFuture[T](0)
+	task := job.Task
+
+	untyped, err := p.WorkerPool.submit(ctx, submission{
+		Job: Job[any]{
+			Task: TaskFunc[any](func(ctx context.Context) (any, error) {
+				return task.Execute(ctx)
+			}),
+			Priority: job.Priority,
+			Deadline: job.Deadline,
+			Tenant:   job.Tenant,
+		},
+		onDone: func(result Result[any]) {
+			// Output is nil when the task failed before returning
+			// (cancelled, expired, panicked) => zero T
+			output, _ := result.Output.(T)
+			typed.complete(Result[T]{JobID: result.JobID, Err: result.Err, Output: output})
+		},
+	}, block)
+	if err != nil {
+		return nil, err
+	}
+	typed.jobID = untyped.JobID()
+	return typed, nil
+}
+
+// ============================================
+// BULK HELPERS
+// ============================================
+
+// Map - runs fn for every item on pool, results in input order
+// Every item runs even if some fail; failures come back as ONE error
+// (errors.Join, each tagged with its index), failed slots hold zero Out
+func Map[In, Out any](ctx context.Context, pool *WorkerPool, items []In, fn func(context.Context, In) (Out, error)) ([]Out, error) {
+	typed := TypedPool[Out](pool)
+	futures := make([]*Future[Out], len(items))
+	errs := make([]error, len(items)) // by index => joined in input order, nil skipped
+
+	for i, item := range items {
+		item := item
+		future, err := typed.Submit(ctx, TaskFunc[Out](func(ctx context.Context) (Out, error) {
+			return fn(ctx, item)
+		}))
+		if err != nil {
+			errs[i] = fmt.Errorf("item %d: %w", i, err)
+			continue
+		}
+		futures[i] = future
+	}
+
+	results := make([]Out, len(items))
+	for i, future := range futures {
+		if future == nil {
+			continue // never submitted, error already recorded
+		}
+		output, err := future.Get()
+		if err != nil {
+			errs[i] = fmt.Errorf("item %d: %w", i, err)
+			continue
+		}
+		results[i] = output
+	}
+	return results, errors.Join(errs...)
+}
+
+// ForEach - Map without results
+func ForEach[In any](ctx context.Context, pool *WorkerPool, items []In, fn func(context.Context, In) error) error {
+	_, err := Map(ctx, pool, items, func(ctx context.Context, item In) (struct{}, error) {
+		return struct{}{}, fn(ctx, item)
+	})
+	return err
+}
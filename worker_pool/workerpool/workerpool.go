@@ -0,0 +1,590 @@
+// Package workerpool - ONE worker pool for the whole repo
+//
+// worker_pool/proper_worker_pool.go, ecommerce_with_queue/main.go and
+// pubsub_with_worker_pool.go each used to carry their own copy, which had:
+//
+//	Problem                                   Fix here
+//	-------------------------------------     -----------------------------------------
+//	panicking Task.Execute kills process  ->  recover() turns panic into Result.Err
+//	SubmitJob fails instantly if full     ->  Submit blocks until space / ctx / timeout
+//	                                          (TrySubmit keeps the old fail-fast mode)
+//	ResultsChannel deadlocks if not read  ->  every Submit returns its own Future,
+//	                                          Results() stream is optional and never
+//	                                          blocks workers
+//	fixed number of workers               ->  Resize(n) at runtime
+//	Shutdown drops queued jobs            ->  Shutdown(ctx) drains queue, ctx bounds it
+//	FIFO queue, no priorities             ->  priority / deadline / per-tenant fair
+//	                                          scheduling (see scheduler.go)
+//	guessing numWorkers                   ->  NewAutoscalingWorkerPool grows / shrinks
+//	                                          on queue depth and p95 latency, Metrics()
+//	                                          (see autoscale.go, metrics.go)
+//	Output interface{} + .(int) everywhere ->  Task[T] / Pool[T] return typed results,
+//	                                          Map / ForEach for bulk work (see typed.go)
+//	"Worker 3: Job 17 failed" printf only  ->  SetTracer: submit / queue_wait / execute /
+//	                                          result spans under the caller's trace
+//	                                          (see tracing.go)
+//
+// Usage:
+//
+//	pool := workerpool.NewPool[int](5, 100)
+//	pool.Start()
+//	future, err := pool.Submit(ctx, task) // task is a Task[int]
+//	n, err := future.Get()
+//	pool.Shutdown(ctx)
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"yourname/observability/tracing"
+)
+
+var (
+	ErrQueueFull    = errors.New("job queue is full")
+	ErrPoolClosed   = errors.New("worker pool is shut down")
+	ErrTaskPanicked = errors.New("task panicked")
+)
+
+// Task - unit of work producing a T
+// ctx is cancelled when the submitter cancels or the pool is force-stopped,
+// long running tasks should check it
+// The untyped WorkerPool runs Task[any]: any type with
+// Execute(ctx) (interface{}, error) already is one
+type Task[T any] interface {
+	Execute(ctx context.Context) (T, error)
+}
+
+// TaskFunc - lets a plain function be used as a Task
+type TaskFunc[T any] func(ctx context.Context) (T, error)
+
+func (f TaskFunc[T]) Execute(ctx context.Context) (T, error) {
+	return f(ctx)
+}
+
+type Job[T any] struct {
+	ID   int // assigned by the pool
+	Task Task[T]
+
+	Priority int       // higher runs first (default 0)
+	Deadline time.Time // zero = none; job is failed if not started by then
+	Tenant   string    // fairness key (user, merchant ...), "" = default tenant
+}
+
+// submission - a Job plus the pool's bookkeeping, what sits in the queue
+type submission struct {
+	Job[any]
+
+	ctx         context.Context
+	future      *Future[any]
+	onDone      func(Result[any]) // typed Pool[T] completes its own future here
+	submittedAt time.Time
+	enqueuedAt  time.Time
+	span        *tracing.Span // "workerpool.job", nil when tracing is off
+}
+
+type Result[T any] struct {
+	JobID  int
+	Err    error
+	Output T
+}
+
+// ============================================
+// FUTURE - one per submitted job
+// Caller waits on ITS OWN future, never on a shared channel
+// => can't receive someone else's result
+// ============================================
+
+type Future[T any] struct {
+	jobID  int
+	done   chan struct{}
+	result Result[T]
+}
+
+func newFuture[T any](jobID int) *Future[T] {
+	return &Future[T]{
+		jobID: jobID,
+		done:  make(chan struct{}),
+	}
+}
+
+func (f *Future[T]) JobID() int {
+	return f.jobID
+}
+
+// Done - closed when the result is ready (usable in select)
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Result - blocks until the job finished
+func (f *Future[T]) Result() Result[T] {
+	<-f.done
+	return f.result
+}
+
+// Get - Result split into value and error
+func (f *Future[T]) Get() (T, error) {
+	<-f.done
+	return f.result.Output, f.result.Err
+}
+
+// Wait - like Result but gives up when ctx is done
+// (the job itself keeps running, only the waiting stops)
+func (f *Future[T]) Wait(ctx context.Context) (Result[T], error) {
+	select {
+	case <-f.done:
+		return f.result, nil
+	case <-ctx.Done():
+		return Result[T]{JobID: f.jobID}, ctx.Err()
+	}
+}
+
+func (f *Future[T]) complete(result Result[T]) {
+	f.result = result
+	close(f.done)
+}
+
+// ============================================
+// WORKER POOL
+// ============================================
+
+type WorkerPool struct {
+	// SubmitTimeout - how long Submit waits for queue space
+	//   0  => wait until ctx is done
+	//   >0 => wait at most this long, then ErrQueueFull
+	SubmitTimeout time.Duration
+
+	queue      *scheduler
+	slots      chan struct{} // one per free queue slot => bounds queue size
+	available  chan struct{} // one token per queued job => wakes a worker
+	jobIDCount int
+
+	workers  []chan struct{} // one stop channel per running worker
+	workerID int             // last assigned worker ID (for logs)
+	workerWg sync.WaitGroup  // running worker goroutines
+	started  bool
+
+	pending    sync.WaitGroup // submitted but not finished jobs (for Wait)
+	submitting sync.WaitGroup // Submit calls currently blocked on the queue
+	closing    chan struct{}  // closed by Shutdown => unblocks submitters
+	closed     bool
+
+	// force-stop: cancelled when Shutdown ctx expires
+	baseCtx    context.Context
+	cancelBase context.CancelFunc
+
+	results *resultStream // nil unless EnableResultStream was called
+
+	stats     *poolStats
+	autoscale *autoscaler     // nil = fixed size
+	tracer    *tracing.Tracer // nil = no spans
+
+	mu sync.Mutex
+}
+
+func NewWorkerPool(numWorkers int, jobQueueSize int) *WorkerPool {
+	baseCtx, cancel := context.WithCancel(context.Background())
+	return &WorkerPool{
+		queue:      newScheduler(),
+		slots:      make(chan struct{}, jobQueueSize),
+		available:  make(chan struct{}, jobQueueSize),
+		workers:    make([]chan struct{}, numWorkers),
+		closing:    make(chan struct{}),
+		baseCtx:    baseCtx,
+		cancelBase: cancel,
+		stats:      newPoolStats(0),
+	}
+}
+
+func (w *WorkerPool) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.started {
+		return
+	}
+	w.started = true
+	for i := range w.workers {
+		w.workers[i] = w.spawnWorker()
+	}
+	if w.autoscale != nil {
+		go w.autoscale.run(w)
+	}
+}
+
+// spawnWorker - caller must hold w.mu
+func (w *WorkerPool) spawnWorker() chan struct{} {
+	stop := make(chan struct{})
+	w.workerID++
+	w.workerWg.Add(1)
+	go w.worker(w.workerID, stop)
+	return stop
+}
+
+// Size - current number of workers
+func (w *WorkerPool) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.workers)
+}
+
+// Resize - grow or shrink at runtime
+// Shrinking never interrupts a running job: the stopped worker
+// finishes its current job first, then exits
+func (w *WorkerPool) Resize(n int) error {
+	if n < 1 {
+		return fmt.Errorf("worker pool needs at least 1 worker, got %d", n)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrPoolClosed
+	}
+	if !w.started {
+		// not running yet, Start will spawn n workers
+		w.workers = make([]chan struct{}, n)
+		return nil
+	}
+
+	for len(w.workers) < n {
+		w.workers = append(w.workers, w.spawnWorker())
+	}
+	for len(w.workers) > n {
+		last := len(w.workers) - 1
+		close(w.workers[last])
+		w.workers = w.workers[:last]
+	}
+	return nil
+}
+
+func (w *WorkerPool) worker(workerID int, stop chan struct{}) {
+	defer w.workerWg.Done()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case _, ok := <-w.available:
+			if !ok {
+				return // Shutdown: queue drained and closed
+			}
+			// token guarantees the queue has at least one job for us
+			job, _ := w.queue.pop()
+			<-w.slots // free the slot for the next Submit
+			w.traceQueueWait(job, workerID)
+			result := w.execute(job, workerID)
+			if result.Err != nil && w.tracer == nil {
+				// with a tracer the error is on the execute span instead
+				fmt.Printf("Worker %d: Job %d failed: %v\n", workerID, job.ID, result.Err)
+			}
+		}
+	}
+}
+
+// execute - runs one job, never panics, always completes the future
+func (w *WorkerPool) execute(job submission, workerID int) (result Result[any]) {
+	result.JobID = job.ID
+	w.stats.jobStarted()
+
+	// child of the job span => the task's own spans nest under execute
+	spanCtx, span := w.tracer.StartSpan(job.ctx, "workerpool.execute", tracing.SpanOptions{
+		Attributes: []tracing.Attribute{{Key: "worker.id", Value: workerID}},
+	})
+
+	defer func() {
+		if r := recover(); r != nil {
+			result.Output = nil
+			result.Err = fmt.Errorf("%w: %v", ErrTaskPanicked, r)
+		}
+		span.RecordError(result.Err)
+		span.End()
+		w.stats.jobFinished(time.Since(job.submittedAt), result.Err != nil)
+		w.deliver(job, result)
+		w.pending.Done()
+	}()
+
+	// Submitter gave up while job was waiting in queue => don't even start
+	if err := job.ctx.Err(); err != nil {
+		result.Err = err
+		return result
+	}
+	if expired(job, time.Now()) {
+		result.Err = ErrDeadlineExceeded
+		return result
+	}
+
+	// Job ctx is cancelled by submitter OR by forced shutdown OR deadline
+	ctx, cancel := context.WithCancel(spanCtx)
+	defer cancel()
+	if !job.Deadline.IsZero() {
+		ctx, cancel = context.WithDeadline(ctx, job.Deadline)
+		defer cancel()
+	}
+	stopOnForce := context.AfterFunc(w.baseCtx, cancel)
+	defer stopOnForce()
+
+	result.Output, result.Err = job.Task.Execute(ctx)
+	return result
+}
+
+// deliver - future, typed hook, result stream; "workerpool.result" span
+// shows how long handing the result back took, then the job span ends
+func (w *WorkerPool) deliver(job submission, result Result[any]) {
+	_, span := w.tracer.Start(job.ctx, "workerpool.result")
+	job.future.complete(result)
+	if job.onDone != nil {
+		job.onDone(result)
+	}
+	if w.results != nil {
+		w.results.push(result)
+	}
+	span.End()
+
+	job.span.RecordError(result.Err)
+	job.span.End()
+}
+
+// Submit - queue a task, waits for space in the queue
+// Returns error if ctx is done, SubmitTimeout passes or pool is shut down
+func (w *WorkerPool) Submit(ctx context.Context, task Task[any]) (*Future[any], error) {
+	return w.submit(ctx, submission{Job: Job[any]{Task: task}}, true)
+}
+
+// SubmitJob - like Submit, with Priority / Deadline / Tenant set on job
+// (job.ID is ignored, the pool assigns it)
+func (w *WorkerPool) SubmitJob(ctx context.Context, job Job[any]) (*Future[any], error) {
+	return w.submit(ctx, submission{Job: job}, true)
+}
+
+// TrySubmit - old SubmitJob behaviour: fail immediately if queue is full
+func (w *WorkerPool) TrySubmit(ctx context.Context, task Task[any]) (*Future[any], error) {
+	return w.submit(ctx, submission{Job: Job[any]{Task: task}}, false)
+}
+
+// SetTenantWeight - tenant with weight 2 gets twice the share of a
+// tenant with weight 1 when both have jobs waiting (default 1)
+func (w *WorkerPool) SetTenantWeight(tenant string, weight float64) error {
+	if weight <= 0 {
+		return fmt.Errorf("tenant weight must be > 0, got %v", weight)
+	}
+	w.queue.setWeight(tenant, weight)
+	return nil
+}
+
+// QueueLen - jobs waiting to be picked by a worker
+func (w *WorkerPool) QueueLen() int {
+	return w.queue.len()
+}
+
+func (w *WorkerPool) submit(ctx context.Context, job submission, block bool) (*Future[any], error) {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	w.jobIDCount++
+	job.ID = w.jobIDCount
+	job.future = newFuture[any](job.ID)
+	job.submittedAt = time.Now()
+	w.submitting.Add(1)
+	w.mu.Unlock()
+	defer w.submitting.Done()
+
+	// job span lives from Submit until the result is delivered,
+	// job.ctx carries it => every later span of this job is its child
+	job.ctx, job.span = w.tracer.StartSpan(ctx, "workerpool.job", tracing.SpanOptions{
+		StartTime:  job.submittedAt,
+		Attributes: jobAttributes(job),
+	})
+	_, submitSpan := w.tracer.StartSpan(job.ctx, "workerpool.submit", tracing.SpanOptions{StartTime: job.submittedAt})
+	err := w.waitForSlot(ctx, job, block)
+	submitSpan.RecordError(err)
+	submitSpan.End()
+	if err != nil {
+		job.span.RecordError(err)
+		job.span.End()
+		return nil, err
+	}
+	return job.future, nil
+}
+
+// waitForSlot - queue the job or say why not
+func (w *WorkerPool) waitForSlot(ctx context.Context, job submission, block bool) error {
+	// Count as pending BEFORE it can be picked up, otherwise the
+	// worker's Done() could run before our Add()
+	w.pending.Add(1)
+
+	if !block {
+		select {
+		case w.slots <- struct{}{}:
+			w.enqueue(job)
+			return nil
+		default:
+			w.pending.Done()
+			return ErrQueueFull
+		}
+	}
+
+	var timeout <-chan time.Time
+	if w.SubmitTimeout > 0 {
+		timer := time.NewTimer(w.SubmitTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case w.slots <- struct{}{}:
+		w.enqueue(job)
+		return nil
+	case <-ctx.Done():
+		w.pending.Done()
+		return ctx.Err()
+	case <-timeout:
+		w.pending.Done()
+		return ErrQueueFull
+	case <-w.closing:
+		w.pending.Done()
+		return ErrPoolClosed
+	}
+}
+
+// enqueue - caller must own a slot
+// Job goes into the scheduler FIRST, then the token => a worker that
+// receives a token always finds a job
+func (w *WorkerPool) enqueue(job submission) {
+	job.enqueuedAt = time.Now()
+	w.stats.jobSubmitted()
+	w.queue.push(job)
+	w.available <- struct{}{}
+}
+
+// Wait - blocks until every submitted job has finished
+func (w *WorkerPool) Wait() {
+	w.pending.Wait()
+}
+
+// Shutdown - graceful stop
+//  1. reject new submissions
+//  2. let workers drain everything already queued
+//  3. if ctx expires first: cancel running jobs' ctx and return ctx.Err()
+func (w *WorkerPool) Shutdown(ctx context.Context) error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return ErrPoolClosed
+	}
+	w.closed = true
+	close(w.closing)
+	if !w.started {
+		// nobody would drain the queue otherwise
+		w.started = true
+		for i := range w.workers {
+			w.workers[i] = w.spawnWorker()
+		}
+	}
+	w.mu.Unlock()
+
+	// No Submit can be mid-enqueue after this => safe to close queue
+	w.submitting.Wait()
+	close(w.available)
+
+	drained := make(chan struct{})
+	go func() {
+		w.workerWg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		w.cancelBase()
+		if w.results != nil {
+			w.results.close()
+		}
+		return nil
+	case <-ctx.Done():
+		w.cancelBase() // tell running tasks to give up
+		return ctx.Err()
+	}
+}
+
+// ============================================
+// RESULT STREAM (optional)
+// For callers who want "all results as they come" instead of futures
+// Workers push into an unbounded buffer and move on,
+// a single forwarder goroutine feeds the channel
+// => slow or absent reader can never block a worker
+// ============================================
+
+type resultStream struct {
+	out     chan Result[any]
+	buffer  []Result[any]
+	signal  chan struct{}
+	stopped bool
+	mu      sync.Mutex
+}
+
+// EnableResultStream - must be called before Start
+func (w *WorkerPool) EnableResultStream() <-chan Result[any] {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.results == nil {
+		w.results = &resultStream{
+			out:    make(chan Result[any]),
+			signal: make(chan struct{}, 1),
+		}
+		go w.results.forward()
+	}
+	return w.results.out
+}
+
+func (s *resultStream) push(result Result[any]) {
+	s.mu.Lock()
+	s.buffer = append(s.buffer, result)
+	s.mu.Unlock()
+
+	select {
+	case s.signal <- struct{}{}:
+	default: // forwarder already notified
+	}
+}
+
+func (s *resultStream) close() {
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+
+	select {
+	case s.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (s *resultStream) forward() {
+	defer close(s.out)
+
+	for range s.signal {
+		for {
+			s.mu.Lock()
+			if len(s.buffer) == 0 {
+				stopped := s.stopped
+				s.mu.Unlock()
+				if stopped {
+					return
+				}
+				break
+			}
+			next := s.buffer[0]
+			s.buffer = s.buffer[1:]
+			s.mu.Unlock()
+
+			s.out <- next
+		}
+	}
+}